@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/cg219/nowplaying/pkg/webtoken"
+)
+
+func main() {
+    file := flag.String("file", os.Getenv("JWT_KEYS"), "keys file the server reads (JWT_KEYS)")
+    keep := flag.Duration("keep", time.Hour * 24 * 30, "how long retired keys keep verifying; match the refresh token lifetime")
+    flag.Usage = func() {
+        fmt.Fprintf(flag.CommandLine.Output(), "usage: jwtkeys [-file keys.yml] [-keep 720h] <rotate|list|remove KID>\n\n")
+        fmt.Fprintf(flag.CommandLine.Output(), "  rotate      add a new signing key, retire the current one and drop keys retired longer than -keep\n")
+        fmt.Fprintf(flag.CommandLine.Output(), "  list        show every key and its state\n")
+        fmt.Fprintf(flag.CommandLine.Output(), "  remove KID  drop a retired key now, ending sessions it signed\n\n")
+        flag.PrintDefaults()
+    }
+
+    flag.Parse()
+
+    if *file == "" || flag.NArg() == 0 {
+        flag.Usage()
+        os.Exit(2)
+    }
+
+    now := time.Now()
+    keys := webtoken.NewKeySet()
+
+    data, err := os.ReadFile(*file)
+    if err == nil {
+        keys, err = webtoken.ParseKeySet(data)
+        if err != nil {
+            log.Fatalf("reading %s: %s\n", *file, err)
+        }
+    } else if !os.IsNotExist(err) || flag.Arg(0) != "rotate" {
+        log.Fatal(err)
+    }
+
+    switch flag.Arg(0) {
+    case "rotate":
+        key, err := keys.Rotate(now)
+        if err != nil {
+            log.Fatal(err)
+        }
+
+        for _, removed := range keys.Prune(now.Add(-*keep)) {
+            log.Printf("removed %s, retired %s\n", removed.Id, removed.RetiredAt.Format(time.RFC3339))
+        }
+
+        save(*file, keys)
+        log.Printf("signing with %s; restart the server to pick it up\n", key.Id)
+    case "list":
+        for _, key := range keys.Keys {
+            state := "active"
+            if !key.RetiredAt.IsZero() {
+                state = fmt.Sprintf("retired %s, removed after %s", key.RetiredAt.Format(time.RFC3339), key.RetiredAt.Add(*keep).Format(time.RFC3339))
+            }
+
+            fmt.Printf("%s\tcreated %s\t%s\n", key.Id, key.CreatedAt.Format(time.RFC3339), state)
+        }
+    case "remove":
+        if flag.NArg() != 2 {
+            flag.Usage()
+            os.Exit(2)
+        }
+
+        if err := keys.Remove(flag.Arg(1)); err != nil {
+            log.Fatal(err)
+        }
+
+        save(*file, keys)
+        log.Printf("removed %s\n", flag.Arg(1))
+    default:
+        flag.Usage()
+        os.Exit(2)
+    }
+}
+
+func save(file string, keys *webtoken.KeySet) {
+    data, err := keys.Marshal()
+    if err != nil {
+        log.Fatal(err)
+    }
+
+    if err := os.WriteFile(file, data, 0600); err != nil {
+        log.Fatal(err)
+    }
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/cg219/nowplaying/internal/app"
+	"github.com/cg219/nowplaying/internal/database"
+	"github.com/cg219/nowplaying/pkg/spotup"
+	_ "modernc.org/sqlite"
+)
+
+func main() {
+    username := flag.String("user", "", "user the history belongs to")
+    flag.Usage = func() {
+        fmt.Fprintf(flag.CommandLine.Output(), "usage: spotup -user <username> <export.zip|Streaming_History_Audio.json>...\n")
+        flag.PrintDefaults()
+    }
+
+    flag.Parse()
+
+    if *username == "" || flag.NArg() == 0 {
+        flag.Usage()
+        os.Exit(2)
+    }
+
+    cwd, _ := os.Getwd()
+    db, err := sql.Open("sqlite", app.DataSource(filepath.Join(cwd, os.Getenv("APP_DATA"))))
+    if err != nil {
+        log.Fatal(err)
+    }
+
+    defer db.Close()
+
+    ctx := context.Background()
+    user, err := database.New(db).GetUser(ctx, *username)
+    if err != nil {
+        log.Fatalf("finding user %s: %s\n", *username, err)
+    }
+
+    for _, name := range flag.Args() {
+        entries, err := spotup.ReadFile(name)
+        if err != nil {
+            log.Fatalf("reading %s: %s\n", name, err)
+        }
+
+        result, err := app.ImportSpotifyHistory(ctx, db, user.ID, entries)
+        if err != nil {
+            log.Fatalf("importing %s: %s\n", name, err)
+        }
+
+        log.Printf("%s: %d entries, %d imported, %d skipped, %d duplicates\n", name, result.Entries, result.Imported, result.Skipped, result.Duplicates)
+    }
+}
@@ -0,0 +1,247 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cg219/nowplaying/internal/database"
+)
+
+const (
+    SCOPE_SCROBBLE = "scrobble:write"
+    SCOPE_STATS = "stats:read"
+    SCOPE_SHARE = "share:post"
+    apiKeyPrefixLength = 8
+    // last_used_at is only written when it is at least this stale, so busy
+    // keys don't turn every request into a write.
+    apiKeyTouchInterval = time.Minute
+)
+
+var (
+    apiKeyScopes = []string{ SCOPE_SCROBBLE, SCOPE_STATS, SCOPE_SHARE }
+    errAPIKeyScope = errors.New("api key is missing a scope")
+)
+
+type ApiKey struct {
+    Id int64 `json:"id"`
+    Name string `json:"name"`
+    Prefix string `json:"prefix"`
+    Scopes []string `json:"scopes"`
+    CreatedAt int64 `json:"createdAt"`
+    LastUsedAt int64 `json:"lastUsedAt,omitempty"`
+    ExpiresAt int64 `json:"expiresAt,omitempty"`
+}
+
+func validScopes(scopes []string) bool {
+    if len(scopes) == 0 {
+        return false
+    }
+
+    for _, scope := range scopes {
+        if !slices.Contains(apiKeyScopes, scope) {
+            return false
+        }
+    }
+
+    return true
+}
+
+// createAPIKey stores a new key and returns the raw value, which is the only
+// time it is ever available. A zero expires never expires.
+func createAPIKey(ctx context.Context, db *database.Queries, uid int64, name string, scopes []string, expires time.Time) (string, ApiKey, error) {
+    key, err := newAPIKey()
+    if err != nil {
+        return "", ApiKey{}, err
+    }
+
+    info := ApiKey{
+        Name: name,
+        Prefix: key[:apiKeyPrefixLength],
+        Scopes: scopes,
+        CreatedAt: time.Now().UnixMilli(),
+    }
+
+    if !expires.IsZero() {
+        info.ExpiresAt = expires.UnixMilli()
+    }
+
+    info.Id, err = db.SaveApiKey(ctx, database.SaveApiKeyParams{
+        Uid: uid,
+        Name: name,
+        Prefix: info.Prefix,
+        Hash: hashToken(key),
+        Scopes: strings.Join(scopes, " "),
+        CreatedAt: info.CreatedAt,
+        ExpiresAt: sql.NullInt64{ Int64: info.ExpiresAt, Valid: info.ExpiresAt != 0 },
+    })
+
+    return key, info, err
+}
+
+// replaceAPIKey issues a fresh key under the user's existing key with this
+// name, creating one if there isn't one yet. Raw keys aren't kept, so the old
+// value stops working.
+func replaceAPIKey(ctx context.Context, db *database.Queries, uid int64, name string, scopes []string) (string, error) {
+    id, err := db.GetApiKeyByName(ctx, database.GetApiKeyByNameParams{ Uid: uid, Name: name })
+    if err == sql.ErrNoRows {
+        key, _, err := createAPIKey(ctx, db, uid, name, scopes, time.Time{})
+        return key, err
+    }
+
+    if err != nil {
+        return "", err
+    }
+
+    key, err := newAPIKey()
+    if err != nil {
+        return "", err
+    }
+
+    err = db.ReplaceApiKeyHash(ctx, database.ReplaceApiKeyHashParams{
+        Prefix: key[:apiKeyPrefixLength],
+        Hash: hashToken(key),
+        ID: id,
+    })
+
+    return key, err
+}
+
+// apiKeyUser finds the user behind a key that hasn't expired and carries
+// scope, and records that the key was used. Unknown or expired keys return
+// sql.ErrNoRows.
+func (s *Server) apiKeyUser(ctx context.Context, key string, scope string) (string, error) {
+    now := time.Now()
+    row, err := s.authCfg.database.GetApiKeyUser(ctx, database.GetApiKeyUserParams{
+        Hash: hashToken(key),
+        Now: sql.NullInt64{ Int64: now.UnixMilli(), Valid: true },
+    })
+
+    if err != nil {
+        return "", err
+    }
+
+    if !slices.Contains(strings.Fields(row.Scopes), scope) {
+        return "", errAPIKeyScope
+    }
+
+    err = s.authCfg.database.TouchApiKey(ctx, database.TouchApiKeyParams{
+        Now: sql.NullInt64{ Int64: now.UnixMilli(), Valid: true },
+        ID: row.ID,
+        Since: sql.NullInt64{ Int64: now.Add(-apiKeyTouchInterval).UnixMilli(), Valid: true },
+    })
+
+    if err != nil {
+        s.log.Error("Touching API Key", "id", row.ID, "error", err)
+    }
+
+    return row.Username, nil
+}
+
+// keyPrefix is safe to log; it is shown next to the key in Settings.
+func keyPrefix(key string) string {
+    if len(key) > apiKeyPrefixLength {
+        return key[:apiKeyPrefixLength]
+    }
+
+    return key
+}
+
+func (s *Server) GetAPIKeys(w http.ResponseWriter, r *http.Request) error {
+    user, err := s.currentUser(r)
+    if err != nil {
+        return err
+    }
+
+    rows, err := s.authCfg.database.GetApiKeys(r.Context(), user.ID)
+    if err != nil {
+        s.log.Error("Getting API Keys", "username", user.Username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    keys := []ApiKey{}
+    for _, row := range rows {
+        keys = append(keys, ApiKey{
+            Id: row.ID,
+            Name: row.Name,
+            Prefix: row.Prefix,
+            Scopes: strings.Fields(row.Scopes),
+            CreatedAt: row.CreatedAt,
+            LastUsedAt: row.LastUsedAt.Int64,
+            ExpiresAt: row.ExpiresAt.Int64,
+        })
+    }
+
+    encode(w, http.StatusOK, keys)
+    return nil
+}
+
+// GenerateAPIKey creates a key with the scopes asked for. ExpiresIn is in
+// days; leave it out for a key that never expires.
+func (s *Server) GenerateAPIKey(w http.ResponseWriter, r *http.Request) error {
+    type Body struct {
+        Name string `json:"name"`
+        Scopes []string `json:"scopes"`
+        ExpiresIn int `json:"expiresIn"`
+    }
+
+    type KeyResp struct {
+        Key string `json:"apikey"`
+        Info ApiKey `json:"key"`
+    }
+
+    user, err := s.currentUser(r)
+    if err != nil {
+        return err
+    }
+
+    body, err := decode[Body](r)
+    if err != nil || strings.TrimSpace(body.Name) == "" || !validScopes(body.Scopes) || body.ExpiresIn < 0 {
+        return fmt.Errorf(BAD_REQUEST_ERROR)
+    }
+
+    var expires time.Time
+    if body.ExpiresIn > 0 {
+        expires = time.Now().AddDate(0, 0, body.ExpiresIn)
+    }
+
+    key, info, err := createAPIKey(r.Context(), s.authCfg.database, user.ID, strings.TrimSpace(body.Name), body.Scopes, expires)
+    if err != nil {
+        s.log.Error("Saving API Key", "username", user.Username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    encode(w, http.StatusOK, KeyResp{ Key: key, Info: info })
+    return nil
+}
+
+func (s *Server) DeleteAPIKey(w http.ResponseWriter, r *http.Request) error {
+    user, err := s.currentUser(r)
+    if err != nil {
+        return err
+    }
+
+    id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+    if err != nil {
+        return fmt.Errorf(BAD_REQUEST_ERROR)
+    }
+
+    deleted, err := s.authCfg.database.DeleteApiKey(r.Context(), database.DeleteApiKeyParams{ Uid: user.ID, ID: id })
+    if err != nil {
+        s.log.Error("Deleting API Key", "username", user.Username, "id", id, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    if deleted == 0 {
+        return fmt.Errorf(NOT_FOUND_ERROR)
+    }
+
+    encode(w, http.StatusOK, SuccessResp{ Success: true })
+    return nil
+}
@@ -5,19 +5,19 @@ import (
 	"crypto/rand"
 	"database/sql"
 	"embed"
-	"encoding/base64"
-	"fmt"
 	"log"
 	"math/big"
 	"os"
 	"os/signal"
 	"path/filepath"
-	"slices"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/cg219/nowplaying/internal/database"
+	"github.com/cg219/nowplaying/pkg/mailer"
+	"github.com/cg219/nowplaying/pkg/musicbrainz"
+	"github.com/cg219/nowplaying/pkg/webtoken"
 	"github.com/dghubble/oauth1"
 	"github.com/dghubble/oauth1/twitter"
 	"github.com/pressly/goose/v3"
@@ -29,7 +29,12 @@ type Config struct {
     LastFM struct {
         Key string `yaml:"key"`
         Secret string `yaml:"secret"`
+        Redirect string `yaml:"redirect"`
     } `yaml:"lastfm"`
+    Audioscrobbler struct {
+        Key string `yaml:"key"`
+        Secret string `yaml:"secret"`
+    } `yaml:"audioscrobbler"`
     Turso struct {
         Name string `yaml:"name"`
         Url string `yaml:"url"`
@@ -37,6 +42,8 @@ type Config struct {
     }
     Data struct {
         Path string `yaml:"data"`
+        Url string `yaml:"url"`
+        Proxies string `yaml:"proxies"`
     } `yaml:"app"`
     Spotify struct {
         Id string `yaml:"id"`
@@ -52,6 +59,21 @@ type Config struct {
         Key string `yaml:"key"`
         Secret string `yaml:"secret"`
     } `json:"discogs"`
+    MusicBrainz struct {
+        Url string `yaml:"url"`
+        Contact string `yaml:"contact"`
+    } `yaml:"musicbrainz"`
+    JWT struct {
+        Secret string `yaml:"secret"`
+        Keys string `yaml:"keys"`
+    } `yaml:"jwt"`
+    SMTP struct {
+        Host string `yaml:"host"`
+        Port string `yaml:"port"`
+        Username string `yaml:"username"`
+        Password string `yaml:"password"`
+        From string `yaml:"from"`
+    } `yaml:"smtp"`
     R2 struct {
         Key string `yaml:"key"`
         Secret string `yaml:"secret"`
@@ -68,20 +90,35 @@ type AppCfg struct {
     TwitterOAuth oauth1.Config
     listenInterval time.Ticker
     database *database.Queries
-    haveNewSessions bool
+    conn *sql.DB
+    supervisor *Supervisor
+    nowPlaying *NowPlayingTracker
+    lastfmImporter *LastFMImporter
+    enricher *MusicBrainzEnricher
+    artwork *ArtworkService
+    keys *webtoken.KeySet
+    mailer mailer.Mailer
     subscribers map[int64]Subscriber
     scrobbles chan ScrobblePack
     subMutex sync.RWMutex
+    scrobbleMutex sync.Mutex
 }
 
+// ScrobblePack carries a track from a source to Run. NowPlaying packs only
+// update the user's current track; Import packs are finished or past listens
+// that should be scrobbled without touching it. Stopped packs mean the source
+// is no longer playing and clear its current track instead of setting it.
 type ScrobblePack struct {
     Scrobble Scrobble
     Username string
+    NowPlaying bool
+    Import bool
+    Stopped bool
 }
 
 type Session interface {
     AuthWithDB(context.Context) error
-    Listen(context.Context, *chan any, chan bool)
+    Listen(context.Context, chan<- ScrobblePack) error
     Encode() []byte
     Decode([]byte) error
 }
@@ -90,12 +127,19 @@ type Publisher interface {
     Register(sub Subscriber) int64
     Unregister(id int64)
     Notify(scrobble Scrobble, username string)
+    NotifyNowPlaying(scrobble Scrobble, username string)
 }
 
 type Subscriber interface {
     Execute(scrobble Scrobble, username string)
 }
 
+// NowPlayingSubscriber is implemented by subscribers that also want to hear
+// about a user's current track before it has been scrobbled.
+type NowPlayingSubscriber interface {
+    NowPlaying(scrobble Scrobble, username string)
+}
+
 func (cfg *AppCfg) Register(sub Subscriber) int64 {
     id, err := rand.Int(rand.Reader, big.NewInt(100000))
     if err != nil {
@@ -126,11 +170,89 @@ func (cfg *AppCfg) Notify(scrobble Scrobble, username string) {
     }
 }
 
+func (cfg *AppCfg) NotifyNowPlaying(scrobble Scrobble, username string) {
+    cfg.subMutex.RLock()
+    defer cfg.subMutex.RUnlock()
+
+    for _, sub := range cfg.subscribers {
+        if nps, ok := sub.(NowPlayingSubscriber); ok {
+            nps.NowPlaying(scrobble, username)
+        }
+    }
+}
+
+// processScrobble is the single path every pack takes: normalize the track,
+// update now playing, apply the Scrobbler rules, and notify subscribers of
+// anything saved. Imported
+// packs are also checked against older scrobbles, not just the latest one. Calls
+// are serialized so two sources can't both save the same track.
+func (cfg *AppCfg) processScrobble(ctx context.Context, pack ScrobblePack) (ScrobbleResult, error) {
+    cfg.scrobbleMutex.Lock()
+    defer cfg.scrobbleMutex.Unlock()
+
+    scrobble := pack.Scrobble
+    username := pack.Username
+    user, err := cfg.database.GetUser(ctx, username)
+    if err != nil {
+        return ScrobbleResult{}, err
+    }
+
+    normalizer, err := loadNormalizer(ctx, cfg.database, user.ID)
+    if err != nil {
+        return ScrobbleResult{}, err
+    }
+
+    scrobble = normalizer.Normalize(scrobble)
+    scrobble.Uid = int(user.ID)
+
+    if pack.Stopped {
+        cfg.nowPlaying.Clear(username, scrobble.Source)
+    } else if !pack.Import {
+        if changed := cfg.nowPlaying.Update(username, scrobble); changed {
+            cfg.NotifyNowPlaying(scrobble, username)
+        }
+    }
+
+    if pack.NowPlaying {
+        return ScrobbleResult{ Status: SCROBBLE_IGNORED, Reason: "Now playing" }, nil
+    }
+
+    if pack.Import {
+        found, err := scrobbleExists(ctx, cfg.database, user.ID, scrobble.ArtistName, scrobble.TrackName, int64(scrobble.Timestamp))
+        if err != nil {
+            return ScrobbleResult{}, err
+        }
+
+        if found {
+            return ScrobbleResult{ Status: SCROBBLE_IGNORED, Reason: "Already scrobbled" }, nil
+        }
+    }
+
+    result, err := NewScrobbler(username, cfg.conn, cfg.database).Submit(ctx, scrobble)
+    if err != nil {
+        return result, err
+    }
+
+    if result.Status == SCROBBLE_ACCEPTED {
+        log.Printf("SCROBBLED: %s - %s\n", scrobble.ArtistName, scrobble.TrackName)
+        cfg.Notify(scrobble, username)
+
+        if cfg.enricher != nil {
+            cfg.enricher.Poke()
+        }
+    }
+
+    return result, nil
+}
+
 func NewConfig(frontend embed.FS, migrations embed.FS) *Config {
     cfg := &Config{}
 
     cfg.LastFM.Key = os.Getenv("LASTFM_KEY")
     cfg.LastFM.Secret = os.Getenv("LASTFM_SECRET")
+    cfg.LastFM.Redirect = os.Getenv("LASTFM_REDIRECT")
+    cfg.Audioscrobbler.Key = os.Getenv("AUDIOSCROBBLER_KEY")
+    cfg.Audioscrobbler.Secret = os.Getenv("AUDIOSCROBBLER_SECRET")
     cfg.Turso.Name = os.Getenv("TURSO_NAME")
     cfg.Turso.Url = os.Getenv("TURSO_URL")
     cfg.Turso.Token = os.Getenv("TURSO_TOKEN")
@@ -142,11 +264,22 @@ func NewConfig(frontend embed.FS, migrations embed.FS) *Config {
     cfg.Twitter.Redirect = os.Getenv("TWITTER_REDIRECT")
     cfg.Discogs.Key = os.Getenv("DISCOGS_KEY")
     cfg.Discogs.Secret = os.Getenv("DISCOGS_SECRET")
+    cfg.MusicBrainz.Url = os.Getenv("MUSICBRAINZ_URL")
+    cfg.MusicBrainz.Contact = os.Getenv("MUSICBRAINZ_CONTACT")
+    cfg.JWT.Secret = os.Getenv("JWT_SECRET")
+    cfg.JWT.Keys = os.Getenv("JWT_KEYS")
     cfg.R2.Key = os.Getenv("R2_KEY")
     cfg.R2.Secret = os.Getenv("R2_SECRET")
     cfg.R2.Token = os.Getenv("R2_TOKEN")
     cfg.R2.Url = os.Getenv("R2_URL")
+    cfg.SMTP.Host = os.Getenv("SMTP_HOST")
+    cfg.SMTP.Port = os.Getenv("SMTP_PORT")
+    cfg.SMTP.Username = os.Getenv("SMTP_USERNAME")
+    cfg.SMTP.Password = os.Getenv("SMTP_PASSWORD")
+    cfg.SMTP.From = os.Getenv("SMTP_FROM")
     cfg.Data.Path = os.Getenv("APP_DATA")
+    cfg.Data.Url = os.Getenv("APP_URL")
+    cfg.Data.Proxies = os.Getenv("APP_PROXIES")
     cfg.Frontend = frontend
     cfg.Migrations = migrations
 
@@ -166,104 +299,59 @@ func NewConfigFromSecrets(data []byte, frontend embed.FS, migrations embed.FS) *
     return cfg
 }
 
-func AppLoop(cfg *AppCfg) bool {
-    cfg.haveNewSessions = false
-    encodedSessions, _ := cfg.database.GetActiveMusicSessions(context.Background())
-    sessions := []Session{}
-    restartLoop := false
-
-    for _, es := range encodedSessions {
-        d, _ := base64.StdEncoding.DecodeString(es.Data)
-
-        switch es.Type {
-        case "spotify":
-            s := NewSpotifyFromEncoded(d, SpotifyConfig(cfg.config.Spotify), cfg.database)
-            s.Id = int(es.ID)
-            sessions = append(sessions, s)
-        case `applemusic`:
-            fmt.Println("Apple Music: ")
-        }
-    }
-
-    exit := make(chan struct{})
-    output := make(chan any)
-    listening := make(chan bool)
-    defer func() {
-        _, ok := <- exit
-        if ok {
-            close(exit)
-        }
-
-        _, ok = <- output
-        if ok {
-            close(output)
+// loadKeySet builds the keys tokens are signed and verified with. A keys file
+// (managed with cmd/jwtkeys) allows rotation; a single secret is used as-is
+// under the "default" kid. With neither, a random key is made at startup and
+// every session ends on restart.
+func loadKeySet(config Config) (*webtoken.KeySet, error) {
+    if config.JWT.Keys != "" {
+        data, err := os.ReadFile(config.JWT.Keys)
+        if err != nil {
+            return nil, err
         }
 
-        _, ok = <- listening
-        if ok {
-            close(listening)
-        }
-    }()
+        return webtoken.ParseKeySet(data)
+    }
 
-    for si, s := range sessions {
-        go func() {
-            s.AuthWithDB(context.Background())
-            s.Listen(context.Background(), &output, listening);
+    if config.JWT.Secret != "" {
+        return webtoken.NewKeySet(webtoken.Key{ Id: "default", Secret: config.JWT.Secret, CreatedAt: time.Now() }), nil
+    }
 
-            <- listening
+    log.Println("no jwt secret or keys file configured; using a temporary key")
 
-            log.Println("Something Goin on")
-            sessions = slices.Delete(sessions, si, si + 1)
-        }()
+    key, err := webtoken.NewKey(time.Now())
+    if err != nil {
+        return nil, err
     }
 
-    go func() {
-        for {
-            if cfg.haveNewSessions {
-                log.Println("new session added")
-                exit <- struct{}{}
-                return
-            }
-        }
-    }()
+    return webtoken.NewKeySet(key), nil
+}
 
-    go func() {
-        for v := range output {
-            switch v := v.(type) {
-            case SpotifyListenValue:
-                user, _ := cfg.database.GetUser(context.Background(), v.Username)
-                scrobbler := NewScrobbler(v.Username, cfg.database)
-                scrobble := Scrobble{
-                    ArtistName: v.Song.Artist,
-                    TrackName: v.Song.Name,
-                    AlbumName: v.Song.Album.Name,
-                    AlbumArtist: v.Song.Album.Artist,
-                    Timestamp: v.Song.Timestamp,
-                    Duration: v.Song.Duration,
-                    TrackNumber: fmt.Sprintf("%d", v.Song.TrackNumber),
-                    Source: "spotify-local",
-                    Uid: int(user.ID),
-                    Progress: v.Song.Progress,
-                }
-
-                if ok := scrobbler.Scrobble(context.Background(), scrobble); ok {
-                    log.Printf("SCROBBLED: %s - %s\n", v.Song.Artist, v.Song.Name)
-                    cfg.Notify(scrobble, v.Username)
-                }
-            }
-        }
-    }()
+// newMailer sends through SMTP when a host is configured. Without one, mail
+// is written to the log so reset and verification links still work locally.
+func newMailer(config Config) mailer.Mailer {
+    if config.SMTP.Host == "" {
+        log.Println("no smtp host configured; mail will be logged instead of sent")
+        return mailer.Log{}
+    }
 
-    <- exit
-    log.Println("Exiting AppLoop()")
+    return mailer.NewSMTP(config.SMTP.Host, config.SMTP.Port, config.SMTP.Username, config.SMTP.Password, config.SMTP.From)
+}
 
-    if cfg.haveNewSessions {
-        restartLoop = true
-    }
-    return restartLoop
+// DataSource is the DSN for the database file at path. Imports, the Last.fm
+// queue, enrichment and artwork all write alongside requests, so a writer
+// waits for the lock instead of failing with SQLITE_BUSY, and transactions
+// take the lock as they begin so two can't deadlock upgrading from a read.
+func DataSource(path string) string {
+    return path + "?_pragma=busy_timeout(5000)&_txlock=immediate"
 }
 
 func Run(config Config) error {
+    keys, err := loadKeySet(config)
+    if err != nil {
+        return err
+    }
+
     cfg := &AppCfg{
         config: config,
         listenInterval: *time.NewTicker(5 * time.Second),
@@ -275,10 +363,13 @@ func Run(config Config) error {
         },
         subscribers: make(map[int64]Subscriber), 
         scrobbles: make(chan ScrobblePack, 100),
+        nowPlaying: NewNowPlayingTracker(),
+        keys: keys,
+        mailer: newMailer(config),
     }
 
     cwd, _ := os.Getwd();
-    db, err := sql.Open("sqlite", filepath.Join(cwd, config.Data.Path))
+    db, err := sql.Open("sqlite", DataSource(filepath.Join(cwd, config.Data.Path)))
     if err != nil {
         return err
     }
@@ -293,6 +384,11 @@ func Run(config Config) error {
     }
 
     cfg.database = database.New(db)
+    cfg.conn = db
+    cfg.supervisor = NewSupervisor(cfg)
+    cfg.lastfmImporter = NewLastFMImporter(LastFMConfig(config.LastFM), cfg.conn, cfg.database)
+    cfg.artwork = NewArtworkService(config, cfg.database)
+    cfg.enricher = NewMusicBrainzEnricher(musicbrainz.New(config.MusicBrainz.Url, musicBrainzUserAgent(config.MusicBrainz.Contact)), cfg.database)
 
     go func() {
         StartServer(cfg)
@@ -301,44 +397,25 @@ func Run(config Config) error {
     ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
     defer stop()
 
-    done := make(chan struct{})
-    run := make(chan struct{})
-
-    // loop := func() {
-    //     log.Println("running AppLoop()")
-    //     restart := AppLoop(cfg)
-    //
-    //     if !restart {
-    //         close(done)
-    //     } else {
-    //         run <- struct{}{}
-    //     }
-    // }
-    //
-    // go loop()
+    go cfg.supervisor.Run(ctx)
+    go cfg.lastfmImporter.Run(ctx)
+    go cfg.enricher.Run(ctx)
+    go cfg.artwork.Run(ctx)
+    go cfg.purgeSessions(ctx)
+
+    lastfm := NewLastFMSubscriber(LastFMConfig(config.LastFM), cfg.database)
+    cfg.Register(lastfm)
+    go lastfm.Run(ctx)
 
     for {
         select {
         case pack := <- cfg.scrobbles:
-            scrobble := pack.Scrobble
-            username := pack.Username
-            user, _ := cfg.database.GetUser(context.Background(), username)
-            scrobbler := NewScrobbler(username, cfg.database)
-            scrobble.Uid = int(user.ID)
-
-            if ok := scrobbler.Scrobble(context.Background(), scrobble); ok {
-                log.Printf("SCROBBLED: %s - %s\n", scrobble.ArtistName, scrobble.TrackName)
-                cfg.Notify(scrobble, username)
+            if _, err := cfg.processScrobble(context.Background(), pack); err != nil {
+                log.Printf("processing scrobble for %s: %s\n", pack.Username, err)
             }
         case <- ctx.Done():
             log.Println("terminating Run()")
             return nil
-        case <-done:
-            log.Println("AppLoop() complete")
-            return nil
-        case <-run:
-            log.Println("restart AppLoop()")
-            // go loop()
         }
     }
 }
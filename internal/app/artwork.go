@@ -0,0 +1,405 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cg219/nowplaying/internal/database"
+)
+
+const (
+    ARTWORK_ARTIST = "artist"
+    ARTWORK_ALBUM = "album"
+    ARTWORK_TRACK = "track"
+    ARTWORK_PENDING = "pending"
+    ARTWORK_FOUND = "found"
+    ARTWORK_MISSING = "missing"
+    artworkBatchSize = 50
+    // Discogs allows 60 authenticated requests a minute; every outbound
+    // request, searches and downloads alike, waits this long after the last.
+    artworkFetchInterval = time.Second
+    // Artwork nobody had is looked for again after this long.
+    artworkRetryAfter = time.Hour * 24 * 7
+    artworkRetryInterval = time.Hour
+    maxArtworkBytes = 1 << 20
+    discogsSearchURL = "https://api.discogs.com/database/search"
+    coverArtArchiveURL = "https://coverartarchive.org"
+)
+
+type ArtworkKey struct {
+    Kind string
+    Artist string
+    Name string
+}
+
+// ArtworkProvider finds an image for an artist, album or track. Find returns
+// an empty URL when the provider has nothing for the key.
+type ArtworkProvider interface {
+    Name() string
+    Find(ctx context.Context, key ArtworkKey) (string, error)
+}
+
+// ArtworkService keeps images for artists, albums and tracks in the artwork
+// table. Pages only ever read from the table; anything missing is queued and
+// fetched in the background one request at a time, trying each provider in
+// order. Misses are stored too and only retried after artworkRetryAfter.
+type ArtworkService struct {
+    db *database.Queries
+    providers []ArtworkProvider
+    client *http.Client
+    last time.Time
+    changes chan struct{}
+}
+
+func NewArtworkService(config Config, db *database.Queries) *ArtworkService {
+    client := &http.Client{ Timeout: time.Second * 10 }
+    providers := []ArtworkProvider{}
+
+    if config.Discogs.Key != "" {
+        providers = append(providers, &discogsProvider{ url: discogsSearchURL, key: config.Discogs.Key, secret: config.Discogs.Secret, client: client })
+    }
+
+    providers = append(providers, &coverArtArchiveProvider{ db: db })
+
+    return &ArtworkService{
+        db: db,
+        providers: providers,
+        client: client,
+        changes: make(chan struct{}, 1),
+    }
+}
+
+// Image returns the local URL for a cached image, or "" when there isn't one
+// yet. Keys that have never been seen are queued for the worker, and the
+// worker is woken for ones still waiting.
+func (a *ArtworkService) Image(ctx context.Context, kind string, artist string, name string) string {
+    if artist == "" {
+        return ""
+    }
+
+    row, err := a.db.GetArtwork(ctx, database.GetArtworkParams{ Kind: kind, ArtistName: artist, Name: name })
+    if err == nil {
+        if row.Status == ARTWORK_FOUND {
+            return fmt.Sprintf("/img/%d", row.ID)
+        }
+
+        if row.Status == ARTWORK_PENDING {
+            a.Poke()
+        }
+
+        return ""
+    }
+
+    if err != sql.ErrNoRows {
+        log.Printf("Artwork: %s %s - %s: %s\n", kind, artist, name, err)
+        return ""
+    }
+
+    if err := a.db.QueueArtwork(ctx, database.QueueArtworkParams{ Kind: kind, ArtistName: artist, Name: name }); err != nil {
+        log.Printf("Artwork: queueing %s %s - %s: %s\n", kind, artist, name, err)
+        return ""
+    }
+
+    a.Poke()
+    return ""
+}
+
+// Poke wakes the worker up to fetch queued artwork. Calls never block.
+func (a *ArtworkService) Poke() {
+    select {
+    case a.changes <- struct{}{}:
+    default:
+    }
+}
+
+func (a *ArtworkService) Run(ctx context.Context) {
+    ticker := time.NewTicker(artworkRetryInterval)
+    defer ticker.Stop()
+
+    a.fetchPending(ctx)
+
+    for {
+        select {
+        case <- ctx.Done():
+            log.Println("Exiting Artwork Service")
+            return
+        case <- a.changes:
+            a.fetchPending(ctx)
+        case <- ticker.C:
+            a.fetchPending(ctx)
+        }
+    }
+}
+
+func (a *ArtworkService) fetchPending(ctx context.Context) {
+    for {
+        rows, err := a.db.GetPendingArtwork(ctx, database.GetPendingArtworkParams{
+            FetchedAt: time.Now().Add(-artworkRetryAfter).UnixMilli(),
+            Limit: artworkBatchSize,
+        })
+
+        if err != nil {
+            log.Printf("Artwork: loading queue: %s\n", err)
+            return
+        }
+
+        if len(rows) == 0 {
+            return
+        }
+
+        for _, row := range rows {
+            if err := a.fetch(ctx, row); err != nil {
+                if ctx.Err() == nil {
+                    log.Printf("Artwork: %s %s - %s: %s\n", row.Kind, row.ArtistName, row.Name, err)
+                }
+
+                return
+            }
+        }
+    }
+}
+
+// fetch tries a seeded URL first (Spotify hands us album art while polling)
+// and then each provider until one has an image that downloads. The row is
+// always saved so it leaves the pending queue either way.
+func (a *ArtworkService) fetch(ctx context.Context, row database.GetPendingArtworkRow) error {
+    key := ArtworkKey{ Kind: row.Kind, Artist: row.ArtistName, Name: row.Name }
+    params := database.SaveArtworkParams{ ID: row.ID, Status: ARTWORK_MISSING }
+
+    type candidate struct {
+        source string
+        url string
+        provider ArtworkProvider
+    }
+
+    candidates := []candidate{}
+    if row.Url.String != "" {
+        candidates = append(candidates, candidate{ source: row.Source.String, url: row.Url.String })
+    }
+
+    for _, provider := range a.providers {
+        candidates = append(candidates, candidate{ source: provider.Name(), provider: provider })
+    }
+
+    for _, c := range candidates {
+        if c.provider != nil {
+            if err := a.wait(ctx); err != nil {
+                return err
+            }
+
+            found, err := c.provider.Find(ctx, key)
+            if err != nil {
+                log.Printf("Artwork: %s search for %s - %s: %s\n", c.source, key.Artist, key.Name, err)
+                continue
+            }
+
+            c.url = found
+        }
+
+        if c.url == "" {
+            continue
+        }
+
+        image, contentType, err := a.download(ctx, c.url)
+        if err != nil {
+            log.Printf("Artwork: downloading %s: %s\n", c.url, err)
+            continue
+        }
+
+        params.Status = ARTWORK_FOUND
+        params.Source = sql.NullString{ String: c.source, Valid: true }
+        params.Url = sql.NullString{ String: c.url, Valid: true }
+        params.Image = image
+        params.ContentType = sql.NullString{ String: contentType, Valid: true }
+        break
+    }
+
+    if ctx.Err() != nil {
+        return ctx.Err()
+    }
+
+    params.FetchedAt = time.Now().UnixMilli()
+    return a.db.SaveArtwork(ctx, params)
+}
+
+func (a *ArtworkService) download(ctx context.Context, imageURL string) ([]byte, string, error) {
+    if err := a.wait(ctx); err != nil {
+        return nil, "", err
+    }
+
+    req, err := http.NewRequestWithContext(ctx, "GET", imageURL, nil)
+    if err != nil {
+        return nil, "", err
+    }
+
+    req.Header.Set("User-Agent", "nowplayingapp 0.1 / mentemusic.com")
+
+    res, err := a.client.Do(req)
+    if err != nil {
+        return nil, "", err
+    }
+
+    defer res.Body.Close()
+
+    if res.StatusCode != http.StatusOK {
+        return nil, "", fmt.Errorf("unexpected status %d", res.StatusCode)
+    }
+
+    contentType := res.Header.Get("Content-Type")
+    if !strings.HasPrefix(contentType, "image/") {
+        return nil, "", fmt.Errorf("not an image: %s", contentType)
+    }
+
+    image, err := io.ReadAll(io.LimitReader(res.Body, maxArtworkBytes + 1))
+    if err != nil {
+        return nil, "", err
+    }
+
+    if len(image) > maxArtworkBytes {
+        return nil, "", fmt.Errorf("image larger than %d bytes", maxArtworkBytes)
+    }
+
+    return image, contentType, nil
+}
+
+// wait spaces outbound requests artworkFetchInterval apart. Only the worker
+// goroutine makes requests so no locking is needed.
+func (a *ArtworkService) wait(ctx context.Context) error {
+    if delay := time.Until(a.last.Add(artworkFetchInterval)); delay > 0 {
+        select {
+        case <- ctx.Done():
+            return ctx.Err()
+        case <- time.After(delay):
+        }
+    }
+
+    a.last = time.Now()
+    return nil
+}
+
+// seedArtwork records an image URL a source already gave us so the worker can
+// download it without searching. Artwork that was already found is left alone.
+func seedArtwork(ctx context.Context, db *database.Queries, kind string, artist string, name string, source string, imageURL string) error {
+    if artist == "" || imageURL == "" {
+        return nil
+    }
+
+    return db.SeedArtwork(ctx, database.SeedArtworkParams{
+        Kind: kind,
+        ArtistName: artist,
+        Name: name,
+        Source: sql.NullString{ String: source, Valid: true },
+        Url: sql.NullString{ String: imageURL, Valid: true },
+    })
+}
+
+type discogsProvider struct {
+    url string
+    key string
+    secret string
+    client *http.Client
+}
+
+func (p *discogsProvider) Name() string {
+    return "discogs"
+}
+
+func (p *discogsProvider) Find(ctx context.Context, key ArtworkKey) (string, error) {
+    type DiscogResp struct {
+        Results []struct {
+            Title string `json:"title"`
+            Thumb string `json:"thumb"`
+        } `json:"results"`
+    }
+
+    params := url.Values{}
+    params.Set("key", p.key)
+    params.Set("secret", p.secret)
+
+    switch key.Kind {
+    case ARTWORK_ARTIST:
+        params.Set("q", key.Artist)
+        params.Set("type", "artist")
+    case ARTWORK_ALBUM:
+        params.Set("artist", key.Artist)
+        params.Set("release_title", key.Name)
+        params.Set("type", "release")
+    default:
+        params.Set("artist", key.Artist)
+        params.Set("track", key.Name)
+        params.Set("type", "release")
+    }
+
+    req, err := http.NewRequestWithContext(ctx, "GET", p.url + "?" + params.Encode(), nil)
+    if err != nil {
+        return "", err
+    }
+
+    req.Header.Set("User-Agent", "nowplayingapp 0.1 / mentemusic.com")
+
+    res, err := p.client.Do(req)
+    if err != nil {
+        return "", err
+    }
+
+    defer res.Body.Close()
+
+    if res.StatusCode != http.StatusOK {
+        return "", fmt.Errorf("unexpected status %d", res.StatusCode)
+    }
+
+    var data DiscogResp
+    if err := json.NewDecoder(res.Body).Decode(&data); err != nil {
+        return "", fmt.Errorf("decoding: %w", err)
+    }
+
+    for _, result := range data.Results {
+        if result.Thumb != "" && !strings.HasSuffix(result.Thumb, "spacer.gif") {
+            return result.Thumb, nil
+        }
+    }
+
+    return "", nil
+}
+
+// coverArtArchiveProvider uses the release MBIDs filled in by the MusicBrainz
+// enricher. It has no artist images.
+type coverArtArchiveProvider struct {
+    db *database.Queries
+}
+
+func (p *coverArtArchiveProvider) Name() string {
+    return "coverartarchive"
+}
+
+func (p *coverArtArchiveProvider) Find(ctx context.Context, key ArtworkKey) (string, error) {
+    var mbid sql.NullString
+    var err error
+
+    switch key.Kind {
+    case ARTWORK_ALBUM:
+        mbid, err = p.db.GetAlbumMbid(ctx, database.GetAlbumMbidParams{ ArtistName: key.Artist, AlbumName: sql.NullString{ String: key.Name, Valid: true } })
+    case ARTWORK_TRACK:
+        mbid, err = p.db.GetTrackAlbumMbid(ctx, database.GetTrackAlbumMbidParams{ ArtistName: key.Artist, TrackName: key.Name })
+    default:
+        return "", nil
+    }
+
+    if err == sql.ErrNoRows {
+        return "", nil
+    }
+
+    if err != nil {
+        return "", err
+    }
+
+    return fmt.Sprintf("%s/release/%s/front-250", coverArtArchiveURL, mbid.String), nil
+}
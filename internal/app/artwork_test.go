@@ -0,0 +1,48 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDiscogsProvider(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        q := r.URL.Query()
+
+        switch {
+        case q.Get("type") == "artist" && q.Get("q") == "Sade":
+            fmt.Fprint(w, `{ "results": [{ "title": "Sade", "thumb": "https://img.discogs.test/spacer.gif" }, { "title": "Sade (2)", "thumb": "https://img.discogs.test/sade.jpg" }] }`)
+        case q.Get("type") == "release" && q.Get("artist") == "Sade" && q.Get("release_title") == "Diamond Life":
+            fmt.Fprint(w, `{ "results": [{ "title": "Sade - Diamond Life", "thumb": "https://img.discogs.test/diamond-life.jpg" }] }`)
+        default:
+            fmt.Fprint(w, `{ "results": [] }`)
+        }
+    }))
+
+    defer srv.Close()
+
+    p := &discogsProvider{ url: srv.URL, key: "key", secret: "secret", client: srv.Client() }
+
+    tests := []struct {
+        key ArtworkKey
+        want string
+    }{
+        { ArtworkKey{ Kind: ARTWORK_ARTIST, Artist: "Sade" }, "https://img.discogs.test/sade.jpg" },
+        { ArtworkKey{ Kind: ARTWORK_ALBUM, Artist: "Sade", Name: "Diamond Life" }, "https://img.discogs.test/diamond-life.jpg" },
+        { ArtworkKey{ Kind: ARTWORK_TRACK, Artist: "Sade", Name: "Smooth Operator" }, "" },
+    }
+
+    for _, tt := range tests {
+        got, err := p.Find(context.Background(), tt.key)
+        if err != nil {
+            t.Fatalf("%+v: %s", tt.key, err)
+        }
+
+        if got != tt.want {
+            t.Errorf("%+v: got %q, want %q", tt.key, got, tt.want)
+        }
+    }
+}
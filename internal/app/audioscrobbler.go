@@ -0,0 +1,362 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error codes from the Last.fm 2.0 API that clients know how to handle.
+const (
+    AS_INVALID_METHOD = 3
+    AS_AUTH_FAILED = 4
+    AS_INVALID_PARAMETERS = 6
+    AS_INVALID_SESSION = 9
+    AS_INVALID_API_KEY = 10
+    AS_INVALID_SIGNATURE = 13
+    AS_RATE_LIMITED = 29
+    // Ignored message codes. Last.fm has none for plays that fail a rule or
+    // were already scrobbled, so those use its generic "track ignored".
+    AS_IGNORED_TRACK = 2
+    AS_SOURCE = "audioscrobbler"
+    maxAudioscrobblerBatch = 50
+    asLoginWindow = time.Minute * 15
+    asLoginIPLimit = 20
+    asLoginUserLimit = 5
+)
+
+type asResponse struct {
+    XMLName xml.Name `xml:"lfm" json:"-"`
+    Status string `xml:"status,attr" json:"-"`
+    Session *asSession `xml:"session,omitempty" json:"session,omitempty"`
+    Scrobbles *asScrobbles `xml:"scrobbles,omitempty" json:"scrobbles,omitempty"`
+    NowPlaying *asScrobbleResult `xml:"nowplaying,omitempty" json:"nowplaying,omitempty"`
+}
+
+type asError struct {
+    XMLName xml.Name `xml:"lfm" json:"-"`
+    Status string `xml:"status,attr" json:"-"`
+    Error struct {
+        Code int `xml:"code,attr"`
+        Message string `xml:",chardata"`
+    } `xml:"error" json:"-"`
+    Code int `xml:"-" json:"error"`
+    Message string `xml:"-" json:"message"`
+}
+
+type asSession struct {
+    Name string `xml:"name" json:"name"`
+    Key string `xml:"key" json:"key"`
+    Subscriber int `xml:"subscriber" json:"subscriber"`
+}
+
+type asScrobbles struct {
+    Accepted int `xml:"accepted,attr" json:"-"`
+    Ignored int `xml:"ignored,attr" json:"-"`
+    Scrobble []asScrobbleResult `xml:"scrobble" json:"scrobble"`
+    Attr struct {
+        Accepted int `json:"accepted"`
+        Ignored int `json:"ignored"`
+    } `xml:"-" json:"@attr"`
+}
+
+type asCorrected struct {
+    Corrected string `xml:"corrected,attr" json:"corrected"`
+    Text string `xml:",chardata" json:"#text"`
+}
+
+type asScrobbleResult struct {
+    Track asCorrected `xml:"track" json:"track"`
+    Artist asCorrected `xml:"artist" json:"artist"`
+    Album asCorrected `xml:"album" json:"album"`
+    AlbumArtist asCorrected `xml:"albumArtist" json:"albumArtist"`
+    Timestamp string `xml:"timestamp,omitempty" json:"timestamp,omitempty"`
+    IgnoredMessage struct {
+        Code string `xml:"code,attr" json:"code"`
+        Text string `xml:",chardata" json:"#text"`
+    } `xml:"ignoredMessage" json:"ignoredMessage"`
+}
+
+// Audioscrobbler serves the subset of the Last.fm 2.0 API that scrobbling
+// clients use, so they can be pointed at this server instead of Last.fm.
+func (s *Server) Audioscrobbler(w http.ResponseWriter, r *http.Request) error {
+    if err := r.ParseForm(); err != nil {
+        s.asFail(w, r, AS_INVALID_PARAMETERS, "Invalid parameters")
+        return nil
+    }
+
+    config := s.authCfg.config.Audioscrobbler
+    if config.Key != "" && r.Form.Get("api_key") != config.Key {
+        s.asFail(w, r, AS_INVALID_API_KEY, "Invalid API key - You must be granted a valid key by last.fm")
+        return nil
+    }
+
+    if !validAudioscrobblerSignature(r, config.Secret) {
+        s.asFail(w, r, AS_INVALID_SIGNATURE, "Invalid method signature supplied")
+        return nil
+    }
+
+    switch strings.ToLower(r.Form.Get("method")) {
+    case "auth.getmobilesession":
+        return s.asGetMobileSession(w, r)
+    case "track.scrobble":
+        return s.asScrobble(w, r)
+    case "track.updatenowplaying":
+        return s.asUpdateNowPlaying(w, r)
+    }
+
+    s.asFail(w, r, AS_INVALID_METHOD, "Invalid Method - No method with that name in this package")
+    return nil
+}
+
+// asGetMobileSession logs a client in with the user's password. It has to be
+// a POST so the password never ends up in a url, and attempts are rate
+// limited by ip and username. Each client gets one key per user: logging in
+// again replaces it rather than adding another.
+func (s *Server) asGetMobileSession(w http.ResponseWriter, r *http.Request) error {
+    if r.Method != http.MethodPost {
+        s.asFail(w, r, AS_INVALID_METHOD, "Invalid Method - auth.getMobileSession must be sent as a POST")
+        return nil
+    }
+
+    username := r.PostForm.Get("username")
+    now := time.Now()
+    if !s.asLoginIPs.Allow(s.clientIP(r), now) || !s.asLoginUsers.Allow(strings.ToLower(username), now) {
+        s.asFail(w, r, AS_RATE_LIMITED, "Rate limit exceeded - Your IP has made too many requests in a short period")
+        return nil
+    }
+
+    if !s.login(r.Context(), username, r.PostForm.Get("password")) {
+        s.asFail(w, r, AS_AUTH_FAILED, "Authentication Failed - You do not have permissions to access the service")
+        return nil
+    }
+
+    user, err := s.authCfg.database.GetUser(r.Context(), username)
+    if err != nil {
+        s.log.Error("Getting User", "username", username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    name := fmt.Sprintf("Audioscrobbler (%s)", r.Form.Get("api_key"))
+    key, err := replaceAPIKey(r.Context(), s.authCfg.database, user.ID, name, []string{ SCOPE_SCROBBLE })
+    if err != nil {
+        s.log.Error("Saving Audioscrobbler Session", "username", username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    s.asOK(w, r, asResponse{ Session: &asSession{ Name: user.Username, Key: key } })
+    return nil
+}
+
+func (s *Server) asScrobble(w http.ResponseWriter, r *http.Request) error {
+    username, ok := s.asSessionUser(w, r)
+    if !ok {
+        return nil
+    }
+
+    scrobbles := audioscrobblerScrobbles(r.Form)
+    if len(scrobbles) == 0 || len(scrobbles) > maxAudioscrobblerBatch {
+        s.asFail(w, r, AS_INVALID_PARAMETERS, "Invalid parameters - Your request is missing a required parameter")
+        return nil
+    }
+
+    result := &asScrobbles{}
+
+    for _, sc := range scrobbles {
+        res, err := s.authCfg.processScrobble(r.Context(), ScrobblePack{ Scrobble: sc, Username: username, Import: true })
+        if err != nil {
+            s.log.Error("Audioscrobbler Scrobble", "username", username, "error", err)
+            return fmt.Errorf(INTERNAL_ERROR)
+        }
+
+        item := asScrobbleFor(sc)
+        item.Timestamp = strconv.Itoa(sc.Timestamp / 1000)
+
+        if res.Status == SCROBBLE_ACCEPTED {
+            result.Accepted++
+        } else {
+            item.IgnoredMessage.Code = strconv.Itoa(AS_IGNORED_TRACK)
+            item.IgnoredMessage.Text = res.Reason
+            result.Ignored++
+        }
+
+        result.Scrobble = append(result.Scrobble, item)
+    }
+
+    result.Attr.Accepted = result.Accepted
+    result.Attr.Ignored = result.Ignored
+    s.asOK(w, r, asResponse{ Scrobbles: result })
+    return nil
+}
+
+func (s *Server) asUpdateNowPlaying(w http.ResponseWriter, r *http.Request) error {
+    username, ok := s.asSessionUser(w, r)
+    if !ok {
+        return nil
+    }
+
+    sc, ok := audioscrobblerScrobble(r.Form, "", false)
+    if !ok {
+        s.asFail(w, r, AS_INVALID_PARAMETERS, "Invalid parameters - Your request is missing a required parameter")
+        return nil
+    }
+
+    sc.Timestamp = int(time.Now().UnixMilli())
+    sc.Progress = 0
+    s.authCfg.scrobbles <- ScrobblePack{ Scrobble: sc, Username: username, NowPlaying: true }
+
+    item := asScrobbleFor(sc)
+    s.asOK(w, r, asResponse{ NowPlaying: &item })
+    return nil
+}
+
+func (s *Server) asSessionUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+    sk := r.Form.Get("sk")
+    if sk == "" {
+        s.asFail(w, r, AS_INVALID_SESSION, "Invalid session key - Please re-authenticate")
+        return "", false
+    }
+
+    username, err := s.apiKeyUser(r.Context(), sk, SCOPE_SCROBBLE)
+    if err != nil {
+        if err != sql.ErrNoRows && err != errAPIKeyScope {
+            s.log.Error("Audioscrobbler Session Lookup", "error", err)
+        }
+
+        s.asFail(w, r, AS_INVALID_SESSION, "Invalid session key - Please re-authenticate")
+        return "", false
+    }
+
+    return username, true
+}
+
+func (s *Server) asOK(w http.ResponseWriter, r *http.Request, resp asResponse) {
+    resp.Status = "ok"
+    writeAudioscrobbler(w, r, http.StatusOK, resp)
+}
+
+func (s *Server) asFail(w http.ResponseWriter, r *http.Request, code int, message string) {
+    resp := asError{ Status: "failed", Code: code, Message: message }
+    resp.Error.Code = code
+    resp.Error.Message = message
+
+    status := http.StatusBadRequest
+    if code == AS_RATE_LIMITED {
+        status = http.StatusTooManyRequests
+    } else if code == AS_AUTH_FAILED || code == AS_INVALID_SESSION || code == AS_INVALID_API_KEY || code == AS_INVALID_SIGNATURE {
+        status = http.StatusForbidden
+    }
+
+    writeAudioscrobbler(w, r, status, resp)
+}
+
+// writeAudioscrobbler answers in XML like Last.fm does unless the client asked
+// for format=json.
+func writeAudioscrobbler(w http.ResponseWriter, r *http.Request, status int, v any) {
+    if r.Form.Get("format") == "json" {
+        encode(w, status, v)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+    w.WriteHeader(status)
+    w.Write([]byte(xml.Header))
+    xml.NewEncoder(w).Encode(v)
+}
+
+// validAudioscrobblerSignature checks api_sig over every parameter except
+// format and callback, which Last.fm leaves out of the signature too.
+func validAudioscrobblerSignature(r *http.Request, secret string) bool {
+    list := []apiParam{}
+
+    for name, values := range r.Form {
+        if name == "api_sig" || name == "format" || name == "callback" || len(values) == 0 {
+            continue
+        }
+
+        list = append(list, apiParam{ Name: name, Value: values[0] })
+    }
+
+    return strings.EqualFold(lastFMSignature(list, secret), r.Form.Get("api_sig"))
+}
+
+// audioscrobblerScrobbles reads a track.scrobble request, which is either a
+// batch of artist[0], track[0]... parameters or a single unindexed scrobble.
+func audioscrobblerScrobbles(form map[string][]string) []Scrobble {
+    scrobbles := []Scrobble{}
+
+    for i := 0; i < maxAudioscrobblerBatch + 1; i++ {
+        sc, ok := audioscrobblerScrobble(form, fmt.Sprintf("[%d]", i), true)
+        if !ok {
+            break
+        }
+
+        scrobbles = append(scrobbles, sc)
+    }
+
+    if len(scrobbles) == 0 {
+        if sc, ok := audioscrobblerScrobble(form, "", true); ok {
+            scrobbles = append(scrobbles, sc)
+        }
+    }
+
+    return scrobbles
+}
+
+func audioscrobblerScrobble(form map[string][]string, suffix string, needsTimestamp bool) (Scrobble, bool) {
+    get := func(name string) string {
+        if values := form[name + suffix]; len(values) > 0 {
+            return strings.TrimSpace(values[0])
+        }
+
+        return ""
+    }
+
+    sc := Scrobble{
+        ArtistName: get("artist"),
+        TrackName: get("track"),
+        AlbumName: get("album"),
+        AlbumArtist: get("albumArtist"),
+        TrackNumber: get("trackNumber"),
+        Mbid: get("mbid"),
+        Source: AS_SOURCE,
+    }
+
+    if sc.ArtistName == "" || sc.TrackName == "" {
+        return sc, false
+    }
+
+    if sc.AlbumArtist == "" {
+        sc.AlbumArtist = sc.ArtistName
+    }
+
+    if seconds, err := strconv.Atoi(get("duration")); err == nil {
+        sc.Duration = seconds * 1000
+        sc.Progress = sc.Duration
+    }
+
+    seconds, err := strconv.ParseInt(get("timestamp"), 10, 64)
+    if err != nil && needsTimestamp {
+        return sc, false
+    }
+
+    sc.Timestamp = int(seconds * 1000)
+
+    return sc, true
+}
+
+func asScrobbleFor(sc Scrobble) asScrobbleResult {
+    item := asScrobbleResult{
+        Track: asCorrected{ Corrected: "0", Text: sc.TrackName },
+        Artist: asCorrected{ Corrected: "0", Text: sc.ArtistName },
+        Album: asCorrected{ Corrected: "0", Text: sc.AlbumName },
+        AlbumArtist: asCorrected{ Corrected: "0", Text: sc.AlbumArtist },
+    }
+
+    item.IgnoredMessage.Code = "0"
+    return item
+}
@@ -0,0 +1,189 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"net/http"
+	"net/mail"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/cg219/nowplaying/internal/database"
+	"github.com/cg219/nowplaying/pkg/mailer"
+)
+
+const (
+    emailTokenLength = 32
+    emailVerificationTime = time.Hour * 24
+    emailSendTimeout = time.Minute
+)
+
+//go:embed templates/email
+var emailFiles embed.FS
+
+var (
+    emailText = template.Must(template.ParseFS(emailFiles, "templates/email/*.txt"))
+    emailHTML = htmltemplate.Must(htmltemplate.ParseFS(emailFiles, "templates/email/*.html"))
+    errNoPublicURL = errors.New("APP_URL is not set")
+)
+
+type EmailData struct {
+    Username string
+    Url string
+    Expires string
+}
+
+// renderEmail builds a message from the name.txt and name.html templates.
+func renderEmail(to string, subject string, name string, data EmailData) (mailer.Message, error) {
+    var text, html bytes.Buffer
+
+    if err := emailText.ExecuteTemplate(&text, name + ".txt", data); err != nil {
+        return mailer.Message{}, err
+    }
+
+    if err := emailHTML.ExecuteTemplate(&html, name + ".html", data); err != nil {
+        return mailer.Message{}, err
+    }
+
+    return mailer.Message{ To: to, Subject: subject, Text: text.String(), HTML: html.String() }, nil
+}
+
+// validEmail accepts a bare address, not a display name form.
+func validEmail(email string) bool {
+    addr, err := mail.ParseAddress(email)
+    return err == nil && addr.Address == email
+}
+
+// sendMail delivers in the background so a slow relay doesn't hold up the
+// request; failures are only logged.
+func (s *Server) sendMail(msg mailer.Message) {
+    go func() {
+        ctx, cancel := context.WithTimeout(context.Background(), emailSendTimeout)
+        defer cancel()
+
+        if err := s.authCfg.mailer.Send(ctx, msg); err != nil {
+            s.log.Error("Sending Mail", "subject", msg.Subject, "error", err)
+        }
+    }()
+}
+
+// publicURL is where links in emails point. It only comes from the configured
+// app url: the request's Host is up to whoever sends it, and a link built from
+// it could hand a reset token to someone else's server.
+func (s *Server) publicURL(path string) (string, error) {
+    base := s.authCfg.config.Data.Url
+    if base == "" {
+        return "", errNoPublicURL
+    }
+
+    return strings.TrimSuffix(base, "/") + path, nil
+}
+
+// verifyEmail saves an unverified address for the user and mails them a link
+// to confirm it. Only a hash of the token is stored.
+func (s *Server) verifyEmail(r *http.Request, uid int64, username string, email string) error {
+    token, err := randomString(emailTokenLength)
+    if err != nil {
+        return err
+    }
+
+    link, err := s.publicURL("/verify/" + token)
+    if err != nil {
+        return err
+    }
+
+    err = s.authCfg.database.SetUserEmail(r.Context(), database.SetUserEmailParams{
+        Email: sql.NullString{ String: email, Valid: true },
+        EmailVerification: sql.NullString{ String: hashToken(token), Valid: true },
+        EmailVerificationTime: sql.NullInt64{ Int64: time.Now().Add(emailVerificationTime).UnixMilli(), Valid: true },
+        ID: uid,
+    })
+
+    if err != nil {
+        return err
+    }
+
+    msg, err := renderEmail(email, "Confirm your email", "verify", EmailData{
+        Username: username,
+        Url: link,
+        Expires: "24 hours",
+    })
+
+    if err != nil {
+        return err
+    }
+
+    s.sendMail(msg)
+    return nil
+}
+
+// SetEmail changes the user's address and sends a new verification link;
+// posting the same address again resends it. An empty address removes it.
+func (s *Server) SetEmail(w http.ResponseWriter, r *http.Request) error {
+    type Body struct {
+        Email string `json:"email"`
+    }
+
+    user, err := s.currentUser(r)
+    if err != nil {
+        return err
+    }
+
+    body, err := decode[Body](r)
+    if err != nil {
+        return fmt.Errorf(BAD_REQUEST_ERROR)
+    }
+
+    email := strings.TrimSpace(body.Email)
+    if email == "" {
+        if err := s.authCfg.database.SetUserEmail(r.Context(), database.SetUserEmailParams{ ID: user.ID }); err != nil {
+            s.log.Error("Removing Email", "username", user.Username, "error", err)
+            return fmt.Errorf(INTERNAL_ERROR)
+        }
+
+        encode(w, http.StatusOK, SuccessResp{ Success: true })
+        return nil
+    }
+
+    if !validEmail(email) {
+        return fmt.Errorf(BAD_REQUEST_ERROR)
+    }
+
+    if err := s.verifyEmail(r, user.ID, user.Username, email); err != nil {
+        s.log.Error("Setting Email", "username", user.Username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    encode(w, http.StatusOK, SuccessResp{ Success: true })
+    return nil
+}
+
+// ConfirmEmail is the link sent by verifyEmail. It sends the browser back to
+// Settings with the outcome in the query string.
+func (s *Server) ConfirmEmail(w http.ResponseWriter, r *http.Request) error {
+    now := time.Now().UnixMilli()
+    verified, err := s.authCfg.database.VerifyEmail(r.Context(), database.VerifyEmailParams{
+        VerifiedAt: sql.NullInt64{ Int64: now, Valid: true },
+        Token: sql.NullString{ String: hashToken(r.PathValue("token")), Valid: true },
+        Now: sql.NullInt64{ Int64: now, Valid: true },
+    })
+
+    if err != nil {
+        s.log.Error("Verifying Email", "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    status := "verified"
+    if verified == 0 {
+        status = "invalid"
+    }
+
+    http.Redirect(w, r, "/settings?email=" + status, http.StatusSeeOther)
+    return nil
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderEmail(t *testing.T) {
+    msg, err := renderEmail("tester@example.com", "Reset your password", "reset", EmailData{
+        Username: "tester",
+        Url: "https://example.com/reset/abc?x=1&y=2",
+        Expires: "15 minutes",
+    })
+
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if msg.To != "tester@example.com" || msg.Subject != "Reset your password" {
+        t.Errorf("header: got %s, %q", msg.To, msg.Subject)
+    }
+
+    if !strings.Contains(msg.Text, "https://example.com/reset/abc?x=1&y=2") || !strings.Contains(msg.Text, "15 minutes") {
+        t.Errorf("text: got %q", msg.Text)
+    }
+
+    if !strings.Contains(msg.HTML, `href="https://example.com/reset/abc?x=1&amp;y=2"`) {
+        t.Errorf("html: got %q", msg.HTML)
+    }
+}
+
+func TestValidEmail(t *testing.T) {
+    cases := map[string]bool{
+        "tester@example.com": true,
+        "tester+np@mail.example.com": true,
+        "": false,
+        "tester": false,
+        "Tester <tester@example.com>": false,
+        "tester@example.com, other@example.com": false,
+    }
+
+    for email, want := range cases {
+        if got := validEmail(email); got != want {
+            t.Errorf("validEmail(%q): got %t, want %t", email, got, want)
+        }
+    }
+}
+
+func TestPublicURL(t *testing.T) {
+    s := &Server{ authCfg: &AppCfg{} }
+
+    if _, err := s.publicURL("/reset/abc"); err != errNoPublicURL {
+        t.Errorf("without an app url: got %v", err)
+    }
+
+    s.authCfg.config.Data.Url = "https://np.example.com/"
+    if link, err := s.publicURL("/reset/abc"); err != nil || link != "https://np.example.com/reset/abc" {
+        t.Errorf("got %q, %v", link, err)
+    }
+}
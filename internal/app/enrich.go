@@ -0,0 +1,204 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/cg219/nowplaying/internal/database"
+	"github.com/cg219/nowplaying/pkg/musicbrainz"
+)
+
+const (
+    enrichBatchSize = 100
+    // How often the whole table is walked again for scrobbles still missing IDs.
+    enrichRescanInterval = time.Hour * 6
+    // Lookups that found nothing are tried again after this long.
+    lookupRetryAfter = time.Hour * 24 * 30
+)
+
+// MusicBrainzEnricher fills in recording, release and artist MBIDs on
+// scrobbles that don't have them, and the track length on those saved without
+// one, like Last.fm imports. Lookups are cached in musicbrainz_lookups by
+// artist, track and album so a song played a hundred times only costs one
+// request, and misses are cached too so they aren't asked about every pass.
+type MusicBrainzEnricher struct {
+    client *musicbrainz.Client
+    db *database.Queries
+    cursor int64
+    changes chan struct{}
+    mu sync.Mutex
+}
+
+func NewMusicBrainzEnricher(client *musicbrainz.Client, db *database.Queries) *MusicBrainzEnricher {
+    return &MusicBrainzEnricher{
+        client: client,
+        db: db,
+        changes: make(chan struct{}, 1),
+    }
+}
+
+func musicBrainzUserAgent(contact string) string {
+    if contact == "" {
+        return "nowplaying/1.0"
+    }
+
+    return fmt.Sprintf("nowplaying/1.0 ( %s )", contact)
+}
+
+// Poke tells the enricher new scrobbles were saved. Calls never block and
+// only pick up scrobbles past the last one it looked at.
+func (e *MusicBrainzEnricher) Poke() {
+    select {
+    case e.changes <- struct{}{}:
+    default:
+    }
+}
+
+func (e *MusicBrainzEnricher) Run(ctx context.Context) {
+    ticker := time.NewTicker(enrichRescanInterval)
+    defer ticker.Stop()
+
+    e.enrich(ctx)
+
+    for {
+        select {
+        case <- ctx.Done():
+            log.Println("Exiting MusicBrainz Enricher")
+            return
+        case <- e.changes:
+            e.enrich(ctx)
+        case <- ticker.C:
+            e.mu.Lock()
+            e.cursor = 0
+            e.mu.Unlock()
+
+            e.enrich(ctx)
+        }
+    }
+}
+
+// enrich works through scrobbles missing an MBID or duration from the cursor
+// onwards. When
+// MusicBrainz rate limits us it waits with backoff and tries the same
+// scrobble again.
+func (e *MusicBrainzEnricher) enrich(ctx context.Context) {
+    e.mu.Lock()
+    defer e.mu.Unlock()
+
+    backoff := minRestartBackoff
+
+    for {
+        rows, err := e.db.GetScrobblesMissingMbids(ctx, database.GetScrobblesMissingMbidsParams{ ID: e.cursor, Limit: enrichBatchSize })
+        if err != nil {
+            log.Printf("MusicBrainz: loading scrobbles: %s\n", err)
+            return
+        }
+
+        if len(rows) == 0 {
+            return
+        }
+
+        for i := 0; i < len(rows); i++ {
+            row := rows[i]
+
+            err := e.enrichScrobble(ctx, row)
+            if ctx.Err() != nil {
+                return
+            }
+
+            if err == musicbrainz.ErrRateLimited {
+                log.Printf("MusicBrainz: rate limited; retrying in %s\n", backoff)
+
+                select {
+                case <- ctx.Done():
+                    return
+                case <- time.After(backoff):
+                }
+
+                backoff = nextBackoff(backoff)
+                i--
+                continue
+            }
+
+            if err != nil {
+                log.Printf("MusicBrainz: scrobble %d: %s\n", row.ID, err)
+            }
+
+            backoff = minRestartBackoff
+            e.cursor = row.ID
+        }
+    }
+}
+
+func (e *MusicBrainzEnricher) enrichScrobble(ctx context.Context, row database.GetScrobblesMissingMbidsRow) error {
+    lookup, err := e.lookup(ctx, row.ArtistName, row.TrackName, row.AlbumName.String)
+    if err != nil {
+        return err
+    }
+
+    if lookup.Found == 0 {
+        return nil
+    }
+
+    return e.db.SetScrobbleMbids(ctx, database.SetScrobbleMbidsParams{
+        Mbid: lookup.RecordingMbid,
+        AlbumMbid: lookup.ReleaseMbid,
+        ArtistMbid: lookup.ArtistMbid,
+        Duration: lookup.Length.Int64,
+        ID: row.ID,
+    })
+}
+
+// lookup returns the cached result for a track, asking MusicBrainz when there
+// is none, when a cached miss is old enough to try again, or when a hit was
+// cached before lengths were kept.
+func (e *MusicBrainzEnricher) lookup(ctx context.Context, artist string, track string, album string) (database.MusicbrainzLookup, error) {
+    cached, err := e.db.GetMusicBrainzLookup(ctx, database.GetMusicBrainzLookupParams{ ArtistName: artist, TrackName: track, AlbumName: album })
+    if err == nil {
+        if (cached.Found == 1 && cached.Length.Valid) || time.Since(time.UnixMilli(cached.LookedUpAt)) < lookupRetryAfter {
+            return cached, nil
+        }
+    } else if err != sql.ErrNoRows {
+        return cached, err
+    }
+
+    match, err := e.client.Lookup(ctx, artist, track, album)
+    if err != nil && err != musicbrainz.ErrNotFound {
+        return database.MusicbrainzLookup{}, err
+    }
+
+    params := database.SaveMusicBrainzLookupParams{
+        ArtistName: artist,
+        TrackName: track,
+        AlbumName: album,
+        RecordingMbid: sql.NullString{ String: match.RecordingId, Valid: match.RecordingId != "" },
+        ReleaseMbid: sql.NullString{ String: match.ReleaseId, Valid: match.ReleaseId != "" },
+        ArtistMbid: sql.NullString{ String: match.ArtistId, Valid: match.ArtistId != "" },
+        LookedUpAt: time.Now().UnixMilli(),
+    }
+
+    if err == nil {
+        params.Found = 1
+        params.Length = sql.NullInt64{ Int64: int64(match.Length), Valid: true }
+    }
+
+    if err := e.db.SaveMusicBrainzLookup(ctx, params); err != nil {
+        return database.MusicbrainzLookup{}, err
+    }
+
+    return database.MusicbrainzLookup{
+        ArtistName: artist,
+        TrackName: track,
+        AlbumName: album,
+        RecordingMbid: params.RecordingMbid,
+        ReleaseMbid: params.ReleaseMbid,
+        ArtistMbid: params.ArtistMbid,
+        Found: params.Found,
+        LookedUpAt: params.LookedUpAt,
+        Length: params.Length,
+    }, nil
+}
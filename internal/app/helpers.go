@@ -1,12 +1,17 @@
 package app
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
+	"math/big"
+	"net"
 	"net/http"
+	"net/netip"
 	_ "net/http/pprof"
-	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -41,134 +46,118 @@ func stringToDuration(ts string) (time.Duration, error) {
     return d, nil
 }
 
-func encode[T any](w http.ResponseWriter, status int, v T) error {
-    w.Header().Set("Content-Type", "application/json")
-    w.WriteHeader(status)
-    if err := json.NewEncoder(w).Encode(v); err != nil {
-        return fmt.Errorf("encoding: %w", err)
+// parseProxies reads the reverse proxies X-Forwarded-For is taken from: a
+// comma separated list of addresses or CIDR ranges. Entries that don't parse
+// are logged and skipped.
+func parseProxies(value string) []netip.Prefix {
+    proxies := []netip.Prefix{}
+
+    for _, entry := range strings.Split(value, ",") {
+        entry = strings.TrimSpace(entry)
+        if entry == "" {
+            continue
+        }
+
+        if prefix, err := netip.ParsePrefix(entry); err == nil {
+            proxies = append(proxies, prefix.Masked())
+        } else if addr, err := netip.ParseAddr(entry); err == nil {
+            proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+        } else {
+            log.Printf("ignoring proxy %q: not an address or range\n", entry)
+        }
     }
 
-    return nil
+    return proxies
 }
 
-func decode[T any](r *http.Request) (T, error) {
-    var v T
-    if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
-        return v, fmt.Errorf("decoding: %w", err)
+func trustedProxy(proxies []netip.Prefix, ip string) bool {
+    addr, err := netip.ParseAddr(ip)
+    if err != nil {
+        return false
     }
 
-    return v, nil
+    for _, prefix := range proxies {
+        if prefix.Contains(addr.Unmap()) {
+            return true
+        }
+    }
+
+    return false
 }
 
-func loadArtistImages(metadata []Artist, cfg Config) {
-    type DiscogResp struct {
-        Results []struct {
-            Title string `json:"title"`
-            Thumb string `json:"thumb"`
-        } `json:"results"`
+// clientIP is the address a request came from. X-Forwarded-For is only
+// believed when the connection itself comes from a configured proxy, and
+// then only back to the first hop that isn't one; anything left of that was
+// written by the client.
+func (s *Server) clientIP(r *http.Request) string {
+    ip, _, err := net.SplitHostPort(r.RemoteAddr)
+    if err != nil {
+        ip = r.RemoteAddr
     }
 
-    client := http.Client{
-        Timeout: time.Millisecond * 600,
+    if !trustedProxy(s.proxies, ip) {
+        return ip
     }
 
-    results := make(chan struct{})
-
-    for i, m := range metadata {
-        go func(m Artist) {
-            req, _ := http.NewRequest("GET", "https://api.discogs.com/database/search", nil)
-            params := url.Values{}
-            params.Set("key", cfg.Discogs.Key)
-            params.Set("secret", cfg.Discogs.Secret)
-            params.Set("q", strings.Trim(strings.Split(m.Name, ",")[0], " "))
-            params.Set("type", "artist")
-            req.Header.Set("User-Agent", "nowplayingapp 0.1 / mentemusic.com")
-            req.URL.RawQuery = params.Encode()
-            res, err := client.Do(req)
-
-            if err != nil {
-                log.Printf("Error Loading Artist Image: %s, %s", m.Name, err)
-                results <- struct{}{}
-                return
-            }
-
-            defer res.Body.Close()
-
-            var data DiscogResp
-
-            if err := json.NewDecoder(res.Body).Decode(&data); err != nil {
-                log.Printf("decoding: %s\n", err)
-                results <- struct{}{}
-                return
-            }
-
-            if len(data.Results) > 0 {
-                metadata[i].Image = data.Results[0].Thumb
-            } else {
-                metadata[i].Image = ""
-            }
-
-            results <- struct{}{}
-        }(m)
+    hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+    for i := len(hops) - 1; i >= 0; i-- {
+        hop := strings.TrimSpace(hops[i])
+        if hop == "" {
+            continue
+        }
+
+        ip = hop
+        if !trustedProxy(s.proxies, ip) {
+            break
+        }
     }
 
-    for range metadata {
-        <- results
-    }
+    return ip
+}
+
+func newAPIKey() (string, error) {
+    return randomString(24)
+}
+
+// hashToken is what gets stored for API keys and emailed tokens. They are
+// long and random, so a plain SHA-256 is enough and lets lookups stay a
+// single indexed query.
+func hashToken(token string) string {
+    sum := sha256.Sum256([]byte(token))
+    return hex.EncodeToString(sum[:])
 }
 
-func loadTrackImages(metadata []Track, cfg Config) {
-    type DiscogResp struct {
-        Results []struct {
-            Title string `json:"title"`
-            Thumb string `json:"thumb"`
-        } `json:"results"`
+func randomString(length int) (string, error) {
+    const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+    key := make([]byte, length)
+
+    for i := range key {
+        n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+        if err != nil {
+            return "", err
+        }
+
+        key[i] = charset[n.Int64()]
     }
 
-    client := http.Client{
-        Timeout: time.Millisecond * 600,
-    } 
-
-    results := make(chan struct{})
-
-    for i, m := range metadata {
-        go func(m Track) {
-            req, _ := http.NewRequest("GET", "https://api.discogs.com/database/search", nil)
-            params := url.Values{}
-            params.Set("key", cfg.Discogs.Key)
-            params.Set("secret", cfg.Discogs.Secret)
-            params.Set("q", fmt.Sprintf("%s - %s", m.Name, m.Track))
-            req.Header.Set("User-Agent", "nowplayingapp 0.1 / mentemusic.com")
-            req.URL.RawQuery = params.Encode()
-            res, err := client.Do(req)
-
-            if err != nil {
-                log.Printf("Error Loading Images: %s, %s, %s", m.Name, m.Track, err)
-                results <- struct{}{}
-                return
-            }
-
-            defer res.Body.Close()
-
-            var data DiscogResp
-
-            if err := json.NewDecoder(res.Body).Decode(&data); err != nil {
-                log.Printf("decoding: %s\n", err)
-                results <- struct{}{}
-                return
-            }
-
-            if len(data.Results) > 0 {
-                metadata[i].Image = data.Results[0].Thumb
-            } else {
-                metadata[i].Image = ""
-            }
-
-            results <- struct{}{}
-        }(m)
+    return string(key), nil
+}
+
+func encode[T any](w http.ResponseWriter, status int, v T) error {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    if err := json.NewEncoder(w).Encode(v); err != nil {
+        return fmt.Errorf("encoding: %w", err)
     }
 
-    for range metadata {
-        <- results
+    return nil
+}
+
+func decode[T any](r *http.Request) (T, error) {
+    var v T
+    if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+        return v, fmt.Errorf("decoding: %w", err)
     }
+
+    return v, nil
 }
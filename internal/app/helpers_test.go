@@ -0,0 +1,35 @@
+package app
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+    s := &Server{ proxies: parseProxies("10.0.0.1, 172.16.0.0/12, nonsense") }
+
+    tests := []struct {
+        name string
+        remote string
+        forwarded []string
+        want string
+    }{
+        { "direct", "203.0.113.7:5000", nil, "203.0.113.7" },
+        { "spoofed without a proxy", "203.0.113.7:5000", []string{ "198.51.100.1" }, "203.0.113.7" },
+        { "behind the proxy", "10.0.0.1:5000", []string{ "198.51.100.1, 203.0.113.7" }, "203.0.113.7" },
+        { "through two proxies", "172.18.0.2:5000", []string{ "1.2.3.4, 203.0.113.7", "10.0.0.1" }, "203.0.113.7" },
+        { "proxy without the header", "10.0.0.1:5000", nil, "10.0.0.1" },
+    }
+
+    for _, tt := range tests {
+        r := httptest.NewRequest("GET", "/", nil)
+        r.RemoteAddr = tt.remote
+        for _, value := range tt.forwarded {
+            r.Header.Add("X-Forwarded-For", value)
+        }
+
+        if got := s.clientIP(r); got != tt.want {
+            t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+        }
+    }
+}
@@ -0,0 +1,421 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cg219/nowplaying/internal/database"
+)
+
+const (
+    RENAME_ARTIST = "artist"
+    RENAME_ALBUM = "album"
+    RENAME_TRACK = "track"
+    defaultHistoryLimit = 50
+    maxHistoryLimit = 200
+)
+
+type HistoryScrobble struct {
+    Id int64 `json:"id"`
+    ArtistName string `json:"artistName"`
+    TrackName string `json:"trackName"`
+    AlbumName string `json:"albumName"`
+    AlbumArtist string `json:"albumArtist"`
+    Timestamp int64 `json:"timestamp"`
+    Duration int64 `json:"duration"`
+    Source string `json:"source"`
+}
+
+type ScrobbleEdit struct {
+    Artist string `json:"artist"`
+    Track string `json:"track"`
+    Album string `json:"album"`
+}
+
+// RenameRequest replaces a name across all of a user's scrobbles. Album and
+// track names are only unique per artist, so those renames need Artist too.
+type RenameRequest struct {
+    Field string `json:"field"`
+    Artist string `json:"artist"`
+    From string `json:"from"`
+    To string `json:"to"`
+}
+
+func (s *Server) GetScrobbles(w http.ResponseWriter, r *http.Request) error {
+    type Data struct {
+        Scrobbles []HistoryScrobble `json:"scrobbles"`
+        Page int `json:"page"`
+        Limit int `json:"limit"`
+        Total int64 `json:"total"`
+    }
+
+    user, err := s.currentUser(r)
+    if err != nil {
+        return err
+    }
+
+    page, err := strconv.Atoi(r.URL.Query().Get("page"))
+    if err != nil || page < 1 {
+        page = 1
+    }
+
+    limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+    if err != nil || limit < 1 || limit > maxHistoryLimit {
+        limit = defaultHistoryLimit
+    }
+
+    rows, err := s.authCfg.database.GetScrobbles(r.Context(), database.GetScrobblesParams{
+        Uid: user.ID,
+        Limit: int64(limit),
+        Offset: int64((page - 1) * limit),
+    })
+
+    if err != nil {
+        s.log.Error("Getting Scrobbles", "username", user.Username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    total, err := s.authCfg.database.CountScrobbles(r.Context(), user.ID)
+    if err != nil {
+        s.log.Error("Counting Scrobbles", "username", user.Username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    data := Data{ Scrobbles: []HistoryScrobble{}, Page: page, Limit: limit, Total: total }
+    for _, row := range rows {
+        data.Scrobbles = append(data.Scrobbles, historyScrobble(database.GetScrobbleRow(row)))
+    }
+
+    encode(w, http.StatusOK, data)
+    return nil
+}
+
+func (s *Server) EditScrobble(w http.ResponseWriter, r *http.Request) error {
+    user, err := s.currentUser(r)
+    if err != nil {
+        return err
+    }
+
+    id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+    if err != nil {
+        return fmt.Errorf(NOT_FOUND_ERROR)
+    }
+
+    edit, err := decode[ScrobbleEdit](r)
+    edit.Artist = strings.TrimSpace(edit.Artist)
+    edit.Track = strings.TrimSpace(edit.Track)
+    edit.Album = strings.TrimSpace(edit.Album)
+
+    if err != nil || edit.Artist == "" || edit.Track == "" {
+        return fmt.Errorf(BAD_REQUEST_ERROR)
+    }
+
+    tx, err := s.authCfg.conn.BeginTx(r.Context(), nil)
+    if err != nil {
+        s.log.Error("Starting Transaction", "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    defer tx.Rollback()
+
+    row, err := editScrobble(r.Context(), s.authCfg.database.WithTx(tx), user.ID, id, edit)
+    if err == sql.ErrNoRows {
+        return fmt.Errorf(NOT_FOUND_ERROR)
+    }
+
+    if err == nil {
+        err = tx.Commit()
+    }
+
+    if err != nil {
+        s.log.Error("Updating Scrobble", "username", user.Username, "id", id, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    encode(w, http.StatusOK, historyScrobble(row))
+    return nil
+}
+
+// editScrobble updates a scrobble and moves its main artist credit to the new
+// artist name, leaving any featured artists as they were.
+func editScrobble(ctx context.Context, db *database.Queries, uid int64, id int64, edit ScrobbleEdit) (database.GetScrobbleRow, error) {
+    row, err := db.GetScrobble(ctx, database.GetScrobbleParams{ ID: id, Uid: uid })
+    if err != nil {
+        return row, err
+    }
+
+    _, err = db.UpdateScrobble(ctx, database.UpdateScrobbleParams{
+        ArtistName: edit.Artist,
+        TrackName: edit.Track,
+        AlbumName: sql.NullString{ String: edit.Album, Valid: edit.Album != "" },
+        ID: id,
+        Uid: uid,
+    })
+
+    if err != nil {
+        return row, err
+    }
+
+    if row.ArtistName != edit.Artist {
+        if err := db.RemoveScrobbleArtist(ctx, database.RemoveScrobbleArtistParams{ ScrobbleID: id, Name: row.ArtistName }); err != nil {
+            return row, err
+        }
+
+        // The new name may already be credited as a featured artist; drop that
+        // credit so it comes back first rather than keeping its old position.
+        if err := db.RemoveScrobbleArtist(ctx, database.RemoveScrobbleArtistParams{ ScrobbleID: id, Name: edit.Artist }); err != nil {
+            return row, err
+        }
+
+        if err := db.AddScrobbleArtist(ctx, database.AddScrobbleArtistParams{ ScrobbleID: id, Name: edit.Artist, Position: 0 }); err != nil {
+            return row, err
+        }
+    }
+
+    return db.GetScrobble(ctx, database.GetScrobbleParams{ ID: id, Uid: uid })
+}
+
+func (s *Server) DeleteScrobble(w http.ResponseWriter, r *http.Request) error {
+    user, err := s.currentUser(r)
+    if err != nil {
+        return err
+    }
+
+    id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+    if err != nil {
+        return fmt.Errorf(NOT_FOUND_ERROR)
+    }
+
+    tx, err := s.authCfg.conn.BeginTx(r.Context(), nil)
+    if err != nil {
+        s.log.Error("Starting Transaction", "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    defer tx.Rollback()
+
+    deleted, err := removeScrobble(r.Context(), s.authCfg.database.WithTx(tx), user.ID, id)
+    if err == nil && deleted > 0 {
+        err = tx.Commit()
+    }
+
+    if err != nil {
+        s.log.Error("Removing Scrobble", "username", user.Username, "id", id, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    if deleted == 0 {
+        return fmt.Errorf(NOT_FOUND_ERROR)
+    }
+
+    encode(w, http.StatusOK, SuccessResp{ Success: true })
+    return nil
+}
+
+// DeleteScrobbles removes several scrobbles at once. Ids that don't exist or
+// belong to someone else are skipped; the response says how many went.
+func (s *Server) DeleteScrobbles(w http.ResponseWriter, r *http.Request) error {
+    type Body struct {
+        Ids []int64 `json:"ids"`
+    }
+
+    type Data struct {
+        Success bool `json:"success"`
+        Deleted int64 `json:"deleted"`
+    }
+
+    user, err := s.currentUser(r)
+    if err != nil {
+        return err
+    }
+
+    body, err := decode[Body](r)
+    if err != nil || len(body.Ids) == 0 || len(body.Ids) > maxScrobbleBatch {
+        return fmt.Errorf(BAD_REQUEST_ERROR)
+    }
+
+    tx, err := s.authCfg.conn.BeginTx(r.Context(), nil)
+    if err != nil {
+        s.log.Error("Starting Transaction", "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    defer tx.Rollback()
+
+    db := s.authCfg.database.WithTx(tx)
+    data := Data{ Success: true }
+
+    for _, id := range body.Ids {
+        deleted, err := removeScrobble(r.Context(), db, user.ID, id)
+        if err != nil {
+            s.log.Error("Removing Scrobble", "username", user.Username, "id", id, "error", err)
+            return fmt.Errorf(INTERNAL_ERROR)
+        }
+
+        data.Deleted += deleted
+    }
+
+    if err := tx.Commit(); err != nil {
+        s.log.Error("Removing Scrobbles", "username", user.Username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    encode(w, http.StatusOK, data)
+    return nil
+}
+
+func (s *Server) PreviewRename(w http.ResponseWriter, r *http.Request) error {
+    return s.rename(w, r, false)
+}
+
+func (s *Server) RenameScrobbles(w http.ResponseWriter, r *http.Request) error {
+    return s.rename(w, r, true)
+}
+
+func (s *Server) rename(w http.ResponseWriter, r *http.Request, apply bool) error {
+    type Data struct {
+        Success bool `json:"success"`
+        Matches int64 `json:"matches"`
+        Updated int64 `json:"updated"`
+    }
+
+    user, err := s.currentUser(r)
+    if err != nil {
+        return err
+    }
+
+    req, err := decode[RenameRequest](r)
+    if err != nil || !req.valid(apply) {
+        return fmt.Errorf(BAD_REQUEST_ERROR)
+    }
+
+    tx, err := s.authCfg.conn.BeginTx(r.Context(), nil)
+    if err != nil {
+        s.log.Error("Starting Transaction", "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    defer tx.Rollback()
+
+    matches, err := renameScrobbles(r.Context(), s.authCfg.database.WithTx(tx), user.ID, req, apply)
+    if err != nil {
+        s.log.Error("Renaming Scrobbles", "username", user.Username, "field", req.Field, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    if err := tx.Commit(); err != nil {
+        s.log.Error("Renaming Scrobbles", "username", user.Username, "field", req.Field, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    data := Data{ Success: true, Matches: matches }
+    if apply {
+        data.Updated = matches
+    }
+
+    encode(w, http.StatusOK, data)
+    return nil
+}
+
+func (req RenameRequest) valid(apply bool) bool {
+    if req.From == "" || (apply && (req.To == "" || req.To == req.From)) {
+        return false
+    }
+
+    switch req.Field {
+    case RENAME_ARTIST:
+        return true
+    case RENAME_ALBUM, RENAME_TRACK:
+        return req.Artist != ""
+    }
+
+    return false
+}
+
+// renameScrobbles counts the scrobbles a rename touches and, when apply is set,
+// renames them. Renaming an artist renames it as album artist too.
+func renameScrobbles(ctx context.Context, db *database.Queries, uid int64, req RenameRequest, apply bool) (int64, error) {
+    from := sql.NullString{ String: req.From, Valid: true }
+    to := sql.NullString{ String: req.To, Valid: true }
+
+    switch req.Field {
+    case RENAME_ARTIST:
+        matches, err := db.CountArtistScrobbles(ctx, database.CountArtistScrobblesParams{ Uid: uid, Name: req.From })
+        if err != nil || !apply {
+            return matches, err
+        }
+
+        if _, err := db.RenameScrobbleArtist(ctx, database.RenameScrobbleArtistParams{ NewName: req.To, Uid: uid, OldName: req.From }); err != nil {
+            return 0, err
+        }
+
+        // Scrobbles already crediting the new name keep that credit; the old
+        // one left behind on them goes.
+        if err := db.RenameArtistCredits(ctx, database.RenameArtistCreditsParams{ NewName: req.To, OldName: req.From, Uid: uid }); err != nil {
+            return 0, err
+        }
+
+        if err := db.RemoveArtistCredits(ctx, database.RemoveArtistCreditsParams{ Name: req.From, Uid: uid }); err != nil {
+            return 0, err
+        }
+
+        _, err = db.RenameScrobbleAlbumArtist(ctx, database.RenameScrobbleAlbumArtistParams{ NewName: to, Uid: uid, OldName: from })
+        return matches, err
+    case RENAME_ALBUM:
+        matches, err := db.CountAlbumScrobbles(ctx, database.CountAlbumScrobblesParams{ Uid: uid, ArtistName: req.Artist, AlbumName: from })
+        if err != nil || !apply {
+            return matches, err
+        }
+
+        _, err = db.RenameScrobbleAlbum(ctx, database.RenameScrobbleAlbumParams{ NewName: to, Uid: uid, ArtistName: req.Artist, OldName: from })
+        return matches, err
+    case RENAME_TRACK:
+        matches, err := db.CountTrackScrobbles(ctx, database.CountTrackScrobblesParams{ Uid: uid, ArtistName: req.Artist, TrackName: req.From })
+        if err != nil || !apply {
+            return matches, err
+        }
+
+        _, err = db.RenameScrobbleTrack(ctx, database.RenameScrobbleTrackParams{ NewName: req.To, Uid: uid, ArtistName: req.Artist, OldName: req.From })
+        return matches, err
+    }
+
+    return 0, fmt.Errorf("unknown rename field: %s", req.Field)
+}
+
+// removeScrobble deletes a scrobble and its artist credits. It runs two
+// statements, so db should be bound to a transaction.
+func removeScrobble(ctx context.Context, db *database.Queries, uid int64, id int64) (int64, error) {
+    if err := db.RemoveScrobbleArtists(ctx, database.RemoveScrobbleArtistsParams{ ID: id, Uid: uid }); err != nil {
+        return 0, err
+    }
+
+    return db.RemoveScrobble(ctx, database.RemoveScrobbleParams{ ID: id, Uid: uid })
+}
+
+func (s *Server) currentUser(r *http.Request) (database.GetUserRow, error) {
+    username := r.Context().Value("username").(string)
+    user, err := s.authCfg.database.GetUser(r.Context(), username)
+    if err != nil {
+        s.log.Error("Getting User", "username", username, "error", err)
+        return user, fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    return user, nil
+}
+
+func historyScrobble(row database.GetScrobbleRow) HistoryScrobble {
+    return HistoryScrobble{
+        Id: row.ID,
+        ArtistName: row.ArtistName,
+        TrackName: row.TrackName,
+        AlbumName: row.AlbumName.String,
+        AlbumArtist: row.AlbumArtist.String,
+        Timestamp: row.Timestamp,
+        Duration: row.Duration,
+        Source: row.Source.String,
+    }
+}
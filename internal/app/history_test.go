@@ -0,0 +1,261 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/cg219/nowplaying/internal/database"
+	"github.com/pressly/goose/v3"
+)
+
+// newTestDB migrates an in-memory database the way Run does, then adds the
+// users 1 and 2 the tests scrobble as.
+func newTestDB(t *testing.T) (*sql.DB, *database.Queries) {
+    t.Helper()
+
+    conn, err := sql.Open("sqlite", ":memory:")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // Every connection to :memory: is its own database.
+    conn.SetMaxOpenConns(1)
+    t.Cleanup(func() { conn.Close() })
+
+    // Go migrations are registered in init, alongside these.
+    goose.SetBaseFS(os.DirFS("../.."))
+    goose.SetDialect("sqlite3")
+    goose.SetLogger(goose.NopLogger())
+
+    if err := goose.Up(conn, "sql/migrations"); err != nil {
+        t.Fatal(err)
+    }
+
+    _, err = conn.Exec(`INSERT INTO users(id, username) VALUES(1, 'tester'), (2, 'other')`)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    return conn, database.New(conn)
+}
+
+func addTestScrobble(t *testing.T, conn *sql.DB, db *database.Queries, uid int, sc Scrobble) int64 {
+    t.Helper()
+
+    sc.Uid = uid
+    sc.Duration = 200000
+    if err := saveScrobble(context.Background(), conn, db, sc); err != nil {
+        t.Fatal(err)
+    }
+
+    // Timestamps only go up in these tests, so the newest is the one just saved.
+    rows, err := db.GetScrobbles(context.Background(), database.GetScrobblesParams{ Uid: int64(uid), Limit: 1 })
+    if err != nil || len(rows) == 0 {
+        t.Fatal(err)
+    }
+
+    return rows[0].ID
+}
+
+func testCredits(t *testing.T, db *database.Queries, id int64) []string {
+    t.Helper()
+
+    names, err := db.GetScrobbleArtists(context.Background(), id)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    return names
+}
+
+func TestRenameArtist(t *testing.T) {
+    ctx := context.Background()
+    conn, db := newTestDB(t)
+
+    solo := addTestScrobble(t, conn, db, 1, Scrobble{ ArtistName: "Jay", TrackName: "One", AlbumArtist: "Jay", Timestamp: 1000 })
+    featured := addTestScrobble(t, conn, db, 1, Scrobble{ ArtistName: "Kanye", Artists: []string{ "Kanye", "Jay" }, TrackName: "Two", AlbumArtist: "Kanye", Timestamp: 2000 })
+    both := addTestScrobble(t, conn, db, 1, Scrobble{ ArtistName: "Jay", Artists: []string{ "Jay", "JAY-Z" }, TrackName: "Three", Timestamp: 3000 })
+    other := addTestScrobble(t, conn, db, 2, Scrobble{ ArtistName: "Jay", TrackName: "One", AlbumArtist: "Jay", Timestamp: 4000 })
+
+    req := RenameRequest{ Field: RENAME_ARTIST, From: "Jay", To: "JAY-Z" }
+
+    matches, err := renameScrobbles(ctx, db, 1, req, false)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if matches != 3 {
+        t.Errorf("preview: got %d matches, want 3", matches)
+    }
+
+    if row, _ := db.GetScrobble(ctx, database.GetScrobbleParams{ ID: solo, Uid: 1 }); row.ArtistName != "Jay" {
+        t.Fatalf("preview renamed %+v", row)
+    }
+
+    if _, err := renameScrobbles(ctx, db, 1, req, true); err != nil {
+        t.Fatal(err)
+    }
+
+    row, _ := db.GetScrobble(ctx, database.GetScrobbleParams{ ID: solo, Uid: 1 })
+    if row.ArtistName != "JAY-Z" || row.AlbumArtist.String != "JAY-Z" {
+        t.Errorf("solo scrobble: %+v", row)
+    }
+
+    tests := []struct {
+        id int64
+        want []string
+    }{
+        { solo, []string{ "JAY-Z" } },
+        { featured, []string{ "Kanye", "JAY-Z" } },
+        { both, []string{ "JAY-Z" } },
+        { other, []string{ "Jay" } },
+    }
+
+    for _, tt := range tests {
+        if got := testCredits(t, db, tt.id); !slices.Equal(got, tt.want) {
+            t.Errorf("scrobble %d credits: got %q, want %q", tt.id, got, tt.want)
+        }
+    }
+
+    if row, _ := db.GetScrobble(ctx, database.GetScrobbleParams{ ID: other, Uid: 2 }); row.ArtistName != "Jay" {
+        t.Errorf("renamed another user's scrobble: %+v", row)
+    }
+}
+
+func TestRenameAlbumAndTrack(t *testing.T) {
+    ctx := context.Background()
+    conn, db := newTestDB(t)
+
+    mine := addTestScrobble(t, conn, db, 1, Scrobble{ ArtistName: "Artist", TrackName: "Song", AlbumName: "Album", Timestamp: 1000 })
+    cover := addTestScrobble(t, conn, db, 1, Scrobble{ ArtistName: "Someone Else", TrackName: "Song", AlbumName: "Album", Timestamp: 2000 })
+
+    album := RenameRequest{ Field: RENAME_ALBUM, Artist: "Artist", From: "Album", To: "Album (Deluxe)" }
+    if matches, err := renameScrobbles(ctx, db, 1, album, true); err != nil || matches != 1 {
+        t.Fatalf("album rename: %d, %v", matches, err)
+    }
+
+    track := RenameRequest{ Field: RENAME_TRACK, Artist: "Artist", From: "Song", To: "Song (Remastered)" }
+    if matches, err := renameScrobbles(ctx, db, 1, track, true); err != nil || matches != 1 {
+        t.Fatalf("track rename: %d, %v", matches, err)
+    }
+
+    row, _ := db.GetScrobble(ctx, database.GetScrobbleParams{ ID: mine, Uid: 1 })
+    if row.AlbumName.String != "Album (Deluxe)" || row.TrackName != "Song (Remastered)" {
+        t.Errorf("renamed scrobble: %+v", row)
+    }
+
+    row, _ = db.GetScrobble(ctx, database.GetScrobbleParams{ ID: cover, Uid: 1 })
+    if row.AlbumName.String != "Album" || row.TrackName != "Song" {
+        t.Errorf("renamed another artist's scrobble: %+v", row)
+    }
+}
+
+func TestRenameRequestValid(t *testing.T) {
+    tests := []struct {
+        req RenameRequest
+        apply bool
+        want bool
+    }{
+        { RenameRequest{ Field: RENAME_ARTIST, From: "A" }, false, true },
+        { RenameRequest{ Field: RENAME_ARTIST, From: "A" }, true, false },
+        { RenameRequest{ Field: RENAME_ARTIST, From: "A", To: "A" }, true, false },
+        { RenameRequest{ Field: RENAME_ARTIST, From: "A", To: "B" }, true, true },
+        { RenameRequest{ Field: RENAME_ALBUM, From: "A", To: "B" }, true, false },
+        { RenameRequest{ Field: RENAME_TRACK, Artist: "X", From: "A", To: "B" }, true, true },
+        { RenameRequest{ Field: "genre", From: "A", To: "B" }, true, false },
+    }
+
+    for _, tt := range tests {
+        if got := tt.req.valid(tt.apply); got != tt.want {
+            t.Errorf("%+v apply=%t: got %t, want %t", tt.req, tt.apply, got, tt.want)
+        }
+    }
+}
+
+func TestEditScrobble(t *testing.T) {
+    ctx := context.Background()
+    conn, db := newTestDB(t)
+
+    id := addTestScrobble(t, conn, db, 1, Scrobble{ ArtistName: "Artsit", Artists: []string{ "Artsit", "Guest" }, TrackName: "Sogn", AlbumName: "Album", Timestamp: 1000 })
+
+    row, err := editScrobble(ctx, db, 1, id, ScrobbleEdit{ Artist: "Artist", Track: "Song" })
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if row.ArtistName != "Artist" || row.TrackName != "Song" || row.AlbumName.Valid {
+        t.Errorf("edited scrobble: %+v", row)
+    }
+
+    if got := testCredits(t, db, id); !slices.Equal(got, []string{ "Artist", "Guest" }) {
+        t.Errorf("credits: got %q", got)
+    }
+
+    // Promoting a featured artist moves its credit to the front.
+    id = addTestScrobble(t, conn, db, 1, Scrobble{ ArtistName: "Host", Artists: []string{ "Host", "Guest", "Other" }, TrackName: "Duet", Timestamp: 2000 })
+    if _, err := editScrobble(ctx, db, 1, id, ScrobbleEdit{ Artist: "Other", Track: "Duet" }); err != nil {
+        t.Fatal(err)
+    }
+
+    if got := testCredits(t, db, id); !slices.Equal(got, []string{ "Other", "Guest" }) {
+        t.Errorf("promoted credits: got %q", got)
+    }
+
+    if _, err := editScrobble(ctx, db, 2, id, ScrobbleEdit{ Artist: "Mine", Track: "Now" }); err != sql.ErrNoRows {
+        t.Errorf("editing another user's scrobble: got %v", err)
+    }
+}
+
+func TestRemoveScrobble(t *testing.T) {
+    ctx := context.Background()
+    conn, db := newTestDB(t)
+
+    id := addTestScrobble(t, conn, db, 1, Scrobble{ ArtistName: "Artist", Artists: []string{ "Artist", "Guest" }, TrackName: "Song", Timestamp: 1000 })
+
+    if deleted, err := removeScrobble(ctx, db, 2, id); err != nil || deleted != 0 {
+        t.Fatalf("removing another user's scrobble: %d, %v", deleted, err)
+    }
+
+    if got := testCredits(t, db, id); len(got) != 2 {
+        t.Fatalf("credits removed for another user: %q", got)
+    }
+
+    if deleted, err := removeScrobble(ctx, db, 1, id); err != nil || deleted != 1 {
+        t.Fatalf("removing scrobble: %d, %v", deleted, err)
+    }
+
+    if got := testCredits(t, db, id); len(got) != 0 {
+        t.Errorf("credits left behind: %q", got)
+    }
+
+    if _, err := db.GetScrobble(ctx, database.GetScrobbleParams{ ID: id, Uid: 1 }); err != sql.ErrNoRows {
+        t.Errorf("scrobble still there: %v", err)
+    }
+}
+
+func TestSaveScrobbleKeepsCredits(t *testing.T) {
+    ctx := context.Background()
+    conn, db := newTestDB(t)
+
+    _, err := conn.Exec(`
+        CREATE TRIGGER fail_credit BEFORE INSERT ON scrobble_artists
+        WHEN NEW.name = 'Broken'
+        BEGIN SELECT RAISE(ABORT, 'credit failed'); END;
+    `)
+
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    sc := Scrobble{ ArtistName: "Artist", Artists: []string{ "Artist", "Broken" }, TrackName: "Song", Duration: 200000, Timestamp: 1000, Uid: 1 }
+    if err := saveScrobble(ctx, conn, db, sc); err == nil {
+        t.Fatal("expected the failed credit to fail the save")
+    }
+
+    if rows, _ := db.GetScrobbles(ctx, database.GetScrobblesParams{ Uid: 1, Limit: 1 }); len(rows) != 0 {
+        t.Errorf("scrobble saved without its credits: %+v", rows)
+    }
+}
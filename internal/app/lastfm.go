@@ -10,7 +10,6 @@ import (
 	"log"
 	"net/http"
 	"net/url"
-	"os/exec"
 	"sort"
 	"strings"
 	"time"
@@ -75,20 +74,46 @@ type LastFMTrack struct {
     Album LastFMAlbum `json:"album"`
     Date LastFMDate `json:"date"`
     Name string `json:"name"`
+    Mbid string `json:"mbid"`
     Url string `json:"url"`
+    Attr struct {
+        NowPlaying string `json:"nowplaying"`
+    } `json:"@attr"`
 }
 
+// LastFMTracks decodes the "track" field of list responses, which Last.fm
+// sends as a bare object instead of an array when there is a single result.
+type LastFMTracks []LastFMTrack
+
 type LastFMCurrentTrackResp struct {
     Recent struct {
-        Tracks []LastFMTrack `json:"track"`
+        Tracks LastFMTracks `json:"track"`
+        Attr struct {
+            Page json.Number `json:"page"`
+            TotalPages json.Number `json:"totalPages"`
+            Total json.Number `json:"total"`
+        } `json:"@attr"`
     } `json:"recenttracks"`
 }
 
 type LastFMConfig struct{
     Key string
     Secret string
+    Redirect string
+}
+
+type LastFMError struct {
+    Code int `json:"error"`
+    Message string `json:"message"`
 }
 
+const (
+    LASTFM_INVALID_SESSION = 9
+    LASTFM_SERVICE_OFFLINE = 11
+    LASTFM_TEMPORARY_ERROR = 16
+    LASTFM_RATE_LIMITED = 29
+)
+
 type apiParam struct {
     Name string
     Value string
@@ -98,6 +123,44 @@ type tokenResp struct {
     Token string `json:"token"`
 }
 
+func (e *LastFMError) Error() string {
+    return fmt.Sprintf("lastfm error %d: %s", e.Code, e.Message)
+}
+
+// Temporary reports whether Last.fm asked us to try the same request again later.
+func (e *LastFMError) Temporary() bool {
+    return e.Code == LASTFM_SERVICE_OFFLINE || e.Code == LASTFM_TEMPORARY_ERROR || e.Code == LASTFM_RATE_LIMITED
+}
+
+func (t *LastFMTracks) UnmarshalJSON(data []byte) error {
+    var list []LastFMTrack
+    if err := json.Unmarshal(data, &list); err == nil {
+        *t = list
+        return nil
+    }
+
+    var single LastFMTrack
+    if err := json.Unmarshal(data, &single); err != nil {
+        return err
+    }
+
+    *t = LastFMTracks{ single }
+    return nil
+}
+
+func lastFMErrorFromBody(body []byte) error {
+    var lfmErr LastFMError
+    if err := json.Unmarshal(body, &lfmErr); err != nil {
+        return nil
+    }
+
+    if lfmErr.Code != 0 {
+        return &lfmErr
+    }
+
+    return nil
+}
+
 func NewLastFM(u string, c LastFMConfig, db *database.Queries) *LastFM {
     return &LastFM{
         client: &http.Client{
@@ -130,66 +193,86 @@ func (l *LastFM) Listen(ctx context.Context, out *chan any) error {
     }
 }
 
-func (l *LastFM) Auth(ctx context.Context) error {
-    authurl := "http://www.last.fm/api/auth/?api_key=%s&token=%s"
-    respBody := tokenResp{}
-    req := l.makeApiRequest("GET", "auth.gettoken", nil) 
-
-    resp, err := l.client.Do(req)
+// GetLastFMAuthURL saves a fresh state on the user and carries it in the
+// callback url so LastFMRedirect only accepts logins this user started.
+func GetLastFMAuthURL(ctx context.Context, username string, config LastFMConfig, db *database.Queries) string {
+    state := GetRandomState(username)
+    cb, err := url.Parse(config.Redirect)
     if err != nil {
-        return err
+        return ""
     }
 
-    defer resp.Body.Close()
+    query := cb.Query()
+    query.Set("state", state)
+    cb.RawQuery = query.Encode()
+
+    db.SaveLastFMAuthState(ctx, database.SaveLastFMAuthStateParams{
+        LastfmAuthState: sql.NullString{ String: state, Valid: true },
+        Username: username,
+    })
+
+    vals := url.Values{}
+    vals.Set("api_key", config.Key)
+    vals.Set("cb", cb.String())
+
+    return fmt.Sprintf("https://www.last.fm/api/auth/?%s", vals.Encode())
+}
+
+// AuthWithToken exchanges the token Last.fm hands back to the auth callback for
+// a session key and stores it on the user.
+func (l *LastFM) AuthWithToken(ctx context.Context, token string) error {
+    req := l.makeApiRequest("GET", "auth.getsession", []apiParam{{ Name: "token", Value: token }})
 
-    err = json.NewDecoder(resp.Body).Decode(&respBody)
+    resp, err := l.client.Do(req.WithContext(ctx))
     if err != nil {
         return err
     }
 
-    log.Print("Token: ", respBody.Token)
-    exec.Command("open", fmt.Sprintf(authurl, l.config.Key, respBody.Token)).Run()
-    fmt.Println("Hit Enter to Continue after authorization")
-    fmt.Scanln()
-
-    req = l.makeApiRequest("GET", "auth.getsession", []apiParam{{ Name: "token", Value: respBody.Token }})
+    defer resp.Body.Close()
 
-    resp2, err := l.client.Do(req)
+    body, err := io.ReadAll(resp.Body)
     if err != nil {
         return err
     }
 
-    defer resp2.Body.Close()
+    if err := lastFMErrorFromBody(body); err != nil {
+        return err
+    }
 
     var session LastFMSessionResp
-    err = json.NewDecoder(resp2.Body).Decode(&session)
+    err = json.Unmarshal(body, &session)
     if err != nil {
         return err
     }
 
-    // l.db.SaveUser(ctx, l.Username)
-    l.db.SaveLastFMSession(ctx, database.SaveLastFMSessionParams{
+    if session.Session.Key == "" {
+        return fmt.Errorf("lastfm: empty session for token")
+    }
+
+    err = l.db.SaveLastFMSession(ctx, database.SaveLastFMSessionParams{
         LastfmSessionName: sql.NullString{ String: session.Session.Name, Valid: true },
         LastfmSessionKey: sql.NullString{ String: session.Session.Key, Valid: true },
         Username: l.Username,
     })
 
+    if err != nil {
+        return err
+    }
+
     l.creds.Name = session.Session.Name
     l.creds.Key = session.Session.Key
 
-    log.Print(session.Session)
     return nil
 }
 
 func (l *LastFM) AuthWithDB(ctx context.Context) error {
     dbSession, err := l.db.GetLastFMSession(ctx, l.Username)
     if err != nil {
-        log.Printf("Oops: %s\n", err)
+        return err
     }
 
-    if !dbSession.LastfmSessionKey.Valid && !dbSession.LastfmSessionName.Valid {
-        l.Auth(ctx)
-        return nil
+    if !dbSession.LastfmSessionKey.Valid || !dbSession.LastfmSessionName.Valid {
+        return fmt.Errorf(AUTH_ERROR)
     }
 
     l.creds.Name = dbSession.LastfmSessionName.String
@@ -212,16 +295,45 @@ func (l *LastFM) Scrobble(ctx context.Context, sc LastFMScrobble) error {
     req := l.makeApiRequest("POST", "track.scrobble", params)
     req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-    resp, err := l.client.Do(req)
+    return l.post(ctx, req)
+}
+
+func (l *LastFM) UpdateNowPlaying(ctx context.Context, sc LastFMScrobble) error {
+    params := []apiParam{}
+    params = append(params, apiParam{ Name: "artist", Value: sc.Artist })
+    params = append(params, apiParam{ Name: "track", Value: sc.Track })
+    params = append(params, apiParam{ Name: "album", Value: sc.Album })
+    params = append(params, apiParam{ Name: "trackNumber", Value: sc.TrackNumber })
+    params = append(params, apiParam{ Name: "mbid", Value: sc.Mbid })
+    params = append(params, apiParam{ Name: "albumArtist", Value: sc.AlbumArtist })
+    params = append(params, apiParam{ Name: "duration", Value: sc.Duration })
+    params = append(params, apiParam{ Name: "sk", Value: l.creds.Key })
+    req := l.makeApiRequest("POST", "track.updateNowPlaying", params)
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+    return l.post(ctx, req)
+}
+
+func (l *LastFM) post(ctx context.Context, req *http.Request) error {
+    resp, err := l.client.Do(req.WithContext(ctx))
     if err != nil {
         return err
     }
-    
+
     defer resp.Body.Close()
 
-    d, _ := io.ReadAll(resp.Body)
+    body, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return err
+    }
+
+    if err := lastFMErrorFromBody(body); err != nil {
+        return err
+    }
 
-    log.Printf("SE: %s", d)
+    if resp.StatusCode >= http.StatusInternalServerError {
+        return &LastFMError{ Code: LASTFM_SERVICE_OFFLINE, Message: resp.Status }
+    }
 
     return nil
 }
@@ -250,6 +362,48 @@ func (l *LastFM) CheckCurrentTrack(ctx context.Context) error {
     return nil
 }
 
+// GetRecentTracks fetches one page of the user's listening history between from
+// and to (unix seconds). A from of 0 leaves the start of the range open.
+func (l *LastFM) GetRecentTracks(ctx context.Context, page int, from int64, to int64) (LastFMCurrentTrackResp, error) {
+    var tracklist LastFMCurrentTrackResp
+
+    params := []apiParam{
+        { Name: "user", Value: l.creds.Name },
+        { Name: "limit", Value: fmt.Sprintf("%d", lastFMImportPageSize) },
+        { Name: "page", Value: fmt.Sprintf("%d", page) },
+        { Name: "to", Value: fmt.Sprintf("%d", to) },
+    }
+
+    if from > 0 {
+        params = append(params, apiParam{ Name: "from", Value: fmt.Sprintf("%d", from) })
+    }
+
+    req := l.makeApiRequest("GET", "user.getrecenttracks", params)
+
+    resp, err := l.client.Do(req.WithContext(ctx))
+    if err != nil {
+        return tracklist, err
+    }
+
+    defer resp.Body.Close()
+
+    body, err := io.ReadAll(resp.Body)
+    if err != nil {
+        return tracklist, err
+    }
+
+    if err := lastFMErrorFromBody(body); err != nil {
+        return tracklist, err
+    }
+
+    if resp.StatusCode >= http.StatusInternalServerError {
+        return tracklist, &LastFMError{ Code: LASTFM_SERVICE_OFFLINE, Message: resp.Status }
+    }
+
+    err = json.Unmarshal(body, &tracklist)
+    return tracklist, err
+}
+
 func (l *LastFM) makeApiRequest(action string, method string, list []apiParam) *http.Request {
     params := ""
 
@@ -281,7 +435,7 @@ func (l *LastFM) makeApiRequest(action string, method string, list []apiParam) *
         return req
     }
 
-    baseurl := "http://ws.audioscrobbler.com/2.0/?api_sig=%s"
+    baseurl := "http://ws.audioscrobbler.com/2.0/?format=json&api_sig=%s"
     signedUrl := fmt.Sprintf(baseurl, l.makeSignature(list))
     req, err := http.NewRequest(action, signedUrl, strings.NewReader(body.Encode()))
 
@@ -293,6 +447,12 @@ func (l *LastFM) makeApiRequest(action string, method string, list []apiParam) *
 }
 
 func (l *LastFM) makeSignature(list []apiParam) string {
+    return lastFMSignature(list, l.config.Secret)
+}
+
+// lastFMSignature builds api_sig: every parameter sorted by name, concatenated
+// as name then value, followed by the secret and md5 hashed.
+func lastFMSignature(list []apiParam, secret string) string {
     rawSig := ""
     sort.Slice(list, func(i, j int) bool {
         return list[i].Name < list[j].Name
@@ -302,7 +462,7 @@ func (l *LastFM) makeSignature(list []apiParam) string {
         rawSig = fmt.Sprintf("%s%s%s", rawSig, p.Name, p.Value)
     }
 
-    rawSig = fmt.Sprintf("%s%s", rawSig, l.config.Secret)
+    rawSig = fmt.Sprintf("%s%s", rawSig, secret)
     h := md5.New()
     fmt.Fprint(h, rawSig)
     sig := h.Sum(nil)
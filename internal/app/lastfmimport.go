@@ -0,0 +1,278 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cg219/nowplaying/internal/database"
+)
+
+const (
+    lastFMImportPageSize = 200
+    lastFMImportPageDelay = time.Millisecond * 250
+    lastFMImportRetries = 5
+    LASTFM_IMPORT_SOURCE = "lastfm-import"
+    IMPORT_RUNNING = "running"
+    IMPORT_COMPLETE = "complete"
+    IMPORT_FAILED = "failed"
+)
+
+// LastFMImporter copies a user's Last.fm history into scrobbles. Progress lives
+// in lastfm_imports so an import that is interrupted picks up from the last
+// finished page the next time the importer runs.
+type LastFMImporter struct {
+    config LastFMConfig
+    db *database.Queries
+    conn *sql.DB
+    running map[int64]bool
+    changes chan struct{}
+    mu sync.Mutex
+}
+
+func NewLastFMImporter(c LastFMConfig, conn *sql.DB, db *database.Queries) *LastFMImporter {
+    return &LastFMImporter{
+        config: c,
+        db: db,
+        conn: conn,
+        running: make(map[int64]bool),
+        changes: make(chan struct{}, 1),
+    }
+}
+
+// Start queues a new import for the user. Only history newer than the last
+// completed import is requested so running it again just fills the gap.
+func (li *LastFMImporter) Start(ctx context.Context, uid int64) error {
+    var since int64
+
+    prev, err := li.db.GetLastFMImport(ctx, uid)
+    if err == nil {
+        if prev.Status == IMPORT_RUNNING {
+            return nil
+        }
+
+        if prev.Status == IMPORT_COMPLETE {
+            since = prev.Until
+        }
+    } else if err != sql.ErrNoRows {
+        return err
+    }
+
+    now := time.Now()
+    err = li.db.StartLastFMImport(ctx, database.StartLastFMImportParams{
+        Uid: uid,
+        Since: since,
+        Until: now.Unix(),
+        StartedAt: now.UnixMilli(),
+        UpdatedAt: now.UnixMilli(),
+    })
+
+    if err != nil {
+        return err
+    }
+
+    select {
+    case li.changes <- struct{}{}:
+    default:
+    }
+
+    return nil
+}
+
+func (li *LastFMImporter) Run(ctx context.Context) {
+    li.resume(ctx)
+
+    for {
+        select {
+        case <- ctx.Done():
+            log.Println("Exiting LastFM Importer")
+            return
+        case <- li.changes:
+            li.resume(ctx)
+        }
+    }
+}
+
+func (li *LastFMImporter) resume(ctx context.Context) {
+    imports, err := li.db.GetRunningLastFMImports(ctx)
+    if err != nil {
+        log.Printf("LastFM Import: loading imports: %s\n", err)
+        return
+    }
+
+    li.mu.Lock()
+    defer li.mu.Unlock()
+
+    for _, imp := range imports {
+        if li.running[imp.Uid] {
+            continue
+        }
+
+        li.running[imp.Uid] = true
+        go li.run(ctx, imp.Uid, imp.Username)
+    }
+}
+
+func (li *LastFMImporter) run(ctx context.Context, uid int64, username string) {
+    defer func() {
+        li.mu.Lock()
+        delete(li.running, uid)
+        li.mu.Unlock()
+    }()
+
+    err := li.importPages(ctx, uid, username)
+    if ctx.Err() != nil {
+        return
+    }
+
+    status := IMPORT_COMPLETE
+    message := sql.NullString{}
+
+    if err != nil {
+        log.Printf("LastFM Import: %s failed: %s\n", username, err)
+        status = IMPORT_FAILED
+        message = sql.NullString{ String: err.Error(), Valid: true }
+    } else {
+        log.Printf("LastFM Import: %s complete\n", username)
+    }
+
+    err = li.db.SetLastFMImportStatus(context.Background(), database.SetLastFMImportStatusParams{
+        Status: status,
+        Error: message,
+        UpdatedAt: time.Now().UnixMilli(),
+        Uid: uid,
+    })
+
+    if err != nil {
+        log.Printf("LastFM Import: saving status for %s: %s\n", username, err)
+    }
+}
+
+func (li *LastFMImporter) importPages(ctx context.Context, uid int64, username string) error {
+    lastfm := NewLastFM(username, li.config, li.db)
+    if err := lastfm.AuthWithDB(ctx); err != nil {
+        return fmt.Errorf("lastfm account not linked")
+    }
+
+    imp, err := li.db.GetLastFMImport(ctx, uid)
+    if err != nil {
+        return err
+    }
+
+    normalizer, err := loadNormalizer(ctx, li.db, uid)
+    if err != nil {
+        return err
+    }
+
+    for page := int(imp.Page); ; page++ {
+        tracklist, err := li.fetchPage(ctx, lastfm, page, imp.Since, imp.Until)
+        if err != nil {
+            return err
+        }
+
+        imported, skipped := 0, 0
+        for _, track := range tracklist.Recent.Tracks {
+            ok, err := li.saveTrack(ctx, uid, normalizer, track)
+            if err != nil {
+                return err
+            }
+
+            if ok {
+                imported++
+            } else {
+                skipped++
+            }
+        }
+
+        totalPages, _ := strconv.Atoi(tracklist.Recent.Attr.TotalPages.String())
+
+        err = li.db.UpdateLastFMImportProgress(ctx, database.UpdateLastFMImportProgressParams{
+            Page: int64(page + 1),
+            TotalPages: int64(totalPages),
+            Imported: int64(imported),
+            Skipped: int64(skipped),
+            UpdatedAt: time.Now().UnixMilli(),
+            Uid: uid,
+        })
+
+        if err != nil {
+            return err
+        }
+
+        if page >= totalPages {
+            return nil
+        }
+
+        select {
+        case <- ctx.Done():
+            return ctx.Err()
+        case <- time.After(lastFMImportPageDelay):
+        }
+    }
+}
+
+func (li *LastFMImporter) fetchPage(ctx context.Context, lastfm *LastFM, page int, since int64, until int64) (LastFMCurrentTrackResp, error) {
+    backoff := minRestartBackoff
+
+    for attempt := 1; ; attempt++ {
+        tracklist, err := lastfm.GetRecentTracks(ctx, page, since, until)
+        if err == nil || attempt >= lastFMImportRetries || ctx.Err() != nil {
+            return tracklist, err
+        }
+
+        var lfmErr *LastFMError
+        if errors.As(err, &lfmErr) && !lfmErr.Temporary() {
+            return tracklist, err
+        }
+
+        log.Printf("LastFM Import: page %d for %s: %s; retrying in %s\n", page, lastfm.Username, err, backoff)
+
+        select {
+        case <- ctx.Done():
+            return tracklist, ctx.Err()
+        case <- time.After(backoff):
+        }
+
+        backoff = nextBackoff(backoff)
+    }
+}
+
+// saveTrack stores a single history entry and reports whether it was new.
+// Tracks that are still playing, or already scrobbled within a minute of the
+// same time, are skipped. Last.fm's history has no track lengths, so they are
+// saved without one for the MusicBrainz enricher to fill in.
+func (li *LastFMImporter) saveTrack(ctx context.Context, uid int64, normalizer *Normalizer, track LastFMTrack) (bool, error) {
+    if track.Attr.NowPlaying == "true" {
+        return false, nil
+    }
+
+    seconds, err := track.Date.Seconds.Int64()
+    if err != nil || track.Artist.Name == "" || track.Name == "" {
+        return false, nil
+    }
+
+    sc := normalizer.Normalize(Scrobble{
+        ArtistName: track.Artist.Name,
+        TrackName: track.Name,
+        AlbumName: track.Album.Name,
+        Mbid: track.Mbid,
+        AlbumMbid: track.Album.Mbid,
+        ArtistMbid: track.Artist.Mbid,
+        Timestamp: int(seconds * 1000),
+        Source: LASTFM_IMPORT_SOURCE,
+        Uid: int(uid),
+    })
+
+    found, err := scrobbleExists(ctx, li.db, uid, sc.ArtistName, sc.TrackName, int64(sc.Timestamp))
+    if err != nil || found {
+        return false, err
+    }
+
+    err = saveScrobble(ctx, li.conn, li.db, sc)
+    return err == nil, err
+}
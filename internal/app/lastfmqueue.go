@@ -0,0 +1,260 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/cg219/nowplaying/internal/database"
+)
+
+const (
+    lastFMQueueInterval = time.Minute * 5
+    lastFMQueueBatch = 50
+    lastFMMaxAttempts = 10
+    lastFMMaxRetryDelay = time.Hour * 6
+    lastFMWorkers = 4
+    lastFMPending = 256
+)
+
+// LastFMSubscriber forwards accepted scrobbles to the user's linked Last.fm
+// account. Submissions that fail are stored in lastfm_queue and retried later.
+type LastFMSubscriber struct {
+    config LastFMConfig
+    db *database.Queries
+    jobs chan lastFMJob
+}
+
+type lastFMJob struct {
+    scrobble Scrobble
+    username string
+    nowPlaying bool
+}
+
+func NewLastFMSubscriber(c LastFMConfig, db *database.Queries) *LastFMSubscriber {
+    return &LastFMSubscriber{
+        config: c,
+        db: db,
+        jobs: make(chan lastFMJob, lastFMPending),
+    }
+}
+
+// Execute hands the scrobble to a worker. When they are all busy and the
+// backlog is full it goes straight to the retry queue instead.
+func (ls *LastFMSubscriber) Execute(scrobble Scrobble, username string) {
+    select {
+    case ls.jobs <- lastFMJob{ scrobble: scrobble, username: username }:
+    default:
+        ls.queue(context.Background(), scrobble, fmt.Errorf("lastfm workers busy"))
+    }
+}
+
+// NowPlaying is only worth sending right away, so it's dropped when the
+// workers are behind.
+func (ls *LastFMSubscriber) NowPlaying(scrobble Scrobble, username string) {
+    select {
+    case ls.jobs <- lastFMJob{ scrobble: scrobble, username: username, nowPlaying: true }:
+    default:
+    }
+}
+
+// Run starts the workers and retries queued scrobbles until ctx is done.
+func (ls *LastFMSubscriber) Run(ctx context.Context) {
+    for i := 0; i < lastFMWorkers; i++ {
+        go ls.work(ctx)
+    }
+
+    ticker := time.NewTicker(lastFMQueueInterval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <- ctx.Done():
+            return
+        case <- ticker.C:
+            ls.flush(ctx)
+        }
+    }
+}
+
+func (ls *LastFMSubscriber) work(ctx context.Context) {
+    for {
+        select {
+        case <- ctx.Done():
+            return
+        case job := <- ls.jobs:
+            if job.nowPlaying {
+                ls.nowPlaying(ctx, job.scrobble, job.username)
+            } else {
+                ls.forward(ctx, job.scrobble, job.username)
+            }
+        }
+    }
+}
+
+func (ls *LastFMSubscriber) forward(ctx context.Context, scrobble Scrobble, username string) {
+    lastfm := NewLastFM(username, ls.config, ls.db)
+
+    if err := lastfm.AuthWithDB(ctx); err != nil {
+        if !lastFMSessionGone(err) {
+            ls.queue(ctx, scrobble, err)
+        }
+
+        return
+    }
+
+    err := lastfm.Scrobble(ctx, toLastFMScrobble(scrobble))
+    if err == nil {
+        log.Printf("LastFM: forwarded %s - %s for %s\n", scrobble.ArtistName, scrobble.TrackName, username)
+        return
+    }
+
+    if !retryableLastFMError(err) {
+        log.Printf("LastFM: dropping %s - %s for %s: %s\n", scrobble.ArtistName, scrobble.TrackName, username, err)
+        return
+    }
+
+    log.Printf("LastFM: queueing %s - %s for %s: %s\n", scrobble.ArtistName, scrobble.TrackName, username, err)
+    ls.queue(ctx, scrobble, err)
+}
+
+func (ls *LastFMSubscriber) nowPlaying(ctx context.Context, scrobble Scrobble, username string) {
+    lastfm := NewLastFM(username, ls.config, ls.db)
+
+    if err := lastfm.AuthWithDB(ctx); err != nil {
+        return
+    }
+
+    if err := lastfm.UpdateNowPlaying(ctx, toLastFMScrobble(scrobble)); err != nil {
+        log.Printf("LastFM: now playing %s - %s for %s: %s\n", scrobble.ArtistName, scrobble.TrackName, username, err)
+    }
+}
+
+func (ls *LastFMSubscriber) queue(ctx context.Context, scrobble Scrobble, reason error) {
+    err := ls.db.QueueLastFMScrobble(ctx, database.QueueLastFMScrobbleParams{
+        Uid: int64(scrobble.Uid),
+        ArtistName: scrobble.ArtistName,
+        TrackName: scrobble.TrackName,
+        AlbumName: sql.NullString{ String: scrobble.AlbumName, Valid: scrobble.AlbumName != "" },
+        AlbumArtist: sql.NullString{ String: scrobble.AlbumArtist, Valid: scrobble.AlbumArtist != "" },
+        TrackNumber: sql.NullString{ String: scrobble.TrackNumber, Valid: scrobble.TrackNumber != "" },
+        Mbid: sql.NullString{ String: scrobble.Mbid, Valid: scrobble.Mbid != "" },
+        Duration: int64(scrobble.Duration),
+        Timestamp: int64(scrobble.Timestamp),
+        NextAttempt: time.Now().Add(lastFMRetryDelay(0)).Unix(),
+        LastError: sql.NullString{ String: reason.Error(), Valid: true },
+    })
+
+    if err != nil {
+        log.Printf("LastFM: queueing failed: %s\n", err)
+    }
+}
+
+func (ls *LastFMSubscriber) flush(ctx context.Context) {
+    due, err := ls.db.GetDueLastFMScrobbles(ctx, database.GetDueLastFMScrobblesParams{
+        NextAttempt: time.Now().Unix(),
+        Limit: lastFMQueueBatch,
+    })
+
+    if err != nil {
+        log.Printf("LastFM: loading queue: %s\n", err)
+        return
+    }
+
+    sessions := make(map[string]*LastFM)
+    unlinked := make(map[string]bool)
+
+    for _, row := range due {
+        lastfm, ok := sessions[row.Username]
+        if !ok {
+            lastfm = NewLastFM(row.Username, ls.config, ls.db)
+            if err := lastfm.AuthWithDB(ctx); err != nil {
+                // Rows are only dropped once the account is unlinked; anything
+                // else leaves them for the next pass.
+                if lastFMSessionGone(err) {
+                    unlinked[row.Username] = true
+                } else {
+                    log.Printf("LastFM: loading session for %s: %s\n", row.Username, err)
+                }
+
+                lastfm = nil
+            }
+
+            sessions[row.Username] = lastfm
+        }
+
+        if lastfm == nil {
+            if unlinked[row.Username] {
+                ls.db.RemoveQueuedLastFMScrobble(ctx, row.ID)
+            }
+
+            continue
+        }
+
+        err := lastfm.Scrobble(ctx, LastFMScrobble{
+            Artist: row.ArtistName,
+            Track: row.TrackName,
+            Timestamp: fmt.Sprintf("%d", row.Timestamp / 1000),
+            Album: row.AlbumName.String,
+            TrackNumber: row.TrackNumber.String,
+            Mbid: row.Mbid.String,
+            AlbumArtist: row.AlbumArtist.String,
+            Duration: fmt.Sprintf("%d", row.Duration / 1000),
+        })
+
+        if err == nil || !retryableLastFMError(err) || row.Attempts + 1 >= lastFMMaxAttempts {
+            if err != nil {
+                log.Printf("LastFM: giving up on %s - %s for %s: %s\n", row.ArtistName, row.TrackName, row.Username, err)
+            }
+
+            ls.db.RemoveQueuedLastFMScrobble(ctx, row.ID)
+            continue
+        }
+
+        ls.db.RetryLastFMScrobble(ctx, database.RetryLastFMScrobbleParams{
+            NextAttempt: time.Now().Add(lastFMRetryDelay(int(row.Attempts) + 1)).Unix(),
+            LastError: sql.NullString{ String: err.Error(), Valid: true },
+            ID: row.ID,
+        })
+    }
+}
+
+func toLastFMScrobble(sc Scrobble) LastFMScrobble {
+    return LastFMScrobble{
+        Artist: sc.ArtistName,
+        Track: sc.TrackName,
+        Timestamp: fmt.Sprintf("%d", sc.Timestamp / 1000),
+        Album: sc.AlbumName,
+        TrackNumber: sc.TrackNumber,
+        Mbid: sc.Mbid,
+        AlbumArtist: sc.AlbumArtist,
+        Duration: fmt.Sprintf("%d", sc.Duration / 1000),
+    }
+}
+
+// lastFMSessionGone reports whether AuthWithDB failed because the user has
+// no Last.fm session, rather than because the lookup itself failed.
+func lastFMSessionGone(err error) bool {
+    return errors.Is(err, sql.ErrNoRows) || err.Error() == AUTH_ERROR
+}
+
+func retryableLastFMError(err error) bool {
+    var lfmErr *LastFMError
+    if errors.As(err, &lfmErr) {
+        return lfmErr.Temporary()
+    }
+
+    return true
+}
+
+func lastFMRetryDelay(attempts int) time.Duration {
+    delay := time.Minute << attempts
+    if delay <= 0 || delay > lastFMMaxRetryDelay {
+        return lastFMMaxRetryDelay
+    }
+
+    return delay
+}
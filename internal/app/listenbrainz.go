@@ -0,0 +1,230 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+    LISTENBRAINZ_SINGLE = "single"
+    LISTENBRAINZ_IMPORT = "import"
+    LISTENBRAINZ_PLAYING_NOW = "playing_now"
+    maxListenBrainzBody = 10 << 20
+)
+
+// ListenBrainzSubmission is the body of POST /1/submit-listens.
+type ListenBrainzSubmission struct {
+    ListenType string `json:"listen_type"`
+    Payload []ListenBrainzListen `json:"payload"`
+}
+
+type ListenBrainzListen struct {
+    ListenedAt int64 `json:"listened_at"`
+    TrackMetadata struct {
+        ArtistName string `json:"artist_name"`
+        TrackName string `json:"track_name"`
+        ReleaseName string `json:"release_name"`
+        AdditionalInfo struct {
+            DurationMs int `json:"duration_ms"`
+            Duration int `json:"duration"`
+            TrackNumber json.RawMessage `json:"tracknumber"`
+            RecordingMbid string `json:"recording_mbid"`
+            ReleaseMbid string `json:"release_mbid"`
+            ArtistMbids []string `json:"artist_mbids"`
+            ReleaseArtistName string `json:"release_artist_name"`
+            SubmissionClient string `json:"submission_client"`
+            MediaPlayer string `json:"media_player"`
+        } `json:"additional_info"`
+    } `json:"track_metadata"`
+}
+
+type ListenBrainzResp struct {
+    Code int `json:"code,omitempty"`
+    Status string `json:"status,omitempty"`
+    Message string `json:"message,omitempty"`
+    Error string `json:"error,omitempty"`
+    Valid *bool `json:"valid,omitempty"`
+    Username string `json:"user_name,omitempty"`
+}
+
+func (s *Server) ListenBrainzValidateToken(w http.ResponseWriter, r *http.Request) error {
+    token := listenBrainzToken(r)
+    if token == "" {
+        token = r.URL.Query().Get("token")
+    }
+
+    valid := false
+    if token == "" {
+        encode(w, http.StatusBadRequest, ListenBrainzResp{ Code: http.StatusBadRequest, Error: "You need to provide an Authorization token." })
+        return nil
+    }
+
+    username, err := s.apiKeyUser(r.Context(), token, SCOPE_SCROBBLE)
+    if err != nil {
+        encode(w, http.StatusOK, ListenBrainzResp{ Code: http.StatusOK, Message: "Token invalid.", Valid: &valid })
+        return nil
+    }
+
+    valid = true
+    encode(w, http.StatusOK, ListenBrainzResp{ Code: http.StatusOK, Message: "Token valid.", Valid: &valid, Username: username })
+    return nil
+}
+
+func (s *Server) ListenBrainzSubmit(w http.ResponseWriter, r *http.Request) error {
+    token := listenBrainzToken(r)
+    if token == "" {
+        encode(w, http.StatusUnauthorized, ListenBrainzResp{ Code: http.StatusUnauthorized, Error: "You need to provide an Authorization header." })
+        return nil
+    }
+
+    username, err := s.apiKeyUser(r.Context(), token, SCOPE_SCROBBLE)
+    if err != nil {
+        encode(w, http.StatusUnauthorized, ListenBrainzResp{ Code: http.StatusUnauthorized, Error: "Invalid authorization token." })
+        return nil
+    }
+
+    r.Body = http.MaxBytesReader(w, r.Body, maxListenBrainzBody)
+    defer r.Body.Close()
+
+    submission, err := decode[ListenBrainzSubmission](r)
+    if err != nil {
+        encode(w, http.StatusBadRequest, ListenBrainzResp{ Code: http.StatusBadRequest, Error: "Cannot parse JSON document." })
+        return nil
+    }
+
+    if msg := submission.validate(); msg != "" {
+        encode(w, http.StatusBadRequest, ListenBrainzResp{ Code: http.StatusBadRequest, Error: msg })
+        return nil
+    }
+
+    // Single and import listens are plays that already finished, so like
+    // other finished plays they skip now playing and are checked for
+    // duplicates.
+    for _, listen := range submission.Payload {
+        s.authCfg.scrobbles <- ScrobblePack{
+            Scrobble: listen.Scrobble(submission.ListenType),
+            Username: username,
+            NowPlaying: submission.ListenType == LISTENBRAINZ_PLAYING_NOW,
+            Import: submission.ListenType != LISTENBRAINZ_PLAYING_NOW,
+        }
+    }
+
+    encode(w, http.StatusOK, ListenBrainzResp{ Status: "ok" })
+    return nil
+}
+
+// validate mirrors the checks ListenBrainz itself makes so clients get the
+// same errors they would from the real service.
+func (sub ListenBrainzSubmission) validate() string {
+    switch sub.ListenType {
+    case LISTENBRAINZ_SINGLE, LISTENBRAINZ_PLAYING_NOW:
+        if len(sub.Payload) != 1 {
+            return fmt.Sprintf("JSON document must contain exactly one listen for listen_type %s.", sub.ListenType)
+        }
+    case LISTENBRAINZ_IMPORT:
+        if len(sub.Payload) == 0 {
+            return "JSON document does not contain any listens."
+        }
+    default:
+        return "JSON document has invalid listen_type."
+    }
+
+    for _, listen := range sub.Payload {
+        if listen.TrackMetadata.ArtistName == "" || listen.TrackMetadata.TrackName == "" {
+            return "JSON document must contain artist_name and track_name."
+        }
+
+        if sub.ListenType != LISTENBRAINZ_PLAYING_NOW && listen.ListenedAt <= 0 {
+            return "JSON document must contain listened_at."
+        }
+    }
+
+    return ""
+}
+
+// Scrobble maps a listen onto a Scrobble. ListenBrainz clients only submit
+// listens that already passed their own threshold, so the whole track counts as
+// played.
+func (l ListenBrainzListen) Scrobble(listenType string) Scrobble {
+    meta := l.TrackMetadata
+    info := meta.AdditionalInfo
+
+    duration := info.DurationMs
+    if duration == 0 {
+        duration = info.Duration * 1000
+    }
+
+    timestamp := time.Unix(l.ListenedAt, 0)
+    progress := duration
+    if listenType == LISTENBRAINZ_PLAYING_NOW {
+        timestamp = time.Now()
+        progress = 0
+    }
+
+    albumArtist := info.ReleaseArtistName
+    if albumArtist == "" {
+        albumArtist = meta.ArtistName
+    }
+
+    artistMbid := ""
+    if len(info.ArtistMbids) > 0 {
+        artistMbid = info.ArtistMbids[0]
+    }
+
+    source := strings.ToLower(info.MediaPlayer)
+    if source == "" {
+        source = strings.ToLower(info.SubmissionClient)
+    }
+
+    if source == "" {
+        source = "listenbrainz"
+    }
+
+    return Scrobble{
+        ArtistName: meta.ArtistName,
+        TrackName: meta.TrackName,
+        AlbumName: meta.ReleaseName,
+        AlbumArtist: albumArtist,
+        TrackNumber: listenBrainzTrackNumber(info.TrackNumber),
+        Mbid: info.RecordingMbid,
+        AlbumMbid: info.ReleaseMbid,
+        ArtistMbid: artistMbid,
+        Duration: duration,
+        Progress: progress,
+        Timestamp: int(timestamp.UnixMilli()),
+        Source: source,
+    }
+}
+
+func listenBrainzToken(r *http.Request) string {
+    token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Token ")
+    if !ok {
+        return ""
+    }
+
+    return strings.TrimSpace(token)
+}
+
+// listenBrainzTrackNumber accepts tracknumber as either a number or a string,
+// since clients send both.
+func listenBrainzTrackNumber(raw json.RawMessage) string {
+    if len(raw) == 0 {
+        return ""
+    }
+
+    var n int
+    if err := json.Unmarshal(raw, &n); err == nil {
+        return strconv.Itoa(n)
+    }
+
+    var str string
+    if err := json.Unmarshal(raw, &str); err == nil {
+        return str
+    }
+
+    return ""
+}
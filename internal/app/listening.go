@@ -0,0 +1,240 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/cg219/nowplaying/internal/database"
+)
+
+const (
+    // The daily series gets one entry per day, so ranges are capped.
+    maxListeningDays = 366 * 2
+    listeningStreakCount = 3
+    dateFormat = "2006-01-02"
+)
+
+// ListenEvent is one or more plays starting at Timestamp, all inside the same
+// local hour.
+type ListenEvent struct {
+    Timestamp int64
+    Plays int64
+    Duration int64
+}
+
+type ListeningDay struct {
+    Date string `json:"date"`
+    Plays int64 `json:"plays"`
+    Minutes int64 `json:"minutes"`
+}
+
+type Streak struct {
+    Start string `json:"start"`
+    End string `json:"end"`
+    Days int `json:"days"`
+}
+
+// ListeningStats describes when a user listens. Listening time is the sum of
+// track durations and is credited to the hour a track started in. Weekdays
+// run Monday (0) to Sunday (6) and hours are in the user's time zone.
+type ListeningStats struct {
+    From int64 `json:"from"`
+    To int64 `json:"to"`
+    Timezone string `json:"timezone"`
+    Plays int64 `json:"plays"`
+    Listened int64 `json:"listened"`
+    Daily []ListeningDay `json:"daily"`
+    Heatmap [7][24]int64 `json:"heatmap"`
+    Hours [24]int64 `json:"hours"`
+    Weekdays [7]int64 `json:"weekdays"`
+    Streaks []Streak `json:"streaks"`
+    CurrentStreak Streak `json:"currentStreak"`
+}
+
+// buildListeningStats aggregates listens inside period. The heatmap, hour and
+// weekday totals are in minutes.
+func buildListeningStats(listens []ListenEvent, period Period) ListeningStats {
+    loc := period.From.Location()
+    stats := ListeningStats{
+        From: period.From.UnixMilli(),
+        To: period.To.UnixMilli(),
+        Timezone: loc.String(),
+        Daily: []ListeningDay{},
+        Streaks: []Streak{},
+    }
+
+    days := map[string]int{}
+    for day := dayPeriod(period.From).From; day.Before(period.To); day = day.AddDate(0, 0, 1) {
+        days[day.Format(dateFormat)] = len(stats.Daily)
+        stats.Daily = append(stats.Daily, ListeningDay{ Date: day.Format(dateFormat) })
+    }
+
+    var listened [7][24]int64
+    daily := make([]int64, len(stats.Daily))
+
+    for _, l := range listens {
+        t := time.UnixMilli(l.Timestamp).In(loc)
+        if !period.Contains(t) {
+            continue
+        }
+
+        stats.Plays += l.Plays
+        stats.Listened += l.Duration
+
+        weekday := (int(t.Weekday()) + 6) % 7
+        listened[weekday][t.Hour()] += l.Duration
+
+        if i, ok := days[t.Format(dateFormat)]; ok {
+            stats.Daily[i].Plays += l.Plays
+            daily[i] += l.Duration
+        }
+    }
+
+    for i := range stats.Daily {
+        stats.Daily[i].Minutes = daily[i] / time.Minute.Milliseconds()
+    }
+
+    // Totals are summed in milliseconds and converted last, so short plays
+    // spread over many cells aren't rounded away.
+    var hours [24]int64
+    var weekdays [7]int64
+
+    for weekday := range listened {
+        for hour, ms := range listened[weekday] {
+            stats.Heatmap[weekday][hour] = ms / time.Minute.Milliseconds()
+            hours[hour] += ms
+            weekdays[weekday] += ms
+        }
+    }
+
+    for hour, ms := range hours {
+        stats.Hours[hour] = ms / time.Minute.Milliseconds()
+    }
+
+    for weekday, ms := range weekdays {
+        stats.Weekdays[weekday] = ms / time.Minute.Milliseconds()
+    }
+
+    return stats
+}
+
+// listeningStreaks finds runs of consecutive days with at least one scrobble
+// in timestamps, which must be sorted. It returns the longest runs, longest
+// first, and the run that includes today or yesterday, if there is one.
+func listeningStreaks(timestamps []int64, now time.Time, count int) ([]Streak, Streak) {
+    loc := now.Location()
+    streaks := []Streak{}
+
+    var start, last time.Time
+    flush := func() {
+        if start.IsZero() {
+            return
+        }
+
+        days := int(last.Sub(start).Hours() / 24 + 0.5) + 1
+        streaks = append(streaks, Streak{ Start: start.Format(dateFormat), End: last.Format(dateFormat), Days: days })
+    }
+
+    for _, ts := range timestamps {
+        day := dayPeriod(time.UnixMilli(ts).In(loc)).From
+
+        switch {
+        case start.IsZero():
+            start, last = day, day
+        case day.Equal(last):
+        case day.Equal(last.AddDate(0, 0, 1)):
+            last = day
+        default:
+            flush()
+            start, last = day, day
+        }
+    }
+
+    flush()
+
+    var current Streak
+    today := dayPeriod(now).From
+    if len(streaks) > 0 {
+        latest := streaks[len(streaks) - 1]
+        if latest.End == today.Format(dateFormat) || latest.End == today.AddDate(0, 0, -1).Format(dateFormat) {
+            current = latest
+        }
+    }
+
+    sort.SliceStable(streaks, func(i, j int) bool {
+        return streaks[i].Days > streaks[j].Days
+    })
+
+    if len(streaks) > count {
+        streaks = streaks[:count]
+    }
+
+    return streaks, current
+}
+
+func (s *Server) GetListeningStats(w http.ResponseWriter, r *http.Request) error {
+    user, err := s.currentUser(r)
+    if err != nil {
+        return err
+    }
+
+    now := time.Now().In(userLocation(user.Timezone))
+    params := r.URL.Query()
+
+    var period Period
+    if value := params.Get("period"); value != "" {
+        period, err = ParsePeriod(value, now)
+    } else if params.Get("from") != "" {
+        period, err = ParseRange(params.Get("from"), params.Get("to"), now)
+    } else {
+        period, err = ParsePeriod("30d", now)
+    }
+
+    if err != nil || period.To.Sub(period.From) > time.Hour * 24 * maxListeningDays {
+        return fmt.Errorf(BAD_REQUEST_ERROR)
+    }
+
+    rows, err := s.authCfg.database.GetListening(r.Context(), database.GetListeningParams{
+        Uid: user.ID,
+        Since: period.From.UnixMilli(),
+        Until: period.To.UnixMilli(),
+        Source: params.Get("source"),
+    })
+
+    if err != nil {
+        s.log.Error("Getting Listening", "username", user.Username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    // Streaks only look back as far as the longest range allowed.
+    history, err := s.authCfg.database.GetListening(r.Context(), database.GetListeningParams{
+        Uid: user.ID,
+        Since: dayPeriod(now.AddDate(0, 0, -maxListeningDays)).From.UnixMilli(),
+        Until: now.UnixMilli(),
+    })
+
+    if err != nil {
+        s.log.Error("Getting Listening History", "username", user.Username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    listens := []ListenEvent{}
+    for _, row := range rows {
+        // The first quarter hour can start before a range like "30d" does;
+        // it is still inside the same local hour.
+        listens = append(listens, ListenEvent{ Timestamp: max(row.Start, period.From.UnixMilli()), Plays: row.Plays, Duration: row.Listened })
+    }
+
+    timestamps := []int64{}
+    for _, row := range history {
+        timestamps = append(timestamps, row.Start)
+    }
+
+    stats := buildListeningStats(listens, period)
+    stats.Streaks, stats.CurrentStreak = listeningStreaks(timestamps, now, listeningStreakCount)
+
+    encode(w, http.StatusOK, stats)
+    return nil
+}
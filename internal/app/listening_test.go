@@ -0,0 +1,106 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cg219/nowplaying/internal/database"
+)
+
+func TestBuildListeningStats(t *testing.T) {
+    la, err := time.LoadLocation("America/Los_Angeles")
+    if err != nil {
+        t.Skip("no tzdata")
+    }
+
+    at := func(day int, hour int) int64 { return time.Date(2025, 3, day, hour, 15, 0, 0, la).UnixMilli() }
+    minutes := func(n int64) int64 { return n * time.Minute.Milliseconds() }
+
+    period := Period{ From: time.Date(2025, 3, 10, 0, 0, 0, 0, la), To: time.Date(2025, 3, 13, 0, 0, 0, 0, la) }
+    stats := buildListeningStats([]ListenEvent{
+        { Timestamp: at(10, 23), Plays: 1, Duration: minutes(4) },
+        { Timestamp: at(10, 23), Plays: 1, Duration: minutes(20) },
+        { Timestamp: at(12, 8), Plays: 1, Duration: minutes(3) },
+        { Timestamp: at(13, 8), Plays: 1, Duration: minutes(3) },
+    }, period)
+
+    if stats.Plays != 3 || stats.Listened != minutes(27) {
+        t.Errorf("totals: got %d plays, %dms", stats.Plays, stats.Listened)
+    }
+
+    if len(stats.Daily) != 3 || stats.Daily[0].Minutes != 24 || stats.Daily[1].Plays != 0 || stats.Daily[2].Minutes != 3 {
+        t.Errorf("daily: got %+v", stats.Daily)
+    }
+
+    // The 11pm listens on Monday stay on Monday in Los Angeles.
+    if stats.Heatmap[0][23] != 24 || stats.Heatmap[2][8] != 3 || stats.Hours[23] != 24 || stats.Weekdays[0] != 24 {
+        t.Errorf("heatmap: got monday %v, hours %v", stats.Heatmap[0], stats.Hours)
+    }
+}
+
+func TestListeningTotalsKeepSeconds(t *testing.T) {
+    from := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+    listens := []ListenEvent{}
+
+    // Half a minute in each of four hours adds up to two minutes that day.
+    for hour := 0; hour < 4; hour++ {
+        listens = append(listens, ListenEvent{ Timestamp: from.Add(time.Hour * time.Duration(hour)).UnixMilli(), Plays: 1, Duration: 30000 })
+    }
+
+    stats := buildListeningStats(listens, Period{ From: from, To: from.AddDate(0, 0, 1) })
+
+    if stats.Heatmap[0][0] != 0 || stats.Weekdays[0] != 2 || stats.Daily[0].Minutes != 2 {
+        t.Errorf("got heatmap %v, weekdays %v, daily %+v", stats.Heatmap[0][:4], stats.Weekdays, stats.Daily)
+    }
+}
+
+func TestGetListening(t *testing.T) {
+    ctx := context.Background()
+    conn, db := newTestDB(t)
+
+    base := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+    for i, offset := range []time.Duration{ 0, time.Minute * 5, time.Minute * 20, time.Hour } {
+        addTestScrobble(t, conn, db, 1, Scrobble{ ArtistName: "Artist", TrackName: "Song", Timestamp: int(base.Add(offset).UnixMilli()) + i })
+    }
+
+    rows, err := db.GetListening(ctx, database.GetListeningParams{ Uid: 1, Since: base.UnixMilli(), Until: base.Add(time.Hour * 2).UnixMilli() })
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    want := []database.GetListeningRow{
+        { Start: base.UnixMilli(), Plays: 2, Listened: 400000 },
+        { Start: base.Add(time.Minute * 15).UnixMilli(), Plays: 1, Listened: 200000 },
+        { Start: base.Add(time.Hour).UnixMilli(), Plays: 1, Listened: 200000 },
+    }
+
+    if len(rows) != len(want) {
+        t.Fatalf("got %+v", rows)
+    }
+
+    for i := range want {
+        if rows[i] != want[i] {
+            t.Errorf("row %d: got %+v, want %+v", i, rows[i], want[i])
+        }
+    }
+}
+
+func TestListeningStreaks(t *testing.T) {
+    now := time.Date(2025, 3, 20, 12, 0, 0, 0, time.UTC)
+    day := func(d int) int64 { return time.Date(2025, 3, d, 9, 0, 0, 0, time.UTC).UnixMilli() }
+
+    streaks, current := listeningStreaks([]int64{ day(1), day(2), day(2), day(3), day(5), day(10), day(11), day(12), day(13), day(19) }, now, 2)
+
+    if len(streaks) != 2 || streaks[0].Days != 4 || streaks[0].Start != "2025-03-10" || streaks[1].Days != 3 {
+        t.Errorf("streaks: got %+v", streaks)
+    }
+
+    if current.Days != 1 || current.End != "2025-03-19" {
+        t.Errorf("current: got %+v", current)
+    }
+
+    if _, current := listeningStreaks([]int64{ day(1) }, now, 3); current.Days != 0 {
+        t.Errorf("expected no current streak, got %+v", current)
+    }
+}
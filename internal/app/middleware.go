@@ -27,6 +27,24 @@ func (s *Server) handle(h ...CandlerFunc) http.Handler {
                     }
                     return
 
+                case NOT_FOUND_ERROR:
+                    if err := encode(w, 404, ResponseError{ Success: false, Messaage: "Not Found", Code: NOT_FOUND }); err != nil {
+                        return500(w)
+                    }
+                    return
+
+                case BAD_REQUEST_ERROR:
+                    if err := encode(w, 400, ResponseError{ Success: false, Messaage: "Bad Request", Code: BAD_REQUEST }); err != nil {
+                        return500(w)
+                    }
+                    return
+
+                case TOO_MANY_REQUESTS_ERROR:
+                    if err := encode(w, 429, ResponseError{ Success: false, Messaage: "Too Many Requests", Code: TOO_MANY_REQUESTS }); err != nil {
+                        return500(w)
+                    }
+                    return
+
                 case REDIRECT_ERROR:
                     s.log.Info("Redirect Error")
                     return
@@ -51,7 +69,7 @@ func (s *Server) RedirectAuthenticated(redirect string, onAuth bool) CandlerFunc
     return func(w http.ResponseWriter, r *http.Request) error {
         cookie, err := r.Cookie("nowplaying")
         if err != nil {
-            s.log.Error("Cookie Retrieval", "cookie", "nowplaying", "method", "RedirectAuthenticated", "request", r, "error", err.Error())
+            s.log.Error("Cookie Retrieval", "cookie", "nowplaying", "method", "RedirectAuthenticated", "path", r.URL.Path, "error", err.Error())
             if !onAuth {
                 http.Redirect(w, r, redirect, http.StatusSeeOther)
                 return fmt.Errorf(REDIRECT_ERROR)
@@ -62,7 +80,7 @@ func (s *Server) RedirectAuthenticated(redirect string, onAuth bool) CandlerFunc
 
         value, err := base64.StdEncoding.DecodeString(cookie.Value)
         if err != nil {
-            s.log.Error("Base64 Decoding", "cookie", cookie.Value, "method", "RedirectAuthenticated", "request", r, "error", err.Error())
+            s.log.Error("Base64 Decoding", "cookie", cookie.Value, "method", "RedirectAuthenticated", "path", r.URL.Path, "error", err.Error())
             if !onAuth {
                 http.Redirect(w, r, redirect, http.StatusSeeOther)
                 return fmt.Errorf(REDIRECT_ERROR)
@@ -74,7 +92,7 @@ func (s *Server) RedirectAuthenticated(redirect string, onAuth bool) CandlerFunc
         var cookieValue webtoken.CookieAuthValue
         err = json.Unmarshal(value, &cookieValue)
         if err != nil {
-            s.log.Error("Invalid Cookie Value", "cookie", cookie.Value, "method", "RedirectAuthenticated", "request", r, "error", err.Error())
+            s.log.Error("Invalid Cookie Value", "cookie", cookie.Value, "method", "RedirectAuthenticated", "path", r.URL.Path, "error", err.Error())
             if !onAuth {
                 http.Redirect(w, r, redirect, http.StatusSeeOther)
                 return fmt.Errorf(REDIRECT_ERROR)
@@ -102,7 +120,11 @@ func (s *Server) RedirectAuthenticated(redirect string, onAuth bool) CandlerFunc
             }
         }
         if !ok && refresh != nil {
-            ctx = refresh(w)
+            ctx, err := refresh(w)
+            if err != nil {
+                return err
+            }
+
             updatedRequest := r.WithContext(ctx)
             *r = *updatedRequest
             s.authenticateRequest(r, username)
@@ -124,33 +146,76 @@ func (s *Server) RedirectAuthenticated(redirect string, onAuth bool) CandlerFunc
     }
 }
 
-func (s *Server) UserOnly(w http.ResponseWriter, r *http.Request) error {
-    cookie, err := r.Cookie("nowplaying")
+// APIKeyOnly authenticates requests from media servers, which can't hold a
+// session cookie. The key has to be sent in the np-apikey header.
+func (s *Server) APIKeyOnly(w http.ResponseWriter, r *http.Request) error {
+    return s.scrobbleKey(r, r.Header.Get("np-apikey"), "APIKeyOnly")
+}
+
+// PlexKeyOnly is APIKeyOnly for Plex, which can't set headers on its webhooks,
+// so the key may also be passed as the apikey query parameter. Keys in urls end
+// up in logs, so no other route accepts them.
+func (s *Server) PlexKeyOnly(w http.ResponseWriter, r *http.Request) error {
+    apikey := r.Header.Get("np-apikey")
+    if apikey == "" {
+        apikey = r.URL.Query().Get("apikey")
+    }
+
+    return s.scrobbleKey(r, apikey, "PlexKeyOnly")
+}
+
+func (s *Server) scrobbleKey(r *http.Request, apikey string, method string) error {
+    if apikey == "" {
+        return fmt.Errorf(AUTH_ERROR)
+    }
+
+    username, err := s.apiKeyUser(r.Context(), apikey, SCOPE_SCROBBLE)
     if err != nil {
-        if apikey := r.Header.Get("np-apikey"); apikey != "" {
-            username, err := s.authCfg.database.GetUserFromApiKey(r.Context(), apikey)
-            if err != nil {
-                s.log.Error("Invalid API Key", "key", apikey, "method", "UserOnly", "request", r, "error", err.Error())
-                return fmt.Errorf(AUTH_ERROR)
-            }
+        s.log.Error("Invalid API Key", "prefix", keyPrefix(apikey), "method", method, "error", err.Error())
+        return fmt.Errorf(AUTH_ERROR)
+    }
 
-            s.authenticateRequest(r, username)
-            return nil
+    s.authenticateRequest(r, username)
+    return nil
+}
+
+// UserOrKey lets a route be called with an np-apikey header instead of a
+// session, as long as the key carries scope. Routes without it only accept
+// sessions, so a leaked key can't be used to manage the account.
+func (s *Server) UserOrKey(scope string) CandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) error {
+        apikey := r.Header.Get("np-apikey")
+        if apikey == "" {
+            return s.UserOnly(w, r)
+        }
+
+        username, err := s.apiKeyUser(r.Context(), apikey, scope)
+        if err != nil {
+            s.log.Error("Invalid API Key", "prefix", keyPrefix(apikey), "scope", scope, "method", "UserOrKey", "error", err.Error())
+            return fmt.Errorf(AUTH_ERROR)
         }
 
-        s.log.Error("Cookie Retrieval", "cookie", "nowplaying", "method", "UserOnly", "request", r, "error", err.Error())
+        s.authenticateRequest(r, username)
+        return nil
+    }
+}
+
+func (s *Server) UserOnly(w http.ResponseWriter, r *http.Request) error {
+    cookie, err := r.Cookie("nowplaying")
+    if err != nil {
+        s.log.Error("Cookie Retrieval", "cookie", "nowplaying", "method", "UserOnly", "path", r.URL.Path, "error", err.Error())
         return fmt.Errorf(AUTH_ERROR)
     } else {
         value, err := base64.StdEncoding.DecodeString(cookie.Value)
         if err != nil {
-            s.log.Error("Base64 Decoding", "cookie", cookie.Value, "method", "UserOnly", "request", r, "error", err.Error())
+            s.log.Error("Base64 Decoding", "cookie", cookie.Value, "method", "UserOnly", "path", r.URL.Path, "error", err.Error())
             return fmt.Errorf(AUTH_ERROR)
         }
 
         var cookieValue webtoken.CookieAuthValue
         err = json.Unmarshal(value, &cookieValue)
         if err != nil {
-            s.log.Error("Invalid Cookie Value", "cookie", cookie.Value, "method", "UserOnly", "request", r, "error", err.Error())
+            s.log.Error("Invalid Cookie Value", "cookie", cookie.Value, "method", "UserOnly", "path", r.URL.Path, "error", err.Error())
             return fmt.Errorf(AUTH_ERROR)
         }
 
@@ -163,7 +228,11 @@ func (s *Server) UserOnly(w http.ResponseWriter, r *http.Request) error {
         }
 
         if !ok && refresh != nil {
-            ctx = refresh(w)
+            ctx, err := refresh(w)
+            if err != nil {
+                return err
+            }
+
             updatedRequest := r.WithContext(ctx)
             *r = *updatedRequest
             s.authenticateRequest(r, username)
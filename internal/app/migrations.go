@@ -0,0 +1,108 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+
+	"github.com/cg219/nowplaying/internal/database"
+	"github.com/pressly/goose/v3"
+)
+
+// Migrations that need Go run alongside the SQL ones in sql/migrations and
+// are numbered with them. The SQL migration just before each one points here,
+// since the goose CLI on its own only sees the .sql files.
+func init() {
+    goose.AddNamedMigrationContext("00027_hash_apikeys.go", hashApiKeys, nil)
+    goose.AddNamedMigrationContext("00030_add_session_uids.go", addSessionUids, nil)
+    goose.AddNamedMigrationContext("00034_split_scrobble_artists.go", splitScrobbleArtists, nil)
+}
+
+// hashApiKeys replaces the raw keys 00026 carried over with their hashes.
+func hashApiKeys(ctx context.Context, tx *sql.Tx) error {
+    db := database.New(tx)
+
+    rows, err := db.GetUnhashedApiKeys(ctx)
+    if err != nil {
+        return err
+    }
+
+    for _, row := range rows {
+        if err := db.SetApiKeyHash(ctx, database.SetApiKeyHashParams{ Hash: hashToken(row.Hash), ID: row.ID }); err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+// addSessionUids ties sessions from before 00029 to their user. The access
+// token's subject names the user; its signature doesn't matter here since the
+// row was written by us. Sessions that can't be tied to anyone are ended.
+func addSessionUids(ctx context.Context, tx *sql.Tx) error {
+    db := database.New(tx)
+
+    rows, err := db.GetUnattributedSessions(ctx)
+    if err != nil {
+        return err
+    }
+
+    for _, row := range rows {
+        username, ok := sessionUsername(row.Accesstoken)
+        if ok {
+            err = db.SetSessionUid(ctx, database.SetSessionUidParams{ Username: username, Accesstoken: row.Accesstoken, Refreshtoken: row.Refreshtoken })
+        } else {
+            err = db.DeleteUserSession(ctx, database.DeleteUserSessionParams{ Accesstoken: row.Accesstoken, Refreshtoken: row.Refreshtoken })
+        }
+
+        if err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+// splitScrobbleArtists credits every artist of scrobbles saved before
+// Normalize existed; 00020 only carried artist_name over as a single credit.
+// Comma separated names are split the way the charts used to split them, and
+// featured artists come out of the artist and track names as Normalize does.
+func splitScrobbleArtists(ctx context.Context, tx *sql.Tx) error {
+    db := database.New(tx)
+    n := NewNormalizer(nil)
+
+    rows, err := db.GetFeaturedScrobbles(ctx)
+    if err != nil {
+        return err
+    }
+
+    for _, row := range rows {
+        artists := []string{}
+        for _, credit := range n.Normalize(Scrobble{ ArtistName: row.ArtistName, TrackName: row.TrackName }).Artists {
+            for _, artist := range strings.Split(credit, ",") {
+                if artist = strings.TrimSpace(artist); artist != "" && !containsFold(artists, artist) {
+                    artists = append(artists, artist)
+                }
+            }
+        }
+
+        if len(artists) == 0 || len(artists) == 1 && artists[0] == row.ArtistName {
+            continue
+        }
+
+        if !slices.Contains(artists, row.ArtistName) {
+            if err := db.RemoveScrobbleArtist(ctx, database.RemoveScrobbleArtistParams{ ScrobbleID: row.ID, Name: row.ArtistName }); err != nil {
+                return err
+            }
+        }
+
+        for i, artist := range artists {
+            if err := db.AddScrobbleArtist(ctx, database.AddScrobbleArtistParams{ ScrobbleID: row.ID, Name: artist, Position: int64(i) }); err != nil {
+                return err
+            }
+        }
+    }
+
+    return nil
+}
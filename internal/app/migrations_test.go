@@ -0,0 +1,75 @@
+package app
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestSplitScrobbleArtists(t *testing.T) {
+    ctx := context.Background()
+    conn, db := newTestDB(t)
+
+    // Rows as 00020 left them: artist_name as the only credit.
+    _, err := conn.Exec(`
+        INSERT INTO scrobbles(id, artist_name, track_name, duration, timestamp) VALUES
+            (1, 'Kanye West feat. Jay-Z, Rick Ross', 'Monster', 200000, 1000),
+            (2, 'Kanye West', 'Monster (feat. Nicki Minaj & Bon Iver)', 200000, 2000),
+            (3, 'Daft Punk', 'One More Time', 200000, 3000),
+            (4, 'Kanye West, Jay-Z', 'Otis (feat. Otis Redding)', 200000, 4000),
+            (5, 'Soft Cell', 'Aftermath (Left Behind)', 200000, 5000);
+        INSERT INTO scrobble_artists(scrobble_id, name, position) VALUES
+            (1, 'Kanye West feat. Jay-Z, Rick Ross', 0),
+            (2, 'Kanye West', 0),
+            (3, 'Daft Punk', 0),
+            (4, 'Kanye West, Jay-Z', 0),
+            (5, 'Soft Cell', 0);
+    `)
+
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    rows, err := db.GetFeaturedScrobbles(ctx)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    ids := []int64{}
+    for _, row := range rows {
+        ids = append(ids, row.ID)
+    }
+
+    if !slices.Equal(ids, []int64{ 1, 2, 4 }) {
+        t.Errorf("featured scrobbles: got %v", ids)
+    }
+
+    tx, err := conn.BeginTx(ctx, nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if err := splitScrobbleArtists(ctx, tx); err != nil {
+        t.Fatal(err)
+    }
+
+    if err := tx.Commit(); err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        id int64
+        want []string
+    }{
+        { 1, []string{ "Kanye West", "Jay-Z", "Rick Ross" } },
+        { 2, []string{ "Kanye West", "Nicki Minaj", "Bon Iver" } },
+        { 3, []string{ "Daft Punk" } },
+        { 4, []string{ "Kanye West", "Jay-Z", "Otis Redding" } },
+    }
+
+    for _, tt := range tests {
+        if got := testCredits(t, db, tt.id); !slices.Equal(got, tt.want) {
+            t.Errorf("scrobble %d credits: got %q, want %q", tt.id, got, tt.want)
+        }
+    }
+}
@@ -0,0 +1,230 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"strings"
+
+	"github.com/cg219/nowplaying/internal/database"
+)
+
+const (
+    MATCH_EXACT = "exact"
+    MATCH_REGEX = "regex"
+)
+
+var (
+    remasterSuffix = regexp.MustCompile(`(?i)\s+(-\s+(\d{4}\s+)?(digital\s+)?remaster(ed)?(\s+\d{4})?(\s+version)?|[(\[](\d{4}\s+)?(digital\s+)?remaster(ed)?(\s+\d{4})?(\s+version)?[)\]])\s*$`)
+    featuring = regexp.MustCompile(`(?i)\s*[(\[](feat\.?|ft\.?|featuring)\s+([^)\]]+)[)\]]`)
+    artistFeaturing = regexp.MustCompile(`(?i)\s+(feat\.?|ft\.?|featuring)\s+(.+)$`)
+    featuredSeparator = regexp.MustCompile(`\s*,\s*|\s+&\s+`)
+)
+
+// RewriteRule renames an artist, album or track at scrobble time. Exact rules
+// replace a whole value (ignoring case); regex rules replace every match.
+type RewriteRule struct {
+    Id int64 `json:"id"`
+    Field string `json:"field"`
+    Match string `json:"match"`
+    Pattern string `json:"pattern"`
+    Replacement string `json:"replacement"`
+}
+
+type Normalizer struct {
+    rules []RewriteRule
+    patterns []*regexp.Regexp
+}
+
+func NewNormalizer(rules []RewriteRule) *Normalizer {
+    n := &Normalizer{}
+
+    for _, rule := range rules {
+        var re *regexp.Regexp
+
+        if rule.Match == MATCH_REGEX {
+            compiled, err := regexp.Compile(rule.Pattern)
+            if err != nil {
+                continue
+            }
+
+            re = compiled
+        }
+
+        n.rules = append(n.rules, rule)
+        n.patterns = append(n.patterns, re)
+    }
+
+    return n
+}
+
+// Normalize cleans a scrobble up before it is checked and saved: remaster
+// suffixes come off track and album names, "(feat. X)" in the title and
+// "A feat. X" in the artist move into Artists, and then the user's rewrite
+// rules run. Artists always ends up holding the main artist first.
+func (n *Normalizer) Normalize(sc Scrobble) Scrobble {
+    var featured []string
+    if match := artistFeaturing.FindStringSubmatch(sc.ArtistName); match != nil {
+        featured = splitFeatured(match[2])
+        sc.ArtistName = artistFeaturing.ReplaceAllString(sc.ArtistName, "")
+    }
+
+    artists := []string{ sc.ArtistName }
+    if len(sc.Artists) > 0 {
+        artists = append([]string{}, sc.Artists...)
+    }
+
+    artists = append(artists, featured...)
+
+    if match := featuring.FindStringSubmatch(sc.TrackName); match != nil {
+        artists = append(artists, splitFeatured(match[2])...)
+        sc.TrackName = featuring.ReplaceAllString(sc.TrackName, "")
+    }
+
+    sc.TrackName = strings.TrimSpace(remasterSuffix.ReplaceAllString(sc.TrackName, ""))
+    sc.AlbumName = strings.TrimSpace(remasterSuffix.ReplaceAllString(sc.AlbumName, ""))
+
+    // A rule that rewrites the artist or track to nothing is ignored; both
+    // are required.
+    if artist := n.rewrite(RENAME_ARTIST, sc.ArtistName); artist != "" {
+        sc.ArtistName = artist
+    }
+
+    if track := n.rewrite(RENAME_TRACK, sc.TrackName); track != "" {
+        sc.TrackName = track
+    }
+
+    sc.AlbumArtist = n.rewrite(RENAME_ARTIST, sc.AlbumArtist)
+    sc.AlbumName = n.rewrite(RENAME_ALBUM, sc.AlbumName)
+
+    sc.Artists = []string{ sc.ArtistName }
+    for _, artist := range artists {
+        artist = n.rewrite(RENAME_ARTIST, strings.TrimSpace(artist))
+        if artist != "" && !containsFold(sc.Artists, artist) {
+            sc.Artists = append(sc.Artists, artist)
+        }
+    }
+
+    return sc
+}
+
+// splitFeatured breaks a featuring credit like "B, C & D" into its artists.
+func splitFeatured(list string) []string {
+    artists := []string{}
+    for _, artist := range featuredSeparator.Split(list, -1) {
+        if artist = strings.TrimSpace(artist); artist != "" {
+            artists = append(artists, artist)
+        }
+    }
+
+    return artists
+}
+
+func (n *Normalizer) rewrite(field string, value string) string {
+    if value == "" {
+        return value
+    }
+
+    for i, rule := range n.rules {
+        if rule.Field != field {
+            continue
+        }
+
+        if re := n.patterns[i]; re != nil {
+            value = re.ReplaceAllString(value, rule.Replacement)
+        } else if strings.EqualFold(value, rule.Pattern) {
+            value = rule.Replacement
+        }
+    }
+
+    return strings.TrimSpace(value)
+}
+
+func (r RewriteRule) Valid() bool {
+    if r.Field != RENAME_ARTIST && r.Field != RENAME_ALBUM && r.Field != RENAME_TRACK {
+        return false
+    }
+
+    switch r.Match {
+    case MATCH_EXACT:
+        return r.Pattern != "" && r.Replacement != ""
+    case MATCH_REGEX:
+        _, err := regexp.Compile(r.Pattern)
+        return r.Pattern != "" && err == nil
+    }
+
+    return false
+}
+
+func rewriteRuleFromDB(row database.RewriteRule) RewriteRule {
+    return RewriteRule{
+        Id: row.ID,
+        Field: row.Field,
+        Match: row.MatchType,
+        Pattern: row.Pattern,
+        Replacement: row.Replacement,
+    }
+}
+
+func loadNormalizer(ctx context.Context, db *database.Queries, uid int64) (*Normalizer, error) {
+    rows, err := db.GetRewriteRules(ctx, uid)
+    if err != nil {
+        return nil, err
+    }
+
+    rules := []RewriteRule{}
+    for _, row := range rows {
+        rules = append(rules, rewriteRuleFromDB(row))
+    }
+
+    return NewNormalizer(rules), nil
+}
+
+// saveScrobble stores a scrobble along with a credit for each of its artists,
+// all in one transaction so a scrobble is never left without its credits.
+func saveScrobble(ctx context.Context, conn *sql.DB, db *database.Queries, sc Scrobble) error {
+    tx, err := conn.BeginTx(ctx, nil)
+    if err != nil {
+        return err
+    }
+
+    defer tx.Rollback()
+
+    if err := insertScrobble(ctx, db.WithTx(tx), sc); err != nil {
+        return err
+    }
+
+    return tx.Commit()
+}
+
+// insertScrobble is saveScrobble for callers already inside a transaction.
+func insertScrobble(ctx context.Context, db *database.Queries, sc Scrobble) error {
+    id, err := db.SaveScrobble(ctx, scrobbleToParams(sc))
+    if err != nil {
+        return err
+    }
+
+    artists := sc.Artists
+    if len(artists) == 0 {
+        artists = []string{ sc.ArtistName }
+    }
+
+    for i, artist := range artists {
+        err := db.AddScrobbleArtist(ctx, database.AddScrobbleArtistParams{ ScrobbleID: id, Name: artist, Position: int64(i) })
+        if err != nil {
+            return err
+        }
+    }
+
+    return nil
+}
+
+func containsFold(list []string, value string) bool {
+    for _, v := range list {
+        if strings.EqualFold(v, value) {
+            return true
+        }
+    }
+
+    return false
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+    n := NewNormalizer([]RewriteRule{
+        { Field: RENAME_ARTIST, Match: MATCH_EXACT, Pattern: "tyler the creator", Replacement: "Tyler, The Creator" },
+        { Field: RENAME_ALBUM, Match: MATCH_REGEX, Pattern: `\s*\(Deluxe( Edition)?\)$`, Replacement: "" },
+        { Field: RENAME_TRACK, Match: MATCH_REGEX, Pattern: `(`, Replacement: "" },
+        { Field: RENAME_ARTIST, Match: MATCH_REGEX, Pattern: `^.*Topic$`, Replacement: "" },
+    })
+
+    tests := []struct {
+        in Scrobble
+        artist string
+        artists []string
+        track string
+        album string
+    }{
+        {
+            Scrobble{ ArtistName: "The Beatles", TrackName: "Something - Remastered 2009", AlbumName: "Abbey Road (Remastered)" },
+            "The Beatles", []string{ "The Beatles" }, "Something", "Abbey Road",
+        },
+        {
+            Scrobble{ ArtistName: "Tyler The Creator", TrackName: "EARFQUAKE (feat. Playboi Carti)", AlbumName: "IGOR (Deluxe Edition)" },
+            "Tyler, The Creator", []string{ "Tyler, The Creator", "Playboi Carti" }, "EARFQUAKE", "IGOR",
+        },
+        {
+            Scrobble{ ArtistName: "Crosby, Stills, Nash & Young", Artists: []string{ "Crosby, Stills, Nash & Young", "Joni Mitchell" }, TrackName: "Woodstock - 2005 Remaster" },
+            "Crosby, Stills, Nash & Young", []string{ "Crosby, Stills, Nash & Young", "Joni Mitchell" }, "Woodstock", "",
+        },
+        {
+            Scrobble{ ArtistName: "Kanye West feat. Jay-Z, Rick Ross", TrackName: "Monster (feat. Nicki Minaj & Bon Iver)" },
+            "Kanye West", []string{ "Kanye West", "Jay-Z", "Rick Ross", "Nicki Minaj", "Bon Iver" }, "Monster", "",
+        },
+        {
+            Scrobble{ ArtistName: "Artist - Topic", TrackName: "Song" },
+            "Artist - Topic", []string{ "Artist - Topic" }, "Song", "",
+        },
+    }
+
+    for _, tt := range tests {
+        sc := n.Normalize(tt.in)
+
+        if sc.ArtistName != tt.artist || sc.TrackName != tt.track || sc.AlbumName != tt.album {
+            t.Errorf("%q: got %q / %q / %q", tt.in.TrackName, sc.ArtistName, sc.TrackName, sc.AlbumName)
+        }
+
+        if !slices.Equal(sc.Artists, tt.artists) {
+            t.Errorf("%q: got artists %q, want %q", tt.in.TrackName, sc.Artists, tt.artists)
+        }
+    }
+}
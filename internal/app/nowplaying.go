@@ -0,0 +1,104 @@
+package app
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const nowPlayingGrace = time.Second * 30
+
+type NowPlaying struct {
+    ArtistName string `json:"artistName"`
+    TrackName string `json:"trackName"`
+    AlbumName string `json:"albumName"`
+    Duration int `json:"duration"`
+    Progress int `json:"progress"`
+    Source string `json:"source"`
+    UpdatedAt int64 `json:"updatedAt"`
+}
+
+// NowPlayingTracker keeps the latest in-progress track for each user, fed by the
+// same packs that go through Scrobbler.Scrobble.
+type NowPlayingTracker struct {
+    tracks map[string]nowPlayingEntry
+    mu sync.RWMutex
+}
+
+type nowPlayingEntry struct {
+    scrobble Scrobble
+    seen time.Time
+}
+
+func NewNowPlayingTracker() *NowPlayingTracker {
+    return &NowPlayingTracker{
+        tracks: make(map[string]nowPlayingEntry),
+    }
+}
+
+// Update records the current track for a user and reports whether it differs
+// from the one seen before (or the previous one has already finished).
+func (t *NowPlayingTracker) Update(username string, sc Scrobble) bool {
+    key := strings.ToLower(username)
+    now := time.Now()
+
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    prev, ok := t.tracks[key]
+    t.tracks[key] = nowPlayingEntry{ scrobble: sc, seen: now }
+
+    if !ok || prev.expired(now) {
+        return true
+    }
+
+    return !strings.EqualFold(prev.scrobble.ArtistName, sc.ArtistName) || !strings.EqualFold(prev.scrobble.TrackName, sc.TrackName)
+}
+
+// Clear drops the user's current track if it came from source, so a stop
+// from one player doesn't hide what another is playing.
+func (t *NowPlayingTracker) Clear(username string, source string) {
+    key := strings.ToLower(username)
+
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    if entry, ok := t.tracks[key]; ok && strings.EqualFold(entry.scrobble.Source, source) {
+        delete(t.tracks, key)
+    }
+}
+
+func (t *NowPlayingTracker) Get(username string) (NowPlaying, bool) {
+    t.mu.RLock()
+    entry, ok := t.tracks[strings.ToLower(username)]
+    t.mu.RUnlock()
+
+    now := time.Now()
+    if !ok || entry.expired(now) {
+        return NowPlaying{}, false
+    }
+
+    progress := entry.scrobble.Progress + int(now.Sub(entry.seen).Milliseconds())
+    if entry.scrobble.Duration > 0 && progress > entry.scrobble.Duration {
+        progress = entry.scrobble.Duration
+    }
+
+    return NowPlaying{
+        ArtistName: entry.scrobble.ArtistName,
+        TrackName: entry.scrobble.TrackName,
+        AlbumName: entry.scrobble.AlbumName,
+        Duration: entry.scrobble.Duration,
+        Progress: progress,
+        Source: entry.scrobble.Source,
+        UpdatedAt: entry.seen.UnixMilli(),
+    }, true
+}
+
+func (e nowPlayingEntry) expired(now time.Time) bool {
+    remaining := time.Duration(e.scrobble.Duration - e.scrobble.Progress) * time.Millisecond
+    if remaining < 0 {
+        remaining = 0
+    }
+
+    return now.After(e.seen.Add(remaining + nowPlayingGrace))
+}
@@ -0,0 +1,20 @@
+package app
+
+import (
+	"testing"
+)
+
+func TestNowPlayingClear(t *testing.T) {
+    tracker := NewNowPlayingTracker()
+    tracker.Update("Tester", Scrobble{ ArtistName: "Artist", TrackName: "Song", Duration: 200000, Source: "plex" })
+
+    tracker.Clear("tester", "jellyfin")
+    if _, ok := tracker.Get("tester"); !ok {
+        t.Fatal("a stop from another source cleared the track")
+    }
+
+    tracker.Clear("tester", "Plex")
+    if _, ok := tracker.Get("tester"); ok {
+        t.Fatal("expected the track to be cleared")
+    }
+}
@@ -0,0 +1,169 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+    PERIOD_DAY = "day"
+    PERIOD_WEEK = "week"
+    PERIOD_MONTH = "month"
+    PERIOD_YEAR = "year"
+)
+
+var (
+    isoWeek = regexp.MustCompile(`^(\d{4})-w(\d{2})$`)
+    rolling = regexp.MustCompile(`^(\d{1,4})([hd])$`)
+)
+
+// Period is a half-open range of time, From inclusive and To exclusive.
+type Period struct {
+    From time.Time
+    To time.Time
+}
+
+func (p Period) Contains(t time.Time) bool {
+    return !t.Before(p.From) && t.Before(p.To)
+}
+
+// ParsePeriod turns a period name into a range. Calendar boundaries fall at
+// midnight in now's location, so pass now in the user's time zone. It
+// understands:
+//
+//   day, week, month, year                 the calendar period containing now (weeks start Monday)
+//   today, this-week, this-month, this-year  the same as above
+//   24h, 7d                                a rolling window ending now
+//   2024, 2025-03, 2025-W10, 2025-03-14    a calendar year, month, ISO week or day
+func ParsePeriod(value string, now time.Time) (Period, error) {
+    loc := now.Location()
+    value = strings.ToLower(strings.TrimSpace(value))
+
+    switch value {
+    case PERIOD_DAY, "today":
+        return dayPeriod(now), nil
+    case PERIOD_WEEK, "this-week":
+        year, week := now.ISOWeek()
+        return isoWeekPeriod(year, week, loc), nil
+    case PERIOD_MONTH, "this-month":
+        from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+        return Period{ From: from, To: from.AddDate(0, 1, 0) }, nil
+    case PERIOD_YEAR, "this-year":
+        from := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, loc)
+        return Period{ From: from, To: from.AddDate(1, 0, 0) }, nil
+    }
+
+    if match := rolling.FindStringSubmatch(value); match != nil {
+        n, _ := strconv.Atoi(match[1])
+        if n == 0 {
+            return Period{}, fmt.Errorf("invalid period: %s", value)
+        }
+
+        if match[2] == "h" {
+            return Period{ From: now.Add(-time.Hour * time.Duration(n)), To: now }, nil
+        }
+
+        return Period{ From: now.AddDate(0, 0, -n), To: now }, nil
+    }
+
+    if match := isoWeek.FindStringSubmatch(value); match != nil {
+        year, _ := strconv.Atoi(match[1])
+        week, _ := strconv.Atoi(match[2])
+
+        if week < 1 || week > isoWeeksIn(year, loc) {
+            return Period{}, fmt.Errorf("invalid week: %s", value)
+        }
+
+        return isoWeekPeriod(year, week, loc), nil
+    }
+
+    if t, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+        return dayPeriod(t), nil
+    }
+
+    if t, err := time.ParseInLocation("2006-01", value, loc); err == nil {
+        return Period{ From: t, To: t.AddDate(0, 1, 0) }, nil
+    }
+
+    if t, err := time.ParseInLocation("2006", value, loc); err == nil {
+        return Period{ From: t, To: t.AddDate(1, 0, 0) }, nil
+    }
+
+    return Period{}, fmt.Errorf("invalid period: %s", value)
+}
+
+// ParseRange builds a period from explicit bounds. Each bound is either an
+// RFC3339 time or a date; a date for to includes that whole day.
+func ParseRange(from string, to string, now time.Time) (Period, error) {
+    p := Period{ To: now }
+
+    if from != "" {
+        t, err := parseBound(from, now.Location())
+        if err != nil {
+            return p, err
+        }
+
+        p.From = t
+    }
+
+    if to != "" {
+        t, err := parseBound(to, now.Location())
+        if err != nil {
+            return p, err
+        }
+
+        if len(to) == len("2006-01-02") {
+            t = t.AddDate(0, 0, 1)
+        }
+
+        p.To = t
+    }
+
+    if !p.From.Before(p.To) {
+        return p, fmt.Errorf("from must be before to")
+    }
+
+    return p, nil
+}
+
+func parseBound(value string, loc *time.Location) (time.Time, error) {
+    if t, err := time.Parse(time.RFC3339, value); err == nil {
+        return t, nil
+    }
+
+    return time.ParseInLocation("2006-01-02", value, loc)
+}
+
+func dayPeriod(t time.Time) Period {
+    from := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+    return Period{ From: from, To: from.AddDate(0, 0, 1) }
+}
+
+// isoWeekPeriod returns the Monday-to-Monday range of an ISO week. Week 1 is
+// the week holding the year's first Thursday, so it always contains Jan 4th.
+func isoWeekPeriod(year int, week int, loc *time.Location) Period {
+    jan4 := time.Date(year, 1, 4, 0, 0, 0, 0, loc)
+    offset := (int(jan4.Weekday()) + 6) % 7
+    from := jan4.AddDate(0, 0, -offset + (week - 1) * 7)
+
+    return Period{ From: from, To: from.AddDate(0, 0, 7) }
+}
+
+// userLocation loads a user's time zone, falling back to UTC for anything
+// that isn't a valid IANA name.
+func userLocation(timezone string) *time.Location {
+    loc, err := time.LoadLocation(timezone)
+    if err != nil || timezone == "" {
+        return time.UTC
+    }
+
+    return loc
+}
+
+func isoWeeksIn(year int, loc *time.Location) int {
+    _, week := time.Date(year, 12, 28, 0, 0, 0, 0, loc).ISOWeek()
+    return week
+}
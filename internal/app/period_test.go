@@ -0,0 +1,99 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePeriod(t *testing.T) {
+    now := time.Date(2025, 3, 14, 15, 30, 0, 0, time.UTC)
+    day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+
+    tests := []struct {
+        value string
+        from time.Time
+        to time.Time
+    }{
+        { "24h", now.Add(-time.Hour * 24), now },
+        { "7d", day(2025, 3, 7).Add(time.Hour * 15 + time.Minute * 30), now },
+        { "day", day(2025, 3, 14), day(2025, 3, 15) },
+        { "week", day(2025, 3, 10), day(2025, 3, 17) },
+        { "today", day(2025, 3, 14), day(2025, 3, 15) },
+        { "this-week", day(2025, 3, 10), day(2025, 3, 17) },
+        { "this-month", day(2025, 3, 1), day(2025, 4, 1) },
+        { "this-year", day(2025, 1, 1), day(2026, 1, 1) },
+        { "2024", day(2024, 1, 1), day(2025, 1, 1) },
+        { "2025-03", day(2025, 3, 1), day(2025, 4, 1) },
+        { "2025-02-28", day(2025, 2, 28), day(2025, 3, 1) },
+        { "2025-W01", day(2024, 12, 30), day(2025, 1, 6) },
+        { "2020-w53", day(2020, 12, 28), day(2021, 1, 4) },
+    }
+
+    for _, tt := range tests {
+        p, err := ParsePeriod(tt.value, now)
+        if err != nil {
+            t.Errorf("%s: %s", tt.value, err)
+            continue
+        }
+
+        if !p.From.Equal(tt.from) || !p.To.Equal(tt.to) {
+            t.Errorf("%s: got %s - %s, want %s - %s", tt.value, p.From, p.To, tt.from, tt.to)
+        }
+    }
+
+    for _, value := range []string{ "", "fortnight", "0d", "2025-13", "2025-W54", "2021-W53" } {
+        if _, err := ParsePeriod(value, now); err == nil {
+            t.Errorf("%q: expected an error", value)
+        }
+    }
+}
+
+func TestParsePeriodInZone(t *testing.T) {
+    la, err := time.LoadLocation("America/Los_Angeles")
+    if err != nil {
+        t.Skip("no tzdata")
+    }
+
+    // 10pm on Sunday in Los Angeles is already Monday in UTC.
+    now := time.Date(2025, 3, 17, 5, 0, 0, 0, time.UTC).In(la)
+
+    p, err := ParsePeriod(PERIOD_DAY, now)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if want := time.Date(2025, 3, 16, 0, 0, 0, 0, la); !p.From.Equal(want) {
+        t.Errorf("day: got %s, want %s", p.From, want)
+    }
+
+    p, _ = ParsePeriod(PERIOD_WEEK, now)
+    if want := time.Date(2025, 3, 10, 0, 0, 0, 0, la); !p.From.Equal(want) || !p.Contains(now) {
+        t.Errorf("week: got %s - %s, want it to start %s", p.From, p.To, want)
+    }
+
+    if userLocation("Not/AZone") != time.UTC || userLocation("") != time.UTC {
+        t.Error("expected invalid zones to fall back to UTC")
+    }
+}
+
+func TestParseRange(t *testing.T) {
+    now := time.Date(2025, 3, 14, 15, 30, 0, 0, time.UTC)
+
+    p, err := ParseRange("2025-03-01", "2025-03-07", now)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if !p.Contains(time.Date(2025, 3, 7, 23, 59, 0, 0, time.UTC)) || p.Contains(time.Date(2025, 3, 8, 0, 0, 0, 0, time.UTC)) {
+        t.Errorf("expected the to date to be included: %s - %s", p.From, p.To)
+    }
+
+    p, err = ParseRange("2025-03-01T12:00:00Z", "", now)
+    if err != nil || !p.To.Equal(now) {
+        t.Errorf("expected an open range to end now, got %s (%v)", p.To, err)
+    }
+
+    if _, err := ParseRange("2025-03-07", "2025-03-01", now); err == nil {
+        t.Error("expected an error for a reversed range")
+    }
+}
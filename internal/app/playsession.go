@@ -0,0 +1,117 @@
+package app
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+    // playSeekTolerance absorbs polling jitter when deciding whether progress
+    // moved with the clock or jumped.
+    playSeekTolerance = time.Second * 2
+    // maxUnseenPlay caps how much of a track's progress is credited when it is
+    // first seen, since it most likely started between two polls.
+    maxUnseenPlay = time.Second * 10
+)
+
+// PlayerState is one poll of a player: the track, how far into it the player
+// is and whether it is playing. A nil PlayerState means nothing is playing.
+type PlayerState struct {
+    Track Scrobble
+    Progress int
+    Playing bool
+}
+
+// PlaySession follows one user's player across polls. It only credits progress
+// that moved in step with the clock, so pauses, seeks and skips don't count as
+// listening, and it emits a single scrobble once the play is over.
+type PlaySession struct {
+    current *play
+}
+
+type play struct {
+    track Scrobble
+    started time.Time
+    listened int
+    progress int
+    seen time.Time
+}
+
+// Observe feeds the session a poll taken at now. When that poll ends the
+// previous play (the track changed, started over or stopped), the finished play
+// is returned with its start time as Timestamp and the time actually listened
+// as Progress, both in milliseconds.
+func (p *PlaySession) Observe(state *PlayerState, now time.Time) (Scrobble, bool) {
+    if state == nil {
+        return p.Finish()
+    }
+
+    cur := p.current
+    if cur == nil {
+        p.start(state, now)
+        return Scrobble{}, false
+    }
+
+    if !samePlayTrack(cur.track, state.Track) {
+        sc, ok := p.Finish()
+        p.start(state, now)
+        return sc, ok
+    }
+
+    elapsed := int(now.Sub(cur.seen).Milliseconds())
+    tolerance := int(playSeekTolerance.Milliseconds())
+    delta := state.Progress - cur.progress
+
+    switch {
+    case delta < -tolerance && state.Progress <= elapsed + tolerance:
+        // Back at the start: repeat-one or the user restarted the track.
+        sc, ok := p.Finish()
+        p.start(state, now)
+        return sc, ok
+    case delta >= 0 && delta <= elapsed + tolerance:
+        cur.listened += delta
+    }
+
+    cur.track = state.Track
+    cur.progress = state.Progress
+    cur.seen = now
+
+    return Scrobble{}, false
+}
+
+// Finish ends the current play, if there is one that was listened to at all.
+func (p *PlaySession) Finish() (Scrobble, bool) {
+    cur := p.current
+    p.current = nil
+
+    if cur == nil || cur.listened <= 0 {
+        return Scrobble{}, false
+    }
+
+    sc := cur.track
+    sc.Timestamp = int(cur.started.UnixMilli())
+    sc.Progress = cur.listened
+
+    return sc, true
+}
+
+func (p *PlaySession) start(state *PlayerState, now time.Time) {
+    listened := 0
+    if state.Playing {
+        listened = min(state.Progress, int(maxUnseenPlay.Milliseconds()))
+    }
+
+    p.current = &play{
+        track: state.Track,
+        started: now.Add(-time.Duration(state.Progress) * time.Millisecond),
+        listened: listened,
+        progress: state.Progress,
+        seen: now,
+    }
+}
+
+func samePlayTrack(a Scrobble, b Scrobble) bool {
+    return strings.EqualFold(a.ArtistName, b.ArtistName) &&
+        strings.EqualFold(a.TrackName, b.TrackName) &&
+        strings.EqualFold(a.AlbumName, b.AlbumName)
+}
@@ -0,0 +1,75 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlaySession(t *testing.T) {
+    song := Scrobble{ ArtistName: "Artist", TrackName: "Song", Duration: 200000 }
+    other := Scrobble{ ArtistName: "Artist", TrackName: "Other", Duration: 180000 }
+    start := time.UnixMilli(1700000000000)
+    at := func(seconds int) time.Time { return start.Add(time.Duration(seconds) * time.Second) }
+
+    t.Run("pause and seek", func(t *testing.T) {
+        var session PlaySession
+
+        polls := []struct {
+            seconds int
+            progress int
+            playing bool
+        }{
+            { 0, 1000, true },
+            { 4, 5000, true },
+            { 8, 7000, false },
+            { 60, 7000, false },
+            { 64, 11000, true },
+            { 68, 150000, true },
+            { 72, 154000, true },
+        }
+
+        for _, poll := range polls {
+            if _, ok := session.Observe(&PlayerState{ Track: song, Progress: poll.progress, Playing: poll.playing }, at(poll.seconds)); ok {
+                t.Fatalf("unexpected scrobble at %ds", poll.seconds)
+            }
+        }
+
+        sc, ok := session.Observe(&PlayerState{ Track: other, Progress: 1000, Playing: true }, at(76))
+        if !ok {
+            t.Fatal("expected a scrobble when the track changed")
+        }
+
+        if sc.Progress != 15000 {
+            t.Errorf("listened: got %d, want 15000", sc.Progress)
+        }
+
+        if want := int(start.UnixMilli()) - 1000; sc.Timestamp != want {
+            t.Errorf("timestamp: got %d, want %d", sc.Timestamp, want)
+        }
+    })
+
+    t.Run("replay", func(t *testing.T) {
+        var session PlaySession
+
+        session.Observe(&PlayerState{ Track: song, Progress: 190000, Playing: true }, at(0))
+        session.Observe(&PlayerState{ Track: song, Progress: 194000, Playing: true }, at(4))
+
+        sc, ok := session.Observe(&PlayerState{ Track: song, Progress: 2000, Playing: true }, at(10))
+        if !ok {
+            t.Fatal("expected a scrobble when the track started over")
+        }
+
+        if sc.Progress != 14000 {
+            t.Errorf("listened: got %d, want 14000", sc.Progress)
+        }
+
+        sc, ok = session.Observe(nil, at(14))
+        if !ok || sc.Timestamp != int(at(8).UnixMilli()) {
+            t.Errorf("expected the replay to end when playback stopped, got %+v", sc)
+        }
+
+        if _, ok := session.Observe(nil, at(18)); ok {
+            t.Error("expected only one scrobble per play")
+        }
+    })
+}
@@ -0,0 +1,53 @@
+package app
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter allows up to limit hits per key in each fixed window. Counts
+// live in memory, so they reset on restart.
+type RateLimiter struct {
+    limit int
+    window time.Duration
+    hits map[string]*rateWindow
+    mutex sync.Mutex
+}
+
+type rateWindow struct {
+    start time.Time
+    count int
+}
+
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+    return &RateLimiter{
+        limit: limit,
+        window: window,
+        hits: make(map[string]*rateWindow),
+    }
+}
+
+// Allow counts a hit against key and reports whether it is within the limit.
+func (l *RateLimiter) Allow(key string, now time.Time) bool {
+    l.mutex.Lock()
+    defer l.mutex.Unlock()
+
+    for k, w := range l.hits {
+        if now.Sub(w.start) >= l.window {
+            delete(l.hits, k)
+        }
+    }
+
+    w, ok := l.hits[key]
+    if !ok {
+        w = &rateWindow{ start: now }
+        l.hits[key] = w
+    }
+
+    if w.count >= l.limit {
+        return false
+    }
+
+    w.count++
+    return true
+}
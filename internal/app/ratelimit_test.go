@@ -0,0 +1,31 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+    limiter := NewRateLimiter(2, time.Minute)
+    now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+    if !limiter.Allow("a", now) || !limiter.Allow("a", now.Add(time.Second)) {
+        t.Fatalf("first two hits should be allowed")
+    }
+
+    if limiter.Allow("a", now.Add(time.Second * 2)) {
+        t.Errorf("third hit in the window should be limited")
+    }
+
+    if !limiter.Allow("b", now.Add(time.Second * 2)) {
+        t.Errorf("keys should be limited separately")
+    }
+
+    if !limiter.Allow("a", now.Add(time.Minute + time.Second * 2)) {
+        t.Errorf("a new window should allow hits again")
+    }
+
+    if len(limiter.hits) != 1 {
+        t.Errorf("expired windows should be dropped, got %d", len(limiter.hits))
+    }
+}
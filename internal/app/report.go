@@ -0,0 +1,407 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/cg219/nowplaying/internal/database"
+)
+
+const (
+    reportListSize = 10
+    reportSlugLength = 12
+)
+
+//go:embed templates/report.html
+var reportHTML string
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+    "minutes": func(ms int64) int64 { return ms / time.Minute.Milliseconds() },
+    "date": func(ms int64, timezone string) string { return time.UnixMilli(ms).In(userLocation(timezone)).Format("Jan 2") },
+    "month": func(month string) string {
+        t, err := time.Parse("2006-01", month)
+        if err != nil {
+            return month
+        }
+
+        return t.Format("January")
+    },
+    "percent": func(value int64, max int64) int64 {
+        if max == 0 {
+            return 0
+        }
+
+        return value * 100 / max
+    },
+    "inc": func(i int) int { return i + 1 },
+}).Parse(reportHTML))
+
+type ReportArtist struct {
+    Artist string `json:"artist"`
+    FirstPlayed int64 `json:"firstPlayed"`
+    Plays int64 `json:"plays"`
+}
+
+type ReportMonth struct {
+    Month string `json:"month"`
+    Artist string `json:"artist"`
+    Name string `json:"name"`
+    Plays int64 `json:"plays"`
+}
+
+// Report is a year-in-review style summary of a period. Clock holds the
+// minutes listened in each hour of the day in the user's time zone, and
+// NewArtists are artists whose first ever scrobble falls inside the period.
+type Report struct {
+    Username string `json:"username"`
+    Title string `json:"title"`
+    From int64 `json:"from"`
+    To int64 `json:"to"`
+    Timezone string `json:"timezone"`
+    Plays int64 `json:"plays"`
+    Listened int64 `json:"listened"`
+    TopArtists []StatsItem `json:"topArtists"`
+    TopAlbums []StatsItem `json:"topAlbums"`
+    TopTracks []StatsItem `json:"topTracks"`
+    NewArtistCount int `json:"newArtistCount"`
+    NewArtists []ReportArtist `json:"newArtists"`
+    BusiestDay ListeningDay `json:"busiestDay"`
+    Months []ReportMonth `json:"months"`
+    Clock [24]int64 `json:"clock"`
+    ClockMax int64 `json:"-"`
+}
+
+type SharedReport struct {
+    Slug string `json:"slug"`
+    Period string `json:"period"`
+    Url string `json:"url"`
+    CreatedAt int64 `json:"createdAt"`
+}
+
+// summarizeReport fills in the parts of a report that depend on when each
+// scrobble happened: totals, the listening clock, the busiest day and the
+// most played track of every month.
+func summarizeReport(rows []database.GetReportScrobblesRow, period Period) Report {
+    loc := period.From.Location()
+    listens := []ListenEvent{}
+    for _, row := range rows {
+        listens = append(listens, ListenEvent{ Timestamp: row.Timestamp, Plays: 1, Duration: row.Duration })
+    }
+
+    listening := buildListeningStats(listens, period)
+    report := Report{
+        Title: reportTitle(period),
+        From: listening.From,
+        To: listening.To,
+        Timezone: listening.Timezone,
+        Plays: listening.Plays,
+        Listened: listening.Listened,
+        Clock: listening.Hours,
+        Months: []ReportMonth{},
+    }
+
+    for _, day := range listening.Daily {
+        if day.Plays > report.BusiestDay.Plays {
+            report.BusiestDay = day
+        }
+    }
+
+    type trackKey struct {
+        artist string
+        name string
+    }
+
+    months := []string{}
+    plays := map[string]map[trackKey]int64{}
+
+    for _, row := range rows {
+        t := time.UnixMilli(row.Timestamp).In(loc)
+        if !period.Contains(t) {
+            continue
+        }
+
+        month := t.Format("2006-01")
+        if plays[month] == nil {
+            months = append(months, month)
+            plays[month] = map[trackKey]int64{}
+        }
+
+        plays[month][trackKey{ artist: row.ArtistName, name: row.TrackName }]++
+    }
+
+    sort.Strings(months)
+
+    for _, month := range months {
+        top := ReportMonth{ Month: month }
+        for key, count := range plays[month] {
+            if count > top.Plays || (count == top.Plays && key.artist + key.name < top.Artist + top.Name) {
+                top = ReportMonth{ Month: month, Artist: key.artist, Name: key.name, Plays: count }
+            }
+        }
+
+        report.Months = append(report.Months, top)
+    }
+
+    return report
+}
+
+// reportTitle names a period the way people talk about it: a year, a month,
+// or a date range.
+func reportTitle(period Period) string {
+    from := period.From
+
+    if from.Month() == time.January && from.Day() == 1 && from.Hour() == 0 && period.To.Equal(from.AddDate(1, 0, 0)) {
+        return from.Format("2006")
+    }
+
+    if from.Day() == 1 && from.Hour() == 0 && period.To.Equal(from.AddDate(0, 1, 0)) {
+        return from.Format("January 2006")
+    }
+
+    return fmt.Sprintf("%s - %s", from.Format("Jan 2, 2006"), period.To.Add(-time.Millisecond).Format("Jan 2, 2006"))
+}
+
+func (s *Server) buildReport(ctx context.Context, user database.GetUserRow, period Period) (Report, error) {
+    rows, err := s.authCfg.database.GetReportScrobbles(ctx, database.GetReportScrobblesParams{
+        Uid: user.ID,
+        Since: period.From.UnixMilli(),
+        Until: period.To.UnixMilli(),
+    })
+
+    if err != nil {
+        return Report{}, err
+    }
+
+    report := summarizeReport(rows, period)
+    report.Username = user.Username
+
+    for _, entity := range []string{ STATS_ARTIST, STATS_ALBUM, STATS_TRACK } {
+        stats, err := topStats(ctx, s.authCfg.database, user.ID, StatsQuery{ Entity: entity, Period: period, Limit: reportListSize })
+        if err != nil {
+            return report, err
+        }
+
+        s.addStatsImages(ctx, &stats)
+
+        switch entity {
+        case STATS_ARTIST:
+            report.TopArtists = stats.Results
+        case STATS_ALBUM:
+            report.TopAlbums = stats.Results
+        case STATS_TRACK:
+            report.TopTracks = stats.Results
+        }
+    }
+
+    newArtists, err := s.authCfg.database.GetNewArtists(ctx, database.GetNewArtistsParams{
+        Uid: user.ID,
+        Since: period.From.UnixMilli(),
+        Until: period.To.UnixMilli(),
+    })
+
+    if err != nil {
+        return report, err
+    }
+
+    report.NewArtistCount = len(newArtists)
+    report.NewArtists = []ReportArtist{}
+
+    for i, row := range newArtists {
+        if i == reportListSize {
+            break
+        }
+
+        report.NewArtists = append(report.NewArtists, ReportArtist{ Artist: row.Artist, FirstPlayed: row.FirstPlayed, Plays: row.Plays })
+    }
+
+    return report, nil
+}
+
+// reportPeriod parses a report period, defaulting to the current year.
+func reportPeriod(value string, user database.GetUserRow) (Period, error) {
+    if value == "" {
+        value = PERIOD_YEAR
+    }
+
+    return ParsePeriod(value, time.Now().In(userLocation(user.Timezone)))
+}
+
+func (s *Server) GetReport(w http.ResponseWriter, r *http.Request) error {
+    user, err := s.currentUser(r)
+    if err != nil {
+        return err
+    }
+
+    period, err := reportPeriod(r.URL.Query().Get("period"), user)
+    if err != nil {
+        return fmt.Errorf(BAD_REQUEST_ERROR)
+    }
+
+    report, err := s.buildReport(r.Context(), user, period)
+    if err != nil {
+        s.log.Error("Building Report", "username", user.Username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    encode(w, http.StatusOK, report)
+    return nil
+}
+
+// ShareReport saves a snapshot of a report under a random slug. The snapshot
+// never changes, so later edits to scrobbles don't rewrite what was shared.
+func (s *Server) ShareReport(w http.ResponseWriter, r *http.Request) error {
+    type Body struct {
+        Period string `json:"period"`
+    }
+
+    user, err := s.currentUser(r)
+    if err != nil {
+        return err
+    }
+
+    body, err := decode[Body](r)
+    if err != nil {
+        return fmt.Errorf(BAD_REQUEST_ERROR)
+    }
+
+    period, err := reportPeriod(body.Period, user)
+    if err != nil {
+        return fmt.Errorf(BAD_REQUEST_ERROR)
+    }
+
+    report, err := s.buildReport(r.Context(), user, period)
+    if err != nil {
+        s.log.Error("Building Report", "username", user.Username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    data, err := json.Marshal(report)
+    if err != nil {
+        s.log.Error("Encoding Report", "username", user.Username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    slug, err := randomString(reportSlugLength)
+    if err != nil {
+        s.log.Error("Generating Report Slug", "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    shared := SharedReport{ Slug: slug, Period: report.Title, Url: fmt.Sprintf("/r/%s", slug), CreatedAt: time.Now().UnixMilli() }
+
+    err = s.authCfg.database.SaveReport(r.Context(), database.SaveReportParams{
+        Uid: user.ID,
+        Slug: slug,
+        Period: shared.Period,
+        Data: string(data),
+        CreatedAt: shared.CreatedAt,
+    })
+
+    if err != nil {
+        s.log.Error("Saving Report", "username", user.Username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    encode(w, http.StatusOK, shared)
+    return nil
+}
+
+func (s *Server) GetSharedReports(w http.ResponseWriter, r *http.Request) error {
+    user, err := s.currentUser(r)
+    if err != nil {
+        return err
+    }
+
+    rows, err := s.authCfg.database.GetReports(r.Context(), user.ID)
+    if err != nil {
+        s.log.Error("Getting Reports", "username", user.Username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    reports := []SharedReport{}
+    for _, row := range rows {
+        reports = append(reports, SharedReport{ Slug: row.Slug, Period: row.Period, Url: fmt.Sprintf("/r/%s", row.Slug), CreatedAt: row.CreatedAt })
+    }
+
+    encode(w, http.StatusOK, reports)
+    return nil
+}
+
+func (s *Server) DeleteSharedReport(w http.ResponseWriter, r *http.Request) error {
+    user, err := s.currentUser(r)
+    if err != nil {
+        return err
+    }
+
+    deleted, err := s.authCfg.database.DeleteReport(r.Context(), database.DeleteReportParams{ Uid: user.ID, Slug: r.PathValue("slug") })
+    if err != nil {
+        s.log.Error("Deleting Report", "username", user.Username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    if deleted == 0 {
+        return fmt.Errorf(NOT_FOUND_ERROR)
+    }
+
+    encode(w, http.StatusOK, SuccessResp{ Success: true })
+    return nil
+}
+
+func (s *Server) sharedReport(r *http.Request) (Report, error) {
+    var report Report
+
+    data, err := s.authCfg.database.GetReport(r.Context(), r.PathValue("slug"))
+    if err == sql.ErrNoRows {
+        return report, fmt.Errorf(NOT_FOUND_ERROR)
+    }
+
+    if err != nil {
+        s.log.Error("Getting Report", "slug", r.PathValue("slug"), "error", err)
+        return report, fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    if err := json.Unmarshal([]byte(data), &report); err != nil {
+        s.log.Error("Decoding Report", "slug", r.PathValue("slug"), "error", err)
+        return report, fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    return report, nil
+}
+
+// GetSharedReport returns the JSON behind a shared report. Anyone with the
+// slug can read it, the same as the page.
+func (s *Server) GetSharedReport(w http.ResponseWriter, r *http.Request) error {
+    report, err := s.sharedReport(r)
+    if err != nil {
+        return err
+    }
+
+    encode(w, http.StatusOK, report)
+    return nil
+}
+
+// ServeSharedReport renders a shared report as a self-contained HTML page.
+func (s *Server) ServeSharedReport(w http.ResponseWriter, r *http.Request) error {
+    report, err := s.sharedReport(r)
+    if err != nil {
+        return err
+    }
+
+    for _, minutes := range report.Clock {
+        report.ClockMax = max(report.ClockMax, minutes)
+    }
+
+    w.Header().Set("Content-Type", "text/html; charset=utf-8")
+    if err := reportTemplate.Execute(w, report); err != nil {
+        s.log.Error("Rendering Report", "slug", r.PathValue("slug"), "error", err)
+    }
+
+    return nil
+}
@@ -0,0 +1,78 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cg219/nowplaying/internal/database"
+)
+
+func TestSummarizeReport(t *testing.T) {
+    loc := time.FixedZone("EST", -5 * 60 * 60)
+    period, _ := ParsePeriod("2025", time.Date(2025, 6, 1, 0, 0, 0, 0, loc))
+
+    at := func(month time.Month, day int, hour int) int64 { return time.Date(2025, month, day, hour, 0, 0, 0, loc).UnixMilli() }
+    play := func(ts int64, artist string, track string) database.GetReportScrobblesRow {
+        return database.GetReportScrobblesRow{ Timestamp: ts, Duration: 3 * time.Minute.Milliseconds(), ArtistName: artist, TrackName: track }
+    }
+
+    report := summarizeReport([]database.GetReportScrobblesRow{
+        play(at(1, 5, 9), "A", "One"),
+        play(at(1, 5, 10), "A", "One"),
+        play(at(1, 6, 9), "B", "Two"),
+        play(at(3, 1, 22), "B", "Two"),
+        play(at(3, 1, 23), "C", "Three"),
+        play(at(3, 1, 23), "C", "Three"),
+        play(at(3, 1, 23), "B", "Two"),
+        // New Year's Eve in New York is already 2026 in UTC.
+        play(at(12, 31, 21), "D", "Four"),
+    }, period)
+
+    if report.Title != "2025" || report.Plays != 8 || report.Listened != 24 * time.Minute.Milliseconds() {
+        t.Errorf("totals: got %q, %d plays, %dms", report.Title, report.Plays, report.Listened)
+    }
+
+    if report.BusiestDay.Date != "2025-03-01" || report.BusiestDay.Plays != 4 {
+        t.Errorf("busiest day: got %+v", report.BusiestDay)
+    }
+
+    months := []ReportMonth{
+        { Month: "2025-01", Artist: "A", Name: "One", Plays: 2 },
+        { Month: "2025-03", Artist: "B", Name: "Two", Plays: 2 },
+        { Month: "2025-12", Artist: "D", Name: "Four", Plays: 1 },
+    }
+
+    if len(report.Months) != len(months) {
+        t.Fatalf("months: got %+v", report.Months)
+    }
+
+    for i, month := range months {
+        if report.Months[i] != month {
+            t.Errorf("month %d: got %+v, want %+v", i, report.Months[i], month)
+        }
+    }
+
+    if report.Clock[23] != 9 || report.Clock[9] != 6 || report.Clock[21] != 3 {
+        t.Errorf("clock: got %v", report.Clock)
+    }
+}
+
+func TestReportTitle(t *testing.T) {
+    now := time.Date(2025, 3, 14, 12, 0, 0, 0, time.UTC)
+    tests := map[string]string{
+        "2024": "2024",
+        "2025-02": "February 2025",
+        "2025-w10": "Mar 3, 2025 - Mar 9, 2025",
+    }
+
+    for value, want := range tests {
+        period, err := ParsePeriod(value, now)
+        if err != nil {
+            t.Fatalf("%s: %s", value, err)
+        }
+
+        if got := reportTitle(period); got != want {
+            t.Errorf("%s: got %q, want %q", value, got, want)
+        }
+    }
+}
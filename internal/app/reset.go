@@ -0,0 +1,190 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cg219/nowplaying/internal/database"
+)
+
+const (
+    resetTokenLength = 32
+    resetTokenTime = time.Minute * 15
+    resetLimitWindow = time.Minute * 15
+    // Per address, across asking for, checking and using reset links.
+    resetIPLimit = 20
+    // Per username, for asking for reset links, so nobody's inbox gets flooded.
+    resetUserLimit = 3
+)
+
+// resetUser returns who a reset token belongs to if it is still usable.
+// Tokens are stored hashed and cleared once used.
+func (s *Server) resetUser(r *http.Request, token string) (string, bool) {
+    row, err := s.authCfg.database.CanResetPassword(r.Context(), database.CanResetPasswordParams{
+        ResetTime: sql.NullInt64{ Int64: time.Now().Unix(), Valid: true },
+        Reset: sql.NullString{ String: hashToken(token), Valid: true },
+    })
+
+    if err != nil {
+        if err != sql.ErrNoRows {
+            s.log.Error("Checking Reset", "error", err)
+        }
+
+        return "", false
+    }
+
+    if !row.Valid {
+        return "", false
+    }
+
+    return row.Username, true
+}
+
+// ResetPassword sets a new password with a reset token. The token has to
+// belong to the username given and works once; afterwards every session the
+// user had is ended.
+func (s *Server) ResetPassword(w http.ResponseWriter, r *http.Request) error {
+    type Body struct {
+        Username string `json:"username"`
+        Reset string `json:"reset"`
+        Password string `json:"password"`
+        PasswordConfirm string `json:"passwordConfirm"`
+    }
+
+    if !s.resetIPs.Allow(s.clientIP(r), time.Now()) {
+        return fmt.Errorf(TOO_MANY_REQUESTS_ERROR)
+    }
+
+    body, err := decode[Body](r)
+    if err != nil || body.Password == "" || body.Password != body.PasswordConfirm {
+        return fmt.Errorf(BAD_REQUEST_ERROR)
+    }
+
+    username, ok := s.resetUser(r, body.Reset)
+    if !ok || username != body.Username {
+        return fmt.Errorf(AUTH_ERROR)
+    }
+
+    hashPass, err := s.hasher.EncodeFromString(body.Password)
+    if err != nil {
+        s.log.Error("Encoding Password", "username", username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    uid, err := s.authCfg.database.ResetPassword(r.Context(), database.ResetPasswordParams{
+        Password: hashPass,
+        Username: username,
+        Reset: sql.NullString{ String: hashToken(body.Reset), Valid: true },
+        ResetTime: sql.NullInt64{ Int64: time.Now().Unix(), Valid: true },
+    })
+
+    // Someone else used the token between the check and here.
+    if err == sql.ErrNoRows {
+        return fmt.Errorf(AUTH_ERROR)
+    }
+
+    if err != nil {
+        s.log.Error("Resetting Password", "username", username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    if err := s.authCfg.database.DeleteUserSessions(r.Context(), uid); err != nil {
+        s.log.Error("Ending Sessions", "username", username, "error", err)
+    }
+
+    s.log.Info("Password Reset", "username", username)
+    encode(w, http.StatusOK, SuccessResp{ Success: true })
+    return nil
+}
+
+// ForgotPassword emails a reset link to the user's confirmed address. The
+// response is the same whether or not a link went out so it can't be used to
+// find accounts or their addresses.
+func (s *Server) ForgotPassword(w http.ResponseWriter, r *http.Request) error {
+    now := time.Now()
+
+    if err := r.ParseForm(); err != nil {
+        return fmt.Errorf(BAD_REQUEST_ERROR)
+    }
+
+    username := strings.TrimSpace(r.FormValue("username"))
+    if username == "" {
+        return fmt.Errorf(BAD_REQUEST_ERROR)
+    }
+
+    if !s.resetIPs.Allow(s.clientIP(r), now) || !s.resetUsers.Allow(strings.ToLower(username), now) {
+        s.log.Info("Reset Rate Limited", "username", username, "ip", s.clientIP(r))
+        return fmt.Errorf(TOO_MANY_REQUESTS_ERROR)
+    }
+
+    reset, err := randomString(resetTokenLength)
+    if err != nil {
+        s.log.Error("Generating Reset Token", "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    // Checked before looking the user up so the answer doesn't depend on
+    // whether they have an address.
+    link, err := s.publicURL("/reset/" + reset)
+    if err != nil {
+        s.log.Error("Sending Reset Email", "username", username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    email, err := s.authCfg.database.GetVerifiedEmail(r.Context(), username)
+    if err != nil || !email.Valid {
+        if err != nil && err != sql.ErrNoRows {
+            s.log.Error("Getting Email", "username", username, "error", err)
+        }
+
+        encode(w, http.StatusOK, SuccessResp{ Success: true })
+        return nil
+    }
+
+    err = s.authCfg.database.SetPasswordReset(r.Context(), database.SetPasswordResetParams{
+        Reset: sql.NullString{ String: hashToken(reset), Valid: true },
+        ResetTime: sql.NullInt64{ Int64: now.Add(resetTokenTime).Unix(), Valid: true },
+        Username: username,
+    })
+
+    if err != nil {
+        s.log.Error("Saving Reset Token", "username", username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    msg, err := renderEmail(email.String, "Reset your password", "reset", EmailData{
+        Username: username,
+        Url: link,
+        Expires: "15 minutes",
+    })
+
+    if err != nil {
+        s.log.Error("Rendering Reset Email", "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    s.sendMail(msg)
+    encode(w, http.StatusOK, SuccessResp{ Success: true })
+    return nil
+}
+
+func (s *Server) GetResetPasswordData(w http.ResponseWriter, r *http.Request) error {
+    type Data struct {
+        Valid bool `json:"valid"`
+        Username string `json:"username"`
+        Reset string `json:"reset"`
+    }
+
+    if !s.resetIPs.Allow(s.clientIP(r), time.Now()) {
+        return fmt.Errorf(TOO_MANY_REQUESTS_ERROR)
+    }
+
+    reset := r.PathValue("resetvalue")
+    username, ok := s.resetUser(r, reset)
+
+    encode(w, http.StatusOK, Data{ Valid: ok, Username: username, Reset: reset })
+    return nil
+}
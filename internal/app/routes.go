@@ -2,19 +2,19 @@ package app
 
 import (
 	"context"
-	"crypto/rand"
 	"database/sql"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"log"
-	"math/big"
 	"net/http"
 	_ "net/http/pprof"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/cg219/nowplaying/internal/database"
+	"github.com/cg219/nowplaying/pkg/spotup"
 	"github.com/dghubble/oauth1"
 )
 
@@ -65,9 +65,14 @@ func (s *Server) NotifyScrobble(w http.ResponseWriter, r *http.Request) error {
         ArtistName string `json:"artistName"`
         TrackName string `json:"trackName"`
         Timestamp int `json:"timestamp"`
+        Time string `json:"time"`
     }
 
-    subscriber := GetScrobbleSubscriber(r.Context().Value("username").(string))
+    username := r.Context().Value("username").(string)
+    user, _ := s.authCfg.database.GetUser(r.Context(), username)
+    loc := userLocation(user.Timezone)
+
+    subscriber := GetScrobbleSubscriber(username)
     id := s.authCfg.Register(subscriber)
 
     for {
@@ -80,6 +85,7 @@ func (s *Server) NotifyScrobble(w http.ResponseWriter, r *http.Request) error {
                 ArtistName: scrobble.ArtistName,
                 TrackName: scrobble.TrackName,
                 Timestamp: int(time.Unix(0, 0).Add(time.Duration(scrobble.Timestamp) * time.Millisecond).UnixMilli()),
+                Time: time.UnixMilli(int64(scrobble.Timestamp)).In(loc).Format(time.RFC3339),
             }
             encoded, _ := json.Marshal(data)
             fmt.Fprintf(w, "event: scrobble\ndata: %s\n\n", string(encoded))
@@ -88,332 +94,204 @@ func (s *Server) NotifyScrobble(w http.ResponseWriter, r *http.Request) error {
     }
 }
 
+type ScrobbleBody struct {
+    Name string `json:"name"`
+    Artist string `json:"artist"`
+    Album string `json:"album"`
+    Timestamp string `json:"timestamp"`
+    Progress string `json:"progress"`
+    Duration string `json:"duration"`
+    Client string `json:"client"`
+}
+
+// ScrobbleSong accepts either a single scrobble or an array of them, such as an
+// offline player flushing its cache. Every item is processed before responding
+// so the client learns whether each listen was saved.
 func (s *Server) ScrobbleSong(w http.ResponseWriter,r *http.Request) error {
     username := r.Context().Value("username").(string)
 
-    type Body struct {
-        Name string `json:"name"`
-        Artist string `json:"artist"`
-        Album string `json:"album"`
-        Timestamp string `json:"timestamp"`
-        Progress string `json:"progress"`
-        Duration string `json:"duration"`
-        Client string `json:"client"`
+    type SingleResp struct {
+        Success bool `json:"success"`
+        ScrobbleResult
     }
 
-    defer r.Body.Close()
-    data := SuccessResp{ Success: true }
+    type ItemResult struct {
+        Index int `json:"index"`
+        ScrobbleResult
+    }
 
-    encode(w, 200, data)
+    type BatchResp struct {
+        Success bool `json:"success"`
+        Accepted int `json:"accepted"`
+        Ignored int `json:"ignored"`
+        Rejected int `json:"rejected"`
+        Results []ItemResult `json:"results"`
+    }
+
+    defer r.Body.Close()
 
-    res, err := decode[Body](r)
+    raw, err := decode[json.RawMessage](r)
     if err != nil {
         s.log.Error("json decoding", "err", err.Error())
+        return fmt.Errorf(BAD_REQUEST_ERROR)
     }
 
-    timestamp, err := time.Parse(time.RFC3339, res.Timestamp)
-    if err != nil {
-        s.log.Error("parsing time", "err", err.Error())
-    }
+    if !strings.HasPrefix(strings.TrimSpace(string(raw)), "[") {
+        var body ScrobbleBody
+        if err := json.Unmarshal(raw, &body); err != nil {
+            s.log.Error("json decoding", "err", err.Error())
+            return fmt.Errorf(BAD_REQUEST_ERROR)
+        }
 
-    duration, err := stringToDuration(res.Duration)
-    if err != nil {
-        s.log.Error("parsing time", "err", err.Error())
-    }
+        result, err := s.submitScrobble(r.Context(), username, body, false)
+        if err != nil {
+            s.log.Error("Scrobbling", "username", username, "err", err)
+            return fmt.Errorf(INTERNAL_ERROR)
+        }
 
-    progress, err := stringToDuration(res.Progress)
-    if err != nil {
-        s.log.Error("parsing time", "err", err.Error())
+        encode(w, http.StatusOK, SingleResp{ Success: result.Status != SCROBBLE_REJECTED, ScrobbleResult: result })
+        return nil
     }
 
-    scrobble := Scrobble{
-        ArtistName: res.Artist,
-        TrackName: res.Name,
-        AlbumName: res.Album,
-        AlbumArtist: res.Artist,
-        Duration: int(duration.Milliseconds()),
-        Progress: int(progress.Milliseconds()),
-        Timestamp: int(timestamp.UnixMilli()),
-        Source: strings.ToLower(res.Client),
-        TrackNumber: "0",
+    var bodies []ScrobbleBody
+    if err := json.Unmarshal(raw, &bodies); err != nil {
+        s.log.Error("json decoding", "err", err.Error())
+        return fmt.Errorf(BAD_REQUEST_ERROR)
     }
 
-    s.authCfg.scrobbles <- ScrobblePack{ Scrobble: scrobble, Username: username }
-    return nil
-}
-
-func (s *Server) GenerateAPIKey(w http.ResponseWriter, r *http.Request) error {
-    username := r.Context().Value("username")
-    user, _ := s.authCfg.database.GetUser(r.Context(), username.(string))
+    if len(bodies) > maxScrobbleBatch {
+        return fmt.Errorf(BAD_REQUEST_ERROR)
+    }
 
-    const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-    key := make([]byte, 24)
+    resp := BatchResp{ Success: true, Results: []ItemResult{} }
 
-    for i := range key {
-        n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+    for i, body := range bodies {
+        result, err := s.submitScrobble(r.Context(), username, body, true)
         if err != nil {
-            s.log.Error("generating api key", "err", err)
+            s.log.Error("Scrobbling", "username", username, "err", err)
             return fmt.Errorf(INTERNAL_ERROR)
         }
 
-        key[i] = charset[n.Int64()]
-    }
-
-    s.authCfg.database.SaveApiKey(r.Context(), database.SaveApiKeyParams{
-        Key: string(key),
-        Uid: sql.NullInt64{ Valid: true, Int64: user.ID },
-        Name: r.PathValue("name"),
-    })
+        switch result.Status {
+        case SCROBBLE_ACCEPTED:
+            resp.Accepted++
+        case SCROBBLE_IGNORED:
+            resp.Ignored++
+        case SCROBBLE_REJECTED:
+            resp.Rejected++
+        }
 
-    type KeyResp struct {
-        Key string `json:"apikey"`
+        resp.Results = append(resp.Results, ItemResult{ Index: i, ScrobbleResult: result })
     }
 
-    resp := KeyResp{ Key: string(key) }
     encode(w, http.StatusOK, resp)
     return nil
 }
 
-func (s *Server) ResetPassword(w http.ResponseWriter, r *http.Request) error {
-    resettimer := time.Now().Unix()
-    type Body struct {
-        Username string `json:"username"`
-        Reset string `json:"reset"`
-        Password string `json:"password"`
-        PasswordConfirm string `json:"passwordConfirm"`
-    }
-
-    body, err := decode[Body](r)
-    if err != nil {
-        return err
+// submitScrobble validates one submitted item and runs it through the
+// scrobble pipeline. Batched items are past listens, so they leave now playing
+// alone. A missing progress means the whole track was played.
+func (s *Server) submitScrobble(ctx context.Context, username string, body ScrobbleBody, batched bool) (ScrobbleResult, error) {
+    reject := func(reason string) (ScrobbleResult, error) {
+        return ScrobbleResult{ Status: SCROBBLE_REJECTED, Reason: reason }, nil
     }
 
-    if !strings.EqualFold(body.Password, body.PasswordConfirm) {
-        return fmt.Errorf(AUTH_ERROR)
+    if strings.TrimSpace(body.Artist) == "" || strings.TrimSpace(body.Name) == "" {
+        return reject("artist and name are required")
     }
 
-    hashPass, _ := s.hasher.EncodeFromString(body.Password)
-
-    s.authCfg.database.ResetPassword(r.Context(), database.ResetPasswordParams{
-        Reset: sql.NullString{ String: body.Reset, Valid: true },
-        ResetTime: sql.NullInt64{ Int64: resettimer, Valid: true },
-        Password: hashPass,
-    })
-
-    data := SuccessResp{ Success: true }
-
-    encode(w, 200, data)
-    return nil
-}
-
-func (s *Server) ForgotPassword(w http.ResponseWriter, r *http.Request) error {
-    resettimer := time.Now().Add(time.Minute * 15).Unix()
-    resetbytes := make([]byte, 32)
-    rand.Read(resetbytes)
-    reset := base64.URLEncoding.EncodeToString(resetbytes)[:16]
-    err := r.ParseForm()
-
+    timestamp, err := time.Parse(time.RFC3339, body.Timestamp)
     if err != nil {
-        s.log.Error("parsing form", "err", err)
+        return reject("timestamp must be RFC3339")
     }
 
-    username := r.FormValue("username")
-
-    err = s.authCfg.database.SetPasswordReset(r.Context(), database.SetPasswordResetParams{
-        Reset: sql.NullString{ String: reset, Valid: true },
-        ResetTime: sql.NullInt64{ Int64: resettimer, Valid: true },
-        Username: username,
-    })
-
-    if err != nil {
-        s.log.Error("resetting pass", "err", err)
+    var duration time.Duration
+    if body.Duration != "" {
+        if duration, err = stringToDuration(body.Duration); err != nil {
+            return reject("duration must be HH:MM:SS")
+        }
     }
 
-    // TODO: Setup email service to send this to user email
-    s.log.Info("Reset Link:", "url", fmt.Sprintf("http://localhost:%s/reset/%s", "3006", reset))
-
-    return nil
-}
-
-func (s *Server) ShareTopDailyArtists(w http.ResponseWriter, r *http.Request) error {
-    username := r.Context().Value("username").(string)
-    user, err := s.authCfg.database.GetUser(r.Context(), username)
-    if err != nil && err != sql.ErrNoRows {
-        return fmt.Errorf(INTERNAL_ERROR)
+    progress := duration
+    if body.Progress != "" {
+        if progress, err = stringToDuration(body.Progress); err != nil {
+            return reject("progress must be HH:MM:SS")
+        }
     }
 
-    twitter := NewTwitter(username, TwitterConfig(s.authCfg.config.Twitter), s.authCfg.database)
-    err = twitter.AuthWithDB(context.Background())
-    if err != nil {
-        s.log.Error("Twitter Auth", "err", err)
-        return fmt.Errorf(INTERNAL_ERROR)
+    scrobble := Scrobble{
+        ArtistName: body.Artist,
+        TrackName: body.Name,
+        AlbumName: body.Album,
+        AlbumArtist: body.Artist,
+        Duration: int(duration.Milliseconds()),
+        Progress: int(progress.Milliseconds()),
+        Timestamp: int(timestamp.UnixMilli()),
+        Source: strings.ToLower(body.Client),
+        TrackNumber: "0",
     }
 
-    results, _ := s.authCfg.database.GetTopArtistsOfDay(r.Context(), database.GetTopArtistsOfDayParams{
-        Limit: 7,
-        Uid: user.ID,
-    })
-
-    var tweet strings.Builder
-
-    tweet.WriteString("Top artists the last 24 hours:\n\n")
-    for _, artist := range results {
-        tweet.WriteString(fmt.Sprintf("%s(%d)\n", artist.Artist, artist.Plays))
-    }
+    return s.authCfg.processScrobble(ctx, ScrobblePack{ Scrobble: scrobble, Username: username, Import: batched })
+}
 
-    log.Println(tweet.String(), len(tweet.String()))
-    twitter.Tweet(tweet.String())
-    return nil
+func (s *Server) ShareTopDailyArtists(w http.ResponseWriter, r *http.Request) error {
+    return s.shareTop(r, STATS_ARTIST, PERIOD_DAY, 7, "Top artists today")
 }
 
 func (s *Server) ShareTopDailyTracks(w http.ResponseWriter, r *http.Request) error {
-    username := r.Context().Value("username").(string)
-    user, err := s.authCfg.database.GetUser(r.Context(), username)
-    if err != nil && err != sql.ErrNoRows {
-        return fmt.Errorf(INTERNAL_ERROR)
-    }
-
-    twitter := NewTwitter(username, TwitterConfig(s.authCfg.config.Twitter), s.authCfg.database)
-    err = twitter.AuthWithDB(context.Background())
-    if err != nil {
-        s.log.Error("Twitter Auth", "err", err)
-        return fmt.Errorf(INTERNAL_ERROR)
-    }
-
-    results, _ := s.authCfg.database.GetTopTracksOfDay(r.Context(), database.GetTopTracksOfDayParams{
-        Limit: 5,
-        Uid: user.ID,
-    })
-
-    var tweet strings.Builder
-
-    tweet.WriteString("Top songs the last 24 hours:\n\n")
-    for _, scrobble := range results {
-        tweet.WriteString(fmt.Sprintf("%s - %s(%d)\n", scrobble.ArtistName, scrobble.TrackName, scrobble.Plays))
-    }
-
-    log.Println(tweet.String(), len(tweet.String()))
-    twitter.Tweet(tweet.String())
-    return nil
+    return s.shareTop(r, STATS_TRACK, PERIOD_DAY, 5, "Top songs today")
 }
 
 func (s *Server) ShareTopWeeklyArtists(w http.ResponseWriter, r *http.Request) error {
-    username := r.Context().Value("username").(string)
-    user, err := s.authCfg.database.GetUser(r.Context(), username)
-    if err != nil && err != sql.ErrNoRows {
-        return fmt.Errorf(INTERNAL_ERROR)
-    }
-
-    twitter := NewTwitter(username, TwitterConfig(s.authCfg.config.Twitter), s.authCfg.database)
-    err = twitter.AuthWithDB(context.Background())
-    if err != nil {
-        s.log.Error("Twitter Auth", "err", err)
-        return fmt.Errorf(INTERNAL_ERROR)
-    }
-
-    results, _ := s.authCfg.database.GetTopArtistsOfWeek(r.Context(), database.GetTopArtistsOfWeekParams{
-        Limit: 7,
-        Uid: user.ID,
-    })
-
-    var tweet strings.Builder
-
-    tweet.WriteString("Top artists this week:\n\n")
-    for _, artist := range results {
-        tweet.WriteString(fmt.Sprintf("%s(%d)\n", artist.Artist, artist.Plays))
-    }
-
-    log.Println(tweet.String(), len(tweet.String()))
-    twitter.Tweet(tweet.String())
-    return nil
+    return s.shareTop(r, STATS_ARTIST, PERIOD_WEEK, 7, "Top artists this week")
 }
 
 func (s *Server) ShareTopWeeklyTracks(w http.ResponseWriter, r *http.Request) error {
-    username := r.Context().Value("username").(string)
-    user, err := s.authCfg.database.GetUser(r.Context(), username)
-    if err != nil && err != sql.ErrNoRows {
-        return fmt.Errorf(INTERNAL_ERROR)
-    }
-
-    twitter := NewTwitter(username, TwitterConfig(s.authCfg.config.Twitter), s.authCfg.database)
-    err = twitter.AuthWithDB(context.Background())
-    if err != nil {
-        s.log.Error("Twitter Auth", "err", err)
-        return fmt.Errorf(INTERNAL_ERROR)
-    }
-
-    results, _ := s.authCfg.database.GetTopTracksOfWeek(r.Context(), database.GetTopTracksOfWeekParams{
-        Limit: 5,
-        Uid: user.ID,
-    })
-
-    var tweet strings.Builder
+    return s.shareTop(r, STATS_TRACK, PERIOD_WEEK, 5, "Top songs this week")
+}
 
-    tweet.WriteString("Top songs this week:\n\n")
-    for _, scrobble := range results {
-        tweet.WriteString(fmt.Sprintf("%s - %s(%d)\n", scrobble.ArtistName, scrobble.TrackName, scrobble.Plays))
-    }
+func (s *Server) ShareTopMonthlyAlbums(w http.ResponseWriter, r *http.Request) error {
+    return s.shareTop(r, STATS_ALBUM, PERIOD_MONTH, 10, "Top albums this month")
+}
 
-    log.Println(tweet.String(), len(tweet.String()))
-    twitter.Tweet(tweet.String())
-    return nil
+func (s *Server) ShareTopYearlyAlbums(w http.ResponseWriter, r *http.Request) error {
+    return s.shareTop(r, STATS_ALBUM, PERIOD_YEAR, 10, "Top albums this year")
 }
 
-func (s *Server) ShareTopMonthlyAlbums(w http.ResponseWriter, r *http.Request) error {
-    username := r.Context().Value("username").(string)
-    user, err := s.authCfg.database.GetUser(r.Context(), username)
-    if err != nil && err != sql.ErrNoRows {
-        return fmt.Errorf(INTERNAL_ERROR)
+// shareTop tweets the user's top artists, tracks or albums for a calendar
+// period in their time zone.
+func (s *Server) shareTop(r *http.Request, entity string, period string, limit int, heading string) error {
+    user, err := s.currentUser(r)
+    if err != nil {
+        return err
     }
 
-    twitter := NewTwitter(username, TwitterConfig(s.authCfg.config.Twitter), s.authCfg.database)
+    twitter := NewTwitter(user.Username, TwitterConfig(s.authCfg.config.Twitter), s.authCfg.database)
     err = twitter.AuthWithDB(context.Background())
     if err != nil {
         s.log.Error("Twitter Auth", "err", err)
         return fmt.Errorf(INTERNAL_ERROR)
     }
 
-    results, _ := s.authCfg.database.GetTopAlbumsOfMonth(r.Context(), database.GetTopAlbumsOfMonthParams{
-        Limit: 10,
-        Uid: user.ID,
-    })
-
-    var tweet strings.Builder
-
-    tweet.WriteString("Top albums in the last month:\n\n")
-    for _, scrobble := range results {
-        tweet.WriteString(fmt.Sprintf("%s (%d)\n", scrobble.AlbumName.String, scrobble.Plays))
-    }
-
-    log.Println(tweet.String(), len(tweet.String()))
-    twitter.Tweet(tweet.String())
-    return nil
-}
-
-func (s *Server) ShareTopYearlyAlbums(w http.ResponseWriter, r *http.Request) error {
-    username := r.Context().Value("username").(string)
-    user, err := s.authCfg.database.GetUser(r.Context(), username)
-    if err != nil && err != sql.ErrNoRows {
-        return fmt.Errorf(INTERNAL_ERROR)
-    }
-
-    twitter := NewTwitter(username, TwitterConfig(s.authCfg.config.Twitter), s.authCfg.database)
-    err = twitter.AuthWithDB(context.Background())
+    p, _ := ParsePeriod(period, time.Now().In(userLocation(user.Timezone)))
+    stats, err := topStats(r.Context(), s.authCfg.database, user.ID, StatsQuery{ Entity: entity, Period: p, Limit: limit })
     if err != nil {
-        s.log.Error("Twitter Auth", "err", err)
+        s.log.Error("Getting Stats", "username", user.Username, "entity", entity, "error", err)
         return fmt.Errorf(INTERNAL_ERROR)
     }
 
-    results, _ := s.authCfg.database.GetTopAlbumsOfYear(r.Context(), database.GetTopAlbumsOfYearParams{
-        Limit: 10,
-        Uid: user.ID,
-    })
-
     var tweet strings.Builder
 
-    tweet.WriteString("Top albums in the last year:\n\n")
-    for _, scrobble := range results {
-        tweet.WriteString(fmt.Sprintf("%s (%d)\n", scrobble.AlbumName.String, scrobble.Plays))
+    tweet.WriteString(heading + ":\n\n")
+    for _, item := range stats.Results {
+        switch entity {
+        case STATS_ARTIST:
+            tweet.WriteString(fmt.Sprintf("%s(%d)\n", item.Artist, item.Plays))
+        case STATS_TRACK:
+            tweet.WriteString(fmt.Sprintf("%s - %s(%d)\n", item.Artist, item.Name, item.Plays))
+        case STATS_ALBUM:
+            tweet.WriteString(fmt.Sprintf("%s (%d)\n", item.Name, item.Plays))
+        }
     }
 
     log.Println(tweet.String(), len(tweet.String()))
@@ -445,7 +323,29 @@ func (s *Server) ShareLatestTrack(w http.ResponseWriter, r *http.Request) error
 }
 
 func (s *Server) AddSpotify(w http.ResponseWriter, r *http.Request) error {
-    user, err := s.authCfg.database.GetUser(r.Context(), r.Context().Value("username").(string))
+    return s.addMusicSource(r, "spotify")
+}
+
+func (s *Server) RemoveSpotify(w http.ResponseWriter, r *http.Request) error {
+    return s.removeMusicSource(r, "spotify")
+}
+
+func (s *Server) AddMusicSource(w http.ResponseWriter, r *http.Request) error {
+    return s.addMusicSource(r, r.PathValue("source"))
+}
+
+func (s *Server) RemoveMusicSource(w http.ResponseWriter, r *http.Request) error {
+    return s.removeMusicSource(r, r.PathValue("source"))
+}
+
+func (s *Server) addMusicSource(r *http.Request, sourceType string) error {
+    src, ok := GetMusicSource(sourceType)
+    if !ok {
+        return fmt.Errorf(NOT_FOUND_ERROR)
+    }
+
+    username := r.Context().Value("username").(string)
+    user, err := s.authCfg.database.GetUser(r.Context(), username)
     if err != nil && err != sql.ErrNoRows {
         return fmt.Errorf(INTERNAL_ERROR)
     }
@@ -456,7 +356,7 @@ func (s *Server) AddSpotify(w http.ResponseWriter, r *http.Request) error {
     }
 
     for _, v := range sessions {
-        if strings.EqualFold(v.Type, "spotify") {
+        if strings.EqualFold(v.Type, src.Type) {
             if v.Active == 1 {
                 return nil
             }
@@ -467,30 +367,35 @@ func (s *Server) AddSpotify(w http.ResponseWriter, r *http.Request) error {
                 return fmt.Errorf(INTERNAL_ERROR)
             }
 
-            s.authCfg.haveNewSessions = true
+            s.authCfg.supervisor.Refresh()
             return nil
         }
     }
 
-    spotify := NewSpotify(r.Context().Value("username").(string), SpotifyConfig(s.authCfg.config.Spotify), s.authCfg.database)
-    data := base64.StdEncoding.EncodeToString(spotify.Encode())
+    session := src.New(s.authCfg, username)
+    data := base64.StdEncoding.EncodeToString(session.Encode())
     err = s.authCfg.database.SaveMusicSession(r.Context(), database.SaveMusicSessionParams{
         Data: data,
-        Type: "spotify",
+        Type: src.Type,
         Uid: user.ID,
         Active: 1,
     })
 
     if err != nil {
-        s.log.Error("Saving Music Session", "data", spotify, "error", err)
+        s.log.Error("Saving Music Session", "type", src.Type, "data", session, "error", err)
         return fmt.Errorf(INTERNAL_ERROR)
     }
 
-    s.authCfg.haveNewSessions = true
+    s.authCfg.supervisor.Refresh()
     return nil
 }
 
-func (s *Server) RemoveSpotify(w http.ResponseWriter, r *http.Request) error {
+func (s *Server) removeMusicSource(r *http.Request, sourceType string) error {
+    src, ok := GetMusicSource(sourceType)
+    if !ok {
+        return fmt.Errorf(NOT_FOUND_ERROR)
+    }
+
     user, err := s.authCfg.database.GetUser(r.Context(), r.Context().Value("username").(string))
     if err != nil && err != sql.ErrNoRows {
         return fmt.Errorf(INTERNAL_ERROR)
@@ -504,7 +409,7 @@ func (s *Server) RemoveSpotify(w http.ResponseWriter, r *http.Request) error {
     var idToRemove int64
 
     for _, v := range sessions {
-        if strings.EqualFold(v.Type, "spotify") {
+        if strings.EqualFold(v.Type, src.Type) {
             if v.Active == 0 {
                 return nil
             }
@@ -522,11 +427,7 @@ func (s *Server) RemoveSpotify(w http.ResponseWriter, r *http.Request) error {
         return fmt.Errorf(INTERNAL_ERROR)
     }
 
-    fmt.Println("Pre REMOVE:", s.authCfg.haveNewSessions)
-
-    s.authCfg.haveNewSessions = true
-
-    fmt.Println("Post REMOVE:", s.authCfg.haveNewSessions)
+    s.authCfg.supervisor.Refresh()
     return nil
 }
 
@@ -544,13 +445,29 @@ func (s *Server) GetLastScrobble(w http.ResponseWriter, r *http.Request) error {
     data := Data{
         ArtistName: scrobble.ArtistName,
         TrackName: scrobble.TrackName,
-        Timestamp: timestamp.Format("01/02/2006 - 03:04PM"),
+        Timestamp: timestamp.In(userLocation(user.Timezone)).Format("01/02/2006 - 03:04PM"),
     }
 
     encode(w, 200, data)
     return nil
 }
 
+func (s *Server) GetNowPlaying(w http.ResponseWriter, r *http.Request) error {
+    type Data struct {
+        Playing bool `json:"playing"`
+        Track *NowPlaying `json:"track,omitempty"`
+    }
+
+    data := Data{}
+    if track, ok := s.authCfg.nowPlaying.Get(r.Context().Value("username").(string)); ok {
+        data.Playing = true
+        data.Track = &track
+    }
+
+    encode(w, http.StatusOK, data)
+    return nil
+}
+
 func (s *Server) GetUserData(w http.ResponseWriter, r *http.Request) error {
     type LastScrobble struct {
         ArtistName string `json:"artistName"`
@@ -563,6 +480,7 @@ func (s *Server) GetUserData(w http.ResponseWriter, r *http.Request) error {
         NavLinks []NavLink `json:"links"`
         Title string `json:"title"`
         Subtitle string `json:"subtitle"`
+        Timezone string `json:"timezone"`
         Top struct {
             Daily struct {
                 Tracks []Track `json:"tracks"`
@@ -587,47 +505,17 @@ func (s *Server) GetUserData(w http.ResponseWriter, r *http.Request) error {
 
     user, _ := s.authCfg.database.GetUser(r.Context(), username.(string))
     scrobble, _ := s.authCfg.database.GetLatestTrack(r.Context(), user.ID)
-    dailytracks, _ := s.authCfg.database.GetTopTracksOfDay(r.Context(), database.GetTopTracksOfDayParams{
-        Limit: 10,
-        Uid: user.ID,
-    })
-    dailyartists, _ := s.authCfg.database.GetTopArtistsOfDay(r.Context(), database.GetTopArtistsOfDayParams{
-        Limit: 10,
-        Uid: user.ID,
-    })
-    weeklytracks, _ := s.authCfg.database.GetTopTracksOfWeek(r.Context(), database.GetTopTracksOfWeekParams{
-        Limit: 10,
-        Uid: user.ID,
-    })
-    weeklyartists, _ := s.authCfg.database.GetTopArtistsOfWeek(r.Context(), database.GetTopArtistsOfWeekParams{
-        Limit: 10,
-        Uid: user.ID,
-    })
     timestamp := time.Unix(0, 0).Add(time.Duration(scrobble.Timestamp) * time.Millisecond).UnixMilli()
 
-    data.Top.Daily.Tracks = make([]Track, 0)
-    data.Top.Weekly.Tracks = make([]Track, 0)
-
-    for _, row := range dailytracks {
-        data.Top.Daily.Tracks = append(data.Top.Daily.Tracks, Track{ Name: row.ArtistName, Plays: int(row.Plays), Track: row.TrackName })
-    } 
-
-    for _, row := range dailyartists {
-        data.Top.Daily.Artists = append(data.Top.Daily.Artists, Artist{ Name: row.Artist, Plays: int(row.Plays) })
-    } 
+    data.Timezone = userLocation(user.Timezone).String()
+    now := time.Now().In(userLocation(user.Timezone))
+    day, _ := ParsePeriod(PERIOD_DAY, now)
+    week, _ := ParsePeriod(PERIOD_WEEK, now)
 
-    for _, row := range weeklytracks {
-        data.Top.Weekly.Tracks = append(data.Top.Weekly.Tracks, Track{ Name: row.ArtistName, Plays: int(row.Plays), Track: row.TrackName })
-    } 
-
-    for _, row := range weeklyartists {
-        data.Top.Weekly.Artists = append(data.Top.Weekly.Artists, Artist{ Name: row.Artist, Plays: int(row.Plays) })
-    } 
-
-    loadTrackImages(data.Top.Daily.Tracks, s.authCfg.config)
-    loadTrackImages(data.Top.Weekly.Tracks, s.authCfg.config)
-    loadArtistImages(data.Top.Daily.Artists, s.authCfg.config)
-    loadArtistImages(data.Top.Weekly.Artists, s.authCfg.config)
+    data.Top.Daily.Tracks = s.topTracks(r.Context(), user.ID, day)
+    data.Top.Daily.Artists = s.topArtists(r.Context(), user.ID, day)
+    data.Top.Weekly.Tracks = s.topTracks(r.Context(), user.ID, week)
+    data.Top.Weekly.Artists = s.topArtists(r.Context(), user.ID, week)
 
     data.LastScrobble = LastScrobble{
         ArtistName: scrobble.ArtistName,
@@ -640,6 +528,40 @@ func (s *Server) GetUserData(w http.ResponseWriter, r *http.Request) error {
 }
 
 
+func (s *Server) topTracks(ctx context.Context, uid int64, period Period) []Track {
+    tracks := []Track{}
+
+    stats, err := topStats(ctx, s.authCfg.database, uid, StatsQuery{ Entity: STATS_TRACK, Period: period, Limit: 10 })
+    if err != nil {
+        s.log.Error("Getting Top Tracks", "uid", uid, "error", err)
+        return tracks
+    }
+
+    s.addStatsImages(ctx, &stats)
+    for _, item := range stats.Results {
+        tracks = append(tracks, Track{ Name: item.Artist, Track: item.Name, Plays: int(item.Plays), Image: item.Image })
+    }
+
+    return tracks
+}
+
+func (s *Server) topArtists(ctx context.Context, uid int64, period Period) []Artist {
+    artists := []Artist{}
+
+    stats, err := topStats(ctx, s.authCfg.database, uid, StatsQuery{ Entity: STATS_ARTIST, Period: period, Limit: 10 })
+    if err != nil {
+        s.log.Error("Getting Top Artists", "uid", uid, "error", err)
+        return artists
+    }
+
+    s.addStatsImages(ctx, &stats)
+    for _, item := range stats.Results {
+        artists = append(artists, Artist{ Name: item.Artist, Plays: int(item.Plays), Image: item.Image })
+    }
+
+    return artists
+}
+
 func (s *Server) GetSettingsData(w http.ResponseWriter, r *http.Request) error {
     user, err := s.authCfg.database.GetUser(r.Context(), r.Context().Value("username").(string))
     if err != nil && err != sql.ErrNoRows {
@@ -661,18 +583,40 @@ func (s *Server) GetSettingsData(w http.ResponseWriter, r *http.Request) error {
         return err
     }
 
+    lastfm, err := s.authCfg.database.GetLastFMSession(r.Context(), r.Context().Value("username").(string))
+    if err != nil && err != sql.ErrNoRows {
+        return err
+    }
+
+    type SourceSetting struct {
+        Type string `json:"type"`
+        Name string `json:"name"`
+        Tracking bool `json:"tracking"`
+    }
+
     type Data struct {
+        Sources []SourceSetting `json:"sources"`
         SpotifyTrack string `json:"spotifyTrack"`
         SpotifyAuthURL string `json:"spotifyUrl"`
         SpotifyOn bool `json:"spotifyOn"`
         TwitterOn bool `json:"twitterOn"`
         TwitterAuthURL string `json:"twitterUrl"`
+        LastFMOn bool `json:"lastfmOn"`
+        LastFMAuthURL string `json:"lastfmUrl"`
+        Timezone string `json:"timezone"`
+        Email string `json:"email"`
+        EmailVerified bool `json:"emailVerified"`
         NavLinks []NavLink `json:"links"`
         Title string `json:"title"`
         Subtitle string `json:"subtitle"`
     }
 
-    data := Data{}
+    email, err := s.authCfg.database.GetUserEmail(r.Context(), user.ID)
+    if err != nil && err != sql.ErrNoRows {
+        return err
+    }
+
+    data := Data{ Timezone: user.Timezone, Email: email.Email.String, EmailVerified: email.EmailVerifiedAt.Valid }
     data.Title = "Settings"
     data.Subtitle = "Configure your preferences"
     data.NavLinks = []NavLink{
@@ -690,12 +634,20 @@ func (s *Server) GetSettingsData(w http.ResponseWriter, r *http.Request) error {
         }, s.authCfg.database)
     }
 
-    for _, v := range sessions {
-        if strings.EqualFold(v.Type, "spotify") {
-            if v.Active == 1 {
-                data.SpotifyTrack = "checked"
+    for _, src := range MusicSources() {
+        setting := SourceSetting{ Type: src.Type, Name: src.Name }
+
+        for _, v := range sessions {
+            if strings.EqualFold(v.Type, src.Type) && v.Active == 1 {
+                setting.Tracking = true
             }
         }
+
+        data.Sources = append(data.Sources, setting)
+
+        if src.Type == "spotify" && setting.Tracking {
+            data.SpotifyTrack = "checked"
+        }
     }
 
     if twitter.TwitterOauthToken.Valid && twitter.TwitterOauthSecret.Valid {
@@ -704,6 +656,12 @@ func (s *Server) GetSettingsData(w http.ResponseWriter, r *http.Request) error {
         data.TwitterAuthURL = GetAuthURL(context.Background(), s.authCfg.TwitterOAuth, s.authCfg.database, user.Username)
     }
 
+    if lastfm.LastfmSessionKey.Valid && lastfm.LastfmSessionName.Valid {
+        data.LastFMOn = true
+    } else {
+        data.LastFMAuthURL = GetLastFMAuthURL(r.Context(), user.Username, LastFMConfig(s.authCfg.config.LastFM), s.authCfg.database)
+    }
+
     encode(w, 200, data)
     return nil
 }
@@ -718,7 +676,10 @@ func (s *Server) LogUserIn(w http.ResponseWriter, r *http.Request) error {
         return fmt.Errorf(AUTH_ERROR)
     }
 
-    s.setTokens(w, r, username)
+    if err := s.setTokens(w, r, username); err != nil {
+        return err
+    }
+
     http.Redirect(w, r, "/settings", http.StatusSeeOther)
     s.log.Info("Login from FE", "username", username, "password", password)
     return nil
@@ -731,30 +692,62 @@ func (s *Server) LogUserOut(w http.ResponseWriter, r *http.Request) error {
     return nil
 }
 
-func (s *Server) GetResetPasswordData(w http.ResponseWriter, r *http.Request) error {
-    type Data struct {
-        Valid bool `json:"valid"`
-        Username string `json:"username"`
-        Reset string `json:"reset"`
+func (s *Server) HealthCheck(w http.ResponseWriter, r *http.Request) error {
+    resp := SuccessResp{ Success: true }
+
+    encode(w, http.StatusOK, resp)
+    return nil
+}
+
+func (s *Server) SaveTimezone(w http.ResponseWriter, r *http.Request) error {
+    type Body struct {
+        Timezone string `json:"timezone"`
     }
 
-    reset := r.PathValue("resetvalue")
+    user, err := s.currentUser(r)
+    if err != nil {
+        return err
+    }
 
-    dbValue, _ := s.authCfg.database.CanResetPassword(r.Context(), database.CanResetPasswordParams{
-        ResetTime: sql.NullInt64{ Int64: time.Now().Unix(), Valid: true },
-        Reset: sql.NullString{ String: reset, Valid: true },
-    })
+    body, err := decode[Body](r)
+    if err != nil {
+        return fmt.Errorf(BAD_REQUEST_ERROR)
+    }
+
+    if _, err := time.LoadLocation(body.Timezone); err != nil || body.Timezone == "" || body.Timezone == "Local" {
+        return fmt.Errorf(BAD_REQUEST_ERROR)
+    }
 
-    data := Data{ Valid: dbValue.Valid, Username: dbValue.Username, Reset: reset }
+    if err := s.authCfg.database.SetUserTimezone(r.Context(), database.SetUserTimezoneParams{ Timezone: body.Timezone, ID: user.ID }); err != nil {
+        s.log.Error("Saving Timezone", "username", user.Username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
 
-    encode(w, 200, data)
+    encode(w, http.StatusOK, SuccessResp{ Success: true })
     return nil
 }
 
-func (s *Server) HealthCheck(w http.ResponseWriter, r *http.Request) error {
-    resp := SuccessResp{ Success: true }
+// ServeArtwork serves an image from the artwork cache. Rows never change once
+// found, so browsers can hold on to them.
+func (s *Server) ServeArtwork(w http.ResponseWriter, r *http.Request) error {
+    id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+    if err != nil {
+        return fmt.Errorf(NOT_FOUND_ERROR)
+    }
 
-    encode(w, http.StatusOK, resp)
+    image, err := s.authCfg.database.GetArtworkImage(r.Context(), id)
+    if err == sql.ErrNoRows || (err == nil && len(image.Image) == 0) {
+        return fmt.Errorf(NOT_FOUND_ERROR)
+    }
+
+    if err != nil {
+        s.log.Error("Loading Artwork", "id", id, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    w.Header().Set("Content-Type", image.ContentType.String)
+    w.Header().Set("Cache-Control", "public, max-age=604800")
+    w.Write(image.Image)
     return nil
 }
 
@@ -782,6 +775,153 @@ func (s *Server) TwitterRedirect(w http.ResponseWriter, r *http.Request) error {
     return nil
 }
 
+func (s *Server) LastFMRedirect(w http.ResponseWriter, r *http.Request) error {
+    username := r.Context().Value("username").(string)
+    token := r.URL.Query().Get("token")
+    state := r.URL.Query().Get("state")
+
+    if token != "" {
+        claimed, err := s.authCfg.database.ClaimLastFMAuthState(r.Context(), database.ClaimLastFMAuthStateParams{
+            Username: username,
+            LastfmAuthState: sql.NullString{ String: state, Valid: true },
+        })
+
+        if err != nil {
+            s.log.Error("LastFM Auth State", "username", username, "err", err)
+            return fmt.Errorf(INTERNAL_ERROR)
+        }
+
+        if state == "" || claimed == 0 {
+            s.log.Error("LastFM Auth State Mismatch", "username", username)
+            return fmt.Errorf(AUTH_ERROR)
+        }
+
+        lastfm := NewLastFM(username, LastFMConfig(s.authCfg.config.LastFM), s.authCfg.database)
+        if err := lastfm.AuthWithToken(r.Context(), token); err != nil {
+            s.log.Error("LastFM Auth Failure", "username", username, "err", err)
+            return fmt.Errorf(AUTH_ERROR)
+        }
+
+        s.log.Info("LastFM Auth Redirect", "username", username)
+    }
+
+    http.Redirect(w, r, "/settings", http.StatusSeeOther)
+    return nil
+}
+
+func (s *Server) RemoveLastFM(w http.ResponseWriter, r *http.Request) error {
+    err := s.authCfg.database.RemoveLastFMSession(r.Context(), r.Context().Value("username").(string))
+    if err != nil {
+        s.log.Error("Removing LastFM Session", "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    encode(w, http.StatusOK, SuccessResp{ Success: true })
+    return nil
+}
+
+func (s *Server) StartLastFMImport(w http.ResponseWriter, r *http.Request) error {
+    username := r.Context().Value("username").(string)
+
+    lastfm, err := s.authCfg.database.GetLastFMSession(r.Context(), username)
+    if err != nil || !lastfm.LastfmSessionKey.Valid {
+        return fmt.Errorf(NOT_FOUND_ERROR)
+    }
+
+    user, err := s.authCfg.database.GetUser(r.Context(), username)
+    if err != nil {
+        s.log.Error("Getting User", "username", username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    if err := s.authCfg.lastfmImporter.Start(r.Context(), user.ID); err != nil {
+        s.log.Error("Starting LastFM Import", "username", username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    s.log.Info("LastFM Import Started", "username", username)
+    return s.GetLastFMImport(w, r)
+}
+
+func (s *Server) GetLastFMImport(w http.ResponseWriter, r *http.Request) error {
+    type Data struct {
+        Status string `json:"status"`
+        Page int64 `json:"page"`
+        TotalPages int64 `json:"totalPages"`
+        Imported int64 `json:"imported"`
+        Skipped int64 `json:"skipped"`
+        StartedAt int64 `json:"startedAt"`
+        UpdatedAt int64 `json:"updatedAt"`
+        Error string `json:"error,omitempty"`
+    }
+
+    username := r.Context().Value("username").(string)
+    user, err := s.authCfg.database.GetUser(r.Context(), username)
+    if err != nil {
+        s.log.Error("Getting User", "username", username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    imp, err := s.authCfg.database.GetLastFMImport(r.Context(), user.ID)
+    if err == sql.ErrNoRows {
+        encode(w, http.StatusOK, Data{ Status: "none" })
+        return nil
+    }
+
+    if err != nil {
+        s.log.Error("Getting LastFM Import", "username", username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    encode(w, http.StatusOK, Data{
+        Status: imp.Status,
+        Page: imp.Page,
+        TotalPages: imp.TotalPages,
+        Imported: imp.Imported,
+        Skipped: imp.Skipped,
+        StartedAt: imp.StartedAt,
+        UpdatedAt: imp.UpdatedAt,
+        Error: imp.Error.String,
+    })
+
+    return nil
+}
+
+func (s *Server) ImportSpotify(w http.ResponseWriter, r *http.Request) error {
+    username := r.Context().Value("username").(string)
+    r.Body = http.MaxBytesReader(w, r.Body, maxSpotifyUploadSize)
+
+    file, header, err := r.FormFile("history")
+    if err != nil {
+        s.log.Error("Reading Spotify Upload", "username", username, "error", err)
+        return fmt.Errorf(BAD_REQUEST_ERROR)
+    }
+
+    defer file.Close()
+
+    entries, err := spotup.Read(file, header.Size)
+    if err != nil {
+        s.log.Error("Parsing Spotify Upload", "username", username, "error", err)
+        return fmt.Errorf(BAD_REQUEST_ERROR)
+    }
+
+    user, err := s.authCfg.database.GetUser(r.Context(), username)
+    if err != nil {
+        s.log.Error("Getting User", "username", username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    result, err := ImportSpotifyHistory(r.Context(), s.authCfg.conn, user.ID, entries)
+    if err != nil {
+        s.log.Error("Importing Spotify History", "username", username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    s.log.Info("Spotify History Imported", "username", username, "entries", result.Entries, "imported", result.Imported)
+    encode(w, http.StatusOK, result)
+    return nil
+}
+
 func (s *Server) SpotifyRedirect(w http.ResponseWriter, r *http.Request) error {
     state := r.URL.Query().Get("state")
     username := DecodeRandomState(state)
@@ -808,3 +948,184 @@ func (s *Server) SpotifyRedirect(w http.ResponseWriter, r *http.Request) error {
     return nil
 }
 
+
+func (s *Server) GetScrobbleRules(w http.ResponseWriter, r *http.Request) error {
+    type Example struct {
+        Length int `json:"length"`
+        Needed int `json:"needed"`
+    }
+
+    type Rule struct {
+        ScrobbleRule
+        Examples []Example `json:"examples"`
+    }
+
+    type Data struct {
+        Rules []Rule `json:"rules"`
+        Sources []string `json:"sources"`
+    }
+
+    username := r.Context().Value("username").(string)
+    user, err := s.authCfg.database.GetUser(r.Context(), username)
+    if err != nil {
+        s.log.Error("Getting User", "username", username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    rows, err := s.authCfg.database.GetScrobbleRules(r.Context(), user.ID)
+    if err != nil {
+        s.log.Error("Getting Scrobble Rules", "username", username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    // The default rule always comes first, stored or not, so the page can show
+    // what applies to sources without an override.
+    rules := []ScrobbleRule{ scrobbleRuleFor(rows, "") }
+    for _, row := range rows {
+        if row.Source != "" {
+            rules = append(rules, scrobbleRuleFromDB(row))
+        }
+    }
+
+    sources, err := scrobbleSources(r.Context(), s.authCfg.database, user.ID)
+    if err != nil {
+        s.log.Error("Getting Scrobble Sources", "username", username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    data := Data{ Sources: sources }
+
+    for _, rule := range rules {
+        item := Rule{ ScrobbleRule: rule }
+
+        for _, length := range []int{ 20, 210, 600 } {
+            item.Examples = append(item.Examples, Example{ Length: length, Needed: rule.Threshold(length * 1000) / 1000 })
+        }
+
+        data.Rules = append(data.Rules, item)
+    }
+
+    encode(w, http.StatusOK, data)
+    return nil
+}
+
+func (s *Server) SaveScrobbleRule(w http.ResponseWriter, r *http.Request) error {
+    username := r.Context().Value("username").(string)
+    user, err := s.authCfg.database.GetUser(r.Context(), username)
+    if err != nil {
+        s.log.Error("Getting User", "username", username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    rule, err := decode[ScrobbleRule](r)
+    if err != nil || !rule.Valid() {
+        return fmt.Errorf(BAD_REQUEST_ERROR)
+    }
+
+    if err := s.authCfg.database.SaveScrobbleRule(r.Context(), rule.toParams(user.ID)); err != nil {
+        s.log.Error("Saving Scrobble Rule", "username", username, "source", rule.Source, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    encode(w, http.StatusOK, SuccessResp{ Success: true })
+    return nil
+}
+
+// DeleteScrobbleRule removes a source override. Deleting the default rule puts
+// the built in one back.
+func (s *Server) DeleteScrobbleRule(w http.ResponseWriter, r *http.Request) error {
+    username := r.Context().Value("username").(string)
+    user, err := s.authCfg.database.GetUser(r.Context(), username)
+    if err != nil {
+        s.log.Error("Getting User", "username", username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    source := strings.ToLower(r.URL.Query().Get("source"))
+    err = s.authCfg.database.DeleteScrobbleRule(r.Context(), database.DeleteScrobbleRuleParams{ Uid: user.ID, Source: source })
+    if err != nil {
+        s.log.Error("Deleting Scrobble Rule", "username", username, "source", source, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    encode(w, http.StatusOK, SuccessResp{ Success: true })
+    return nil
+}
+
+func (s *Server) GetRewriteRules(w http.ResponseWriter, r *http.Request) error {
+    type Data struct {
+        Rules []RewriteRule `json:"rules"`
+    }
+
+    user, err := s.currentUser(r)
+    if err != nil {
+        return err
+    }
+
+    rows, err := s.authCfg.database.GetRewriteRules(r.Context(), user.ID)
+    if err != nil {
+        s.log.Error("Getting Rewrite Rules", "username", user.Username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    data := Data{ Rules: []RewriteRule{} }
+    for _, row := range rows {
+        data.Rules = append(data.Rules, rewriteRuleFromDB(row))
+    }
+
+    encode(w, http.StatusOK, data)
+    return nil
+}
+
+func (s *Server) AddRewriteRule(w http.ResponseWriter, r *http.Request) error {
+    user, err := s.currentUser(r)
+    if err != nil {
+        return err
+    }
+
+    rule, err := decode[RewriteRule](r)
+    if err != nil || !rule.Valid() {
+        return fmt.Errorf(BAD_REQUEST_ERROR)
+    }
+
+    rule.Id, err = s.authCfg.database.SaveRewriteRule(r.Context(), database.SaveRewriteRuleParams{
+        Uid: user.ID,
+        Field: rule.Field,
+        MatchType: rule.Match,
+        Pattern: rule.Pattern,
+        Replacement: rule.Replacement,
+    })
+
+    if err != nil {
+        s.log.Error("Saving Rewrite Rule", "username", user.Username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    encode(w, http.StatusOK, rule)
+    return nil
+}
+
+func (s *Server) DeleteRewriteRule(w http.ResponseWriter, r *http.Request) error {
+    user, err := s.currentUser(r)
+    if err != nil {
+        return err
+    }
+
+    id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+    if err != nil {
+        return fmt.Errorf(NOT_FOUND_ERROR)
+    }
+
+    deleted, err := s.authCfg.database.DeleteRewriteRule(r.Context(), database.DeleteRewriteRuleParams{ ID: id, Uid: user.ID })
+    if err != nil {
+        s.log.Error("Deleting Rewrite Rule", "username", user.Username, "id", id, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    if deleted == 0 {
+        return fmt.Errorf(NOT_FOUND_ERROR)
+    }
+
+    encode(w, http.StatusOK, SuccessResp{ Success: true })
+    return nil
+}
@@ -0,0 +1,154 @@
+package app
+
+import (
+	"context"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/cg219/nowplaying/internal/database"
+)
+
+const (
+    // RULE_MODE_SECONDS needs MinSeconds of playback, or MinPercent for tracks
+    // shorter than that. RULE_MODE_EITHER needs whichever comes first, which is
+    // how Last.fm counts (50% or 4 minutes).
+    RULE_MODE_SECONDS = "seconds"
+    RULE_MODE_EITHER = "either"
+)
+
+// ScrobbleRule decides whether a play counts. A rule with an empty Source is the
+// user's default; any other Source overrides it for scrobbles from that source.
+type ScrobbleRule struct {
+    Source string `json:"source"`
+    Mode string `json:"mode"`
+    MinPercent int `json:"minPercent"`
+    MinSeconds int `json:"minSeconds"`
+    MaxTrackSeconds int `json:"maxTrackSeconds"`
+    CountRepeats bool `json:"countRepeats"`
+}
+
+var defaultScrobbleRule = ScrobbleRule{
+    Mode: RULE_MODE_SECONDS,
+    MinPercent: 50,
+    MinSeconds: int(minScrobbleProgress.Seconds()),
+    CountRepeats: true,
+}
+
+// Threshold is how much of a track, in milliseconds, has to be played before it
+// can be scrobbled.
+func (r ScrobbleRule) Threshold(duration int) int {
+    seconds := r.MinSeconds * 1000
+    percent := int(math.Round(float64(duration) * float64(r.MinPercent) / 100))
+
+    if r.Mode == RULE_MODE_EITHER {
+        return min(seconds, percent)
+    }
+
+    if duration > seconds {
+        return seconds
+    }
+
+    return percent
+}
+
+// Check returns the reason a scrobble doesn't pass the rule, or an empty string
+// if it does.
+func (r ScrobbleRule) Check(sc Scrobble) string {
+    if r.MaxTrackSeconds > 0 && sc.Duration > r.MaxTrackSeconds * 1000 {
+        return "Longer than the maximum track length"
+    }
+
+    if sc.Progress < r.Threshold(sc.Duration) {
+        return "Not played long enough"
+    }
+
+    return ""
+}
+
+func (r ScrobbleRule) Valid() bool {
+    if r.Mode != RULE_MODE_SECONDS && r.Mode != RULE_MODE_EITHER {
+        return false
+    }
+
+    return r.MinPercent >= 0 && r.MinPercent <= 100 && r.MinSeconds >= 0 && r.MaxTrackSeconds >= 0
+}
+
+func (r ScrobbleRule) toParams(uid int64) database.SaveScrobbleRuleParams {
+    repeats := 0
+    if r.CountRepeats {
+        repeats = 1
+    }
+
+    return database.SaveScrobbleRuleParams{
+        Uid: uid,
+        Source: strings.ToLower(r.Source),
+        Mode: r.Mode,
+        MinPercent: int64(r.MinPercent),
+        MinSeconds: int64(r.MinSeconds),
+        MaxTrackSeconds: int64(r.MaxTrackSeconds),
+        CountRepeats: int64(repeats),
+    }
+}
+
+func scrobbleRuleFromDB(row database.ScrobbleRule) ScrobbleRule {
+    return ScrobbleRule{
+        Source: row.Source,
+        Mode: row.Mode,
+        MinPercent: int(row.MinPercent),
+        MinSeconds: int(row.MinSeconds),
+        MaxTrackSeconds: int(row.MaxTrackSeconds),
+        CountRepeats: row.CountRepeats == 1,
+    }
+}
+
+// scrobbleRuleFor picks the rule for a source: its override if there is one,
+// then the user's default, then the built in default.
+func scrobbleRuleFor(rows []database.ScrobbleRule, source string) ScrobbleRule {
+    rule := defaultScrobbleRule
+
+    for _, row := range rows {
+        if row.Source == "" {
+            rule = scrobbleRuleFromDB(row)
+        }
+    }
+
+    for _, row := range rows {
+        if row.Source != "" && strings.EqualFold(row.Source, source) {
+            return scrobbleRuleFromDB(row)
+        }
+    }
+
+    return rule
+}
+
+func loadScrobbleRule(ctx context.Context, db *database.Queries, uid int64, source string) (ScrobbleRule, error) {
+    rows, err := db.GetScrobbleRules(ctx, uid)
+    if err != nil {
+        return defaultScrobbleRule, err
+    }
+
+    return scrobbleRuleFor(rows, source), nil
+}
+
+// scrobbleSources lists the sources a rule can be set for: what the built in
+// sources save as Scrobble.Source, then anything else the user's scrobbles
+// came from, like ListenBrainz players and API clients. Last.fm imports skip
+// the rules, so they aren't offered.
+func scrobbleSources(ctx context.Context, db *database.Queries, uid int64) ([]string, error) {
+    sources := []string{ SPOTIFY_SOURCE, SPOTIFY_IMPORT_SOURCE, "jellyfin", "emby", "plex", AS_SOURCE }
+
+    rows, err := db.GetScrobbleSources(ctx, uid)
+    if err != nil {
+        return sources, err
+    }
+
+    for _, row := range rows {
+        source := strings.ToLower(row.String)
+        if source != LASTFM_IMPORT_SOURCE && !slices.Contains(sources, source) {
+            sources = append(sources, source)
+        }
+    }
+
+    return sources, nil
+}
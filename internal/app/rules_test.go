@@ -0,0 +1,65 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/cg219/nowplaying/internal/database"
+)
+
+func TestThreshold(t *testing.T) {
+    lastfm := ScrobbleRule{ Mode: RULE_MODE_EITHER, MinPercent: 50, MinSeconds: 240 }
+
+    tests := []struct {
+        rule ScrobbleRule
+        duration int
+        want int
+    }{
+        { defaultScrobbleRule, 20000, 10000 },
+        { defaultScrobbleRule, 210000, 30000 },
+        { defaultScrobbleRule, 600000, 30000 },
+        { lastfm, 20000, 10000 },
+        { lastfm, 210000, 105000 },
+        { lastfm, 600000, 240000 },
+    }
+
+    for _, tt := range tests {
+        if got := tt.rule.Threshold(tt.duration); got != tt.want {
+            t.Errorf("%s rule, %dms track: got %d, want %d", tt.rule.Mode, tt.duration, got, tt.want)
+        }
+    }
+}
+
+func TestCheck(t *testing.T) {
+    rule := ScrobbleRule{ Mode: RULE_MODE_SECONDS, MinPercent: 50, MinSeconds: 30, MaxTrackSeconds: 900 }
+
+    if reason := rule.Check(Scrobble{ Duration: 200000, Progress: 30000 }); reason != "" {
+        t.Errorf("expected scrobble to pass, got %q", reason)
+    }
+
+    if reason := rule.Check(Scrobble{ Duration: 200000, Progress: 29000 }); reason == "" {
+        t.Error("expected short play to fail")
+    }
+
+    if reason := rule.Check(Scrobble{ Duration: 3600000, Progress: 3600000 }); reason == "" {
+        t.Error("expected long track to fail")
+    }
+}
+
+func TestScrobbleRuleFor(t *testing.T) {
+    rows := []database.ScrobbleRule{
+        { Source: "", Mode: RULE_MODE_EITHER, MinPercent: 50, MinSeconds: 240, CountRepeats: 1 },
+        { Source: "plex", Mode: RULE_MODE_SECONDS, MinPercent: 80, MinSeconds: 60 },
+    }
+
+    if rule := scrobbleRuleFor(nil, "spotify"); rule != defaultScrobbleRule {
+        t.Errorf("expected built in default, got %+v", rule)
+    }
+
+    if rule := scrobbleRuleFor(rows, "spotify"); rule.MinSeconds != 240 || !rule.CountRepeats {
+        t.Errorf("expected user default, got %+v", rule)
+    }
+
+    if rule := scrobbleRuleFor(rows, "Plex"); rule.MinPercent != 80 || rule.CountRepeats {
+        t.Errorf("expected plex override, got %+v", rule)
+    }
+}
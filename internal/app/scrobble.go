@@ -6,48 +6,66 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"math"
 	"time"
 
 	"github.com/cg219/nowplaying/internal/database"
 )
 
+const (
+    SCROBBLE_ACCEPTED = "accepted"
+    SCROBBLE_IGNORED = "ignored"
+    SCROBBLE_REJECTED = "rejected"
+    maxScrobbleBatch = 500
+    minScrobbleProgress = time.Second * 30
+    duplicateScrobbleWindow = time.Second * 60
+)
+
 type Scrobbler struct {
     Username string
     Duration time.Duration
     db *database.Queries
+    conn *sql.DB
     Id int
 }
 
 type Scrobble struct {
     ArtistName string
+    Artists []string
     TrackName string
     Timestamp int
     AlbumArtist string
     AlbumName string
     TrackNumber string
     Mbid string
+    AlbumMbid string
+    ArtistMbid string
     Source string
     Duration int
     Uid int
     Progress int
 }
 
+type ScrobbleResult struct {
+    Status string `json:"status"`
+    Reason string `json:"reason,omitempty"`
+}
+
 type ScrobbleEncoded struct {
     Username string `json:"u"`
     Duration int `json:"d"`
 }
 
-func NewScrobbler(u string, db *database.Queries) *Scrobbler {
+func NewScrobbler(u string, conn *sql.DB, db *database.Queries) *Scrobbler {
     return &Scrobbler{
         Username: u,
         db: db,
+        conn: conn,
         Duration: time.Second * 15,
     }
 }
 
-func NewScrobblerFromEncoded(encoded []byte, db *database.Queries) *Scrobbler {
-    s := &Scrobbler{ db: db }
+func NewScrobblerFromEncoded(encoded []byte, conn *sql.DB, db *database.Queries) *Scrobbler {
+    s := &Scrobbler{ db: db, conn: conn }
     s.Decode(encoded)
     return s
 }
@@ -100,42 +118,67 @@ func (s *Scrobbler) AuthWithDB(ctx context.Context) error {
 }
 
 func (s *Scrobbler) Scrobble(ctx context.Context, sc Scrobble) bool {
-    dbValue, err := s.db.GetLatestTrack(ctx, int64(sc.Uid))
+    result, err := s.Submit(ctx, sc)
+    if err != nil {
+        log.Printf("Scrobble failed: %s\n", err)
+        return false
+    }
 
-    if err == sql.ErrNoRows {
-        if sc.Duration > int(time.Duration(time.Second * 30).Milliseconds()) {
-            if sc.Progress < int(time.Duration(time.Second * 30).Milliseconds()) {
-                return false
-            }
-        } else {
-            if float64(sc.Progress) < math.Round(float64(sc.Duration) * .5) {
-                return false
-            }
-        }
+    return result.Status == SCROBBLE_ACCEPTED
+}
 
-        s.db.SaveScrobble(ctx, scrobbleToParams(sc))
-        return false
+// Submit runs the same checks as Scrobble but reports why a track was not
+// saved, for callers that pass the outcome back to a client. The user's rule
+// for the scrobble's source decides whether the play counts.
+func (s *Scrobbler) Submit(ctx context.Context, sc Scrobble) (ScrobbleResult, error) {
+    rule, err := loadScrobbleRule(ctx, s.db, int64(sc.Uid), sc.Source)
+    if err != nil {
+        return ScrobbleResult{}, err
     }
 
-    if sc.ArtistName == dbValue.ArtistName &&
-        sc.TrackName == dbValue.TrackName &&
-        sc.Duration == int(dbValue.Duration) &&
-        sc.Timestamp <= int(dbValue.Timestamp + dbValue.Duration) {
-        return false
+    dbValue, err := s.db.GetLatestTrack(ctx, int64(sc.Uid))
+    if err != nil && err != sql.ErrNoRows {
+        return ScrobbleResult{}, err
     }
 
-    if sc.Duration > int(time.Duration(time.Second * 30).Milliseconds()) {
-        if sc.Progress < int(time.Duration(time.Second * 30).Milliseconds()) {
-            return false
+    if err == nil && sc.ArtistName == dbValue.ArtistName && sc.TrackName == dbValue.TrackName {
+        // A play that started before the last one was half over is the same
+        // play; one that starts as it ends is a replay.
+        if sc.Duration == int(dbValue.Duration) && sc.Timestamp < int(dbValue.Timestamp + dbValue.Duration / 2) {
+            return ScrobbleResult{ Status: SCROBBLE_IGNORED, Reason: "Same as the last scrobble" }, nil
         }
-    } else {
-        if float64(sc.Progress) < math.Round(float64(sc.Duration) * .5) {
-            return false
+
+        if !rule.CountRepeats {
+            return ScrobbleResult{ Status: SCROBBLE_IGNORED, Reason: "Repeat of the last scrobble" }, nil
         }
     }
 
-    s.db.SaveScrobble(ctx, scrobbleToParams(sc))
-    return true
+    if reason := rule.Check(sc); reason != "" {
+        return ScrobbleResult{ Status: SCROBBLE_IGNORED, Reason: reason }, nil
+    }
+
+    if err := saveScrobble(ctx, s.conn, s.db, sc); err != nil {
+        return ScrobbleResult{}, err
+    }
+
+    return ScrobbleResult{ Status: SCROBBLE_ACCEPTED }, nil
+}
+
+// scrobbleExists reports whether the user already has this track scrobbled
+// within a minute of timestamp. Imports use it to avoid doubling up history
+// that was also tracked live.
+func scrobbleExists(ctx context.Context, db *database.Queries, uid int64, artist string, track string, timestamp int64) (bool, error) {
+    window := duplicateScrobbleWindow.Milliseconds()
+
+    found, err := db.ScrobbleExistsNear(ctx, database.ScrobbleExistsNearParams{
+        Uid: uid,
+        ArtistName: artist,
+        TrackName: track,
+        Start: timestamp - window,
+        End: timestamp + window,
+    })
+
+    return found == 1, err
 }
 
 func scrobbleToParams(sc Scrobble) database.SaveScrobbleParams {
@@ -147,6 +190,8 @@ func scrobbleToParams(sc Scrobble) database.SaveScrobbleParams {
         AlbumArtist: sql.NullString{ String: sc.AlbumArtist, Valid: sc.AlbumArtist != "" },
         Source: sql.NullString{ String: sc.Source, Valid: sc.Source != "" },
         Mbid: sql.NullString{ String: sc.Mbid, Valid: sc.Mbid != "" },
+        AlbumMbid: sql.NullString{ String: sc.AlbumMbid, Valid: sc.AlbumMbid != "" },
+        ArtistMbid: sql.NullString{ String: sc.ArtistMbid, Valid: sc.ArtistMbid != "" },
         TrackNumber: sql.NullString{ String: sc.TrackNumber, Valid: sc.TrackNumber != "" },
         Duration: int64(sc.Duration),
         Uid: int64(sc.Uid),
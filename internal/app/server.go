@@ -10,6 +10,7 @@ import (
 	"log"
 	"log/slog"
 	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
 	"strings"
@@ -27,6 +28,11 @@ type Server struct {
     authCfg *AppCfg
     log *slog.Logger
     hasher *argon2id.Argon2id
+    resetIPs *RateLimiter
+    resetUsers *RateLimiter
+    asLoginIPs *RateLimiter
+    asLoginUsers *RateLimiter
+    proxies []netip.Prefix
 }
 
 type SuccessResp struct {
@@ -65,12 +71,18 @@ const (
     USERNAME_EXISTS_ERROR = "Username Exists Error"
     GOTO_NEXT_HANDLER_ERROR = "Redirect Error"
     REDIRECT_ERROR = "Intentional Redirect Error"
+    NOT_FOUND_ERROR = "Not Found Error"
+    BAD_REQUEST_ERROR = "Bad Request Error"
+    TOO_MANY_REQUESTS_ERROR = "Too Many Requests Error"
 )
 const (
     CODE_USER_EXISTS = iota
     AUTH_FAIL
     AUTH_NOT_ALLOWED
     INTERNAL_SERVER_ERROR
+    NOT_FOUND
+    BAD_REQUEST
+    TOO_MANY_REQUESTS
 )
 
 func NewServer(cfg *AppCfg) *Server {
@@ -79,6 +91,11 @@ func NewServer(cfg *AppCfg) *Server {
         authCfg: cfg,
         log: slog.New(slog.NewTextHandler(os.Stderr, nil)),
         hasher: argon2id.NewArgon2id(16 * 1024, 2, 1, 16, 32),
+        resetIPs: NewRateLimiter(resetIPLimit, resetLimitWindow),
+        resetUsers: NewRateLimiter(resetUserLimit, resetLimitWindow),
+        asLoginIPs: NewRateLimiter(asLoginIPLimit, asLoginWindow),
+        asLoginUsers: NewRateLimiter(asLoginUserLimit, asLoginWindow),
+        proxies: parseProxies(cfg.config.Data.Proxies),
     }
 }
 
@@ -98,32 +115,89 @@ func addRoutes(srv *Server) {
     srv.mux.Handle("POST /api/login", srv.handle(srv.LogUserIn))
     srv.mux.Handle("POST /api/logout", srv.handle(srv.UserOnly, srv.LogUserOut))
     srv.mux.Handle("POST /api/settings", srv.handle(srv.UserOnly, srv.GetSettingsData))
-    srv.mux.Handle("POST /api/scrobble", srv.handle(srv.UserOnly, srv.ScrobbleSong))
-    srv.mux.Handle("POST /api/generate-apikey/{name}", srv.handle(srv.UserOnly, srv.GenerateAPIKey))
-    srv.mux.Handle("GET /api/last-scrobble", srv.handle(srv.UserOnly, srv.GetLastScrobble))
+    srv.mux.Handle("POST /api/scrobble", srv.handle(srv.UserOrKey(SCOPE_SCROBBLE), srv.ScrobbleSong))
+    srv.mux.Handle("GET /api/apikeys", srv.handle(srv.UserOnly, srv.GetAPIKeys))
+    srv.mux.Handle("POST /api/apikeys", srv.handle(srv.UserOnly, srv.GenerateAPIKey))
+    srv.mux.Handle("DELETE /api/apikeys/{id}", srv.handle(srv.UserOnly, srv.DeleteAPIKey))
+    srv.mux.Handle("GET /api/sessions", srv.handle(srv.UserOnly, srv.GetSessions))
+    srv.mux.Handle("DELETE /api/sessions", srv.handle(srv.UserOnly, srv.RevokeAllSessions))
+    srv.mux.Handle("DELETE /api/sessions/{id}", srv.handle(srv.UserOnly, srv.RevokeSession))
+    srv.mux.Handle("POST /api/webhooks/jellyfin", srv.handle(srv.APIKeyOnly, srv.JellyfinWebhook))
+    srv.mux.Handle("POST /api/webhooks/emby", srv.handle(srv.APIKeyOnly, srv.EmbyWebhook))
+    srv.mux.Handle("POST /api/webhooks/plex", srv.handle(srv.PlexKeyOnly, srv.PlexWebhook))
+    srv.mux.Handle("POST /1/submit-listens", srv.handle(srv.ListenBrainzSubmit))
+    srv.mux.Handle("GET /1/validate-token", srv.handle(srv.ListenBrainzValidateToken))
+
+    // Without a shared secret anyone could sign requests, so the endpoint is
+    // left off entirely.
+    if srv.authCfg.config.Audioscrobbler.Secret != "" {
+        srv.mux.Handle("GET /2.0/", srv.handle(srv.Audioscrobbler))
+        srv.mux.Handle("POST /2.0/", srv.handle(srv.Audioscrobbler))
+    } else {
+        srv.log.Warn("Audioscrobbler API disabled", "reason", "AUDIOSCROBBLER_SECRET is not set")
+    }
+
+    // Reset and verification links are built from the app url only.
+    if srv.authCfg.config.Data.Url == "" {
+        srv.log.Warn("Email links disabled", "reason", "APP_URL is not set")
+    }
+
+    srv.mux.Handle("GET /api/last-scrobble", srv.handle(srv.UserOrKey(SCOPE_STATS), srv.GetLastScrobble))
+    srv.mux.Handle("GET /api/now-playing", srv.handle(srv.UserOrKey(SCOPE_STATS), srv.GetNowPlaying))
     srv.mux.Handle("GET /api/events/scrobble", srv.handle(srv.UserOnly, srv.NotifyScrobble))
     srv.mux.Handle("POST /api/me", srv.handle(srv.UserOnly, srv.GetUserData))
     srv.mux.Handle("POST /api/forgot-password", srv.handle(srv.ForgotPassword))
     srv.mux.Handle("POST /api/reset-password", srv.handle(srv.ResetPassword))
-    srv.mux.Handle("POST /api/share-latest-track", srv.handle(srv.UserOnly, srv.ShareLatestTrack))
-    srv.mux.Handle("POST /api/share-top-yearly-albums", srv.handle(srv.UserOnly, srv.ShareTopYearlyAlbums))
-    srv.mux.Handle("POST /api/share-top-monthly-albums", srv.handle(srv.UserOnly, srv.ShareTopMonthlyAlbums))
-    srv.mux.Handle("POST /api/share-top-weekly-tracks", srv.handle(srv.UserOnly, srv.ShareTopWeeklyTracks))
-    srv.mux.Handle("POST /api/share-top-weekly-artists", srv.handle(srv.UserOnly, srv.ShareTopWeeklyArtists))
-    srv.mux.Handle("POST /api/share-top-daily-tracks", srv.handle(srv.UserOnly, srv.ShareTopDailyTracks))
-    srv.mux.Handle("POST /api/share-top-daily-artists", srv.handle(srv.UserOnly, srv.ShareTopDailyArtists))
+    srv.mux.Handle("POST /api/share-latest-track", srv.handle(srv.UserOrKey(SCOPE_SHARE), srv.ShareLatestTrack))
+    srv.mux.Handle("POST /api/share-top-yearly-albums", srv.handle(srv.UserOrKey(SCOPE_SHARE), srv.ShareTopYearlyAlbums))
+    srv.mux.Handle("POST /api/share-top-monthly-albums", srv.handle(srv.UserOrKey(SCOPE_SHARE), srv.ShareTopMonthlyAlbums))
+    srv.mux.Handle("POST /api/share-top-weekly-tracks", srv.handle(srv.UserOrKey(SCOPE_SHARE), srv.ShareTopWeeklyTracks))
+    srv.mux.Handle("POST /api/share-top-weekly-artists", srv.handle(srv.UserOrKey(SCOPE_SHARE), srv.ShareTopWeeklyArtists))
+    srv.mux.Handle("POST /api/share-top-daily-tracks", srv.handle(srv.UserOrKey(SCOPE_SHARE), srv.ShareTopDailyTracks))
+    srv.mux.Handle("POST /api/share-top-daily-artists", srv.handle(srv.UserOrKey(SCOPE_SHARE), srv.ShareTopDailyArtists))
     srv.mux.Handle("POST /api/spotify", srv.handle(srv.UserOnly, srv.AddSpotify))
     srv.mux.Handle("DELETE /api/spotify", srv.handle(srv.UserOnly, srv.RemoveSpotify))
+    srv.mux.Handle("DELETE /api/lastfm", srv.handle(srv.UserOnly, srv.RemoveLastFM))
+    srv.mux.Handle("POST /api/import/lastfm", srv.handle(srv.UserOnly, srv.StartLastFMImport))
+    srv.mux.Handle("GET /api/import/lastfm", srv.handle(srv.UserOnly, srv.GetLastFMImport))
+    srv.mux.Handle("POST /api/import/spotify", srv.handle(srv.UserOnly, srv.ImportSpotify))
+    srv.mux.Handle("POST /api/timezone", srv.handle(srv.UserOnly, srv.SaveTimezone))
+    srv.mux.Handle("POST /api/email", srv.handle(srv.UserOnly, srv.SetEmail))
+    srv.mux.Handle("GET /api/stats", srv.handle(srv.UserOrKey(SCOPE_STATS), srv.GetStats))
+    srv.mux.Handle("GET /api/stats/listening", srv.handle(srv.UserOrKey(SCOPE_STATS), srv.GetListeningStats))
+    srv.mux.Handle("GET /api/report", srv.handle(srv.UserOrKey(SCOPE_STATS), srv.GetReport))
+    srv.mux.Handle("GET /api/reports", srv.handle(srv.UserOnly, srv.GetSharedReports))
+    srv.mux.Handle("POST /api/reports", srv.handle(srv.UserOnly, srv.ShareReport))
+    srv.mux.Handle("GET /api/reports/{slug}", srv.handle(srv.GetSharedReport))
+    srv.mux.Handle("DELETE /api/reports/{slug}", srv.handle(srv.UserOnly, srv.DeleteSharedReport))
+    srv.mux.Handle("GET /api/scrobbles", srv.handle(srv.UserOrKey(SCOPE_STATS), srv.GetScrobbles))
+    srv.mux.Handle("PATCH /api/scrobbles/{id}", srv.handle(srv.UserOnly, srv.EditScrobble))
+    srv.mux.Handle("DELETE /api/scrobbles/{id}", srv.handle(srv.UserOnly, srv.DeleteScrobble))
+    srv.mux.Handle("POST /api/scrobbles/delete", srv.handle(srv.UserOnly, srv.DeleteScrobbles))
+    srv.mux.Handle("POST /api/scrobbles/rename/preview", srv.handle(srv.UserOnly, srv.PreviewRename))
+    srv.mux.Handle("POST /api/scrobbles/rename", srv.handle(srv.UserOnly, srv.RenameScrobbles))
+    srv.mux.Handle("GET /api/rewrite-rules", srv.handle(srv.UserOnly, srv.GetRewriteRules))
+    srv.mux.Handle("POST /api/rewrite-rules", srv.handle(srv.UserOnly, srv.AddRewriteRule))
+    srv.mux.Handle("DELETE /api/rewrite-rules/{id}", srv.handle(srv.UserOnly, srv.DeleteRewriteRule))
+    srv.mux.Handle("GET /api/scrobble-rules", srv.handle(srv.UserOnly, srv.GetScrobbleRules))
+    srv.mux.Handle("POST /api/scrobble-rules", srv.handle(srv.UserOnly, srv.SaveScrobbleRule))
+    srv.mux.Handle("DELETE /api/scrobble-rules", srv.handle(srv.UserOnly, srv.DeleteScrobbleRule))
+    srv.mux.Handle("POST /api/sources/{source}", srv.handle(srv.UserOnly, srv.AddMusicSource))
+    srv.mux.Handle("DELETE /api/sources/{source}", srv.handle(srv.UserOnly, srv.RemoveMusicSource))
     srv.mux.Handle("GET /auth/spotify-redirect", srv.handle(srv.SpotifyRedirect))
     srv.mux.Handle("GET /auth/x-redirect", srv.handle(srv.TwitterRedirect))
+    srv.mux.Handle("GET /auth/lastfm-redirect", srv.handle(srv.RedirectAuthenticated("/", false), srv.LastFMRedirect))
     srv.mux.Handle("POST /auth/register", srv.handle(srv.Register))
     srv.mux.Handle("POST /auth/login", srv.handle(srv.Login))
     srv.mux.Handle("POST /auth/logout", srv.handle(srv.UserOnly, srv.Logout))
     srv.mux.Handle("GET /healthcheck", srv.handle(srv.HealthCheck))
+    srv.mux.Handle("GET /img/{id}", srv.handle(srv.ServeArtwork))
     srv.mux.Handle("GET /me", srv.handle(srv.RedirectAuthenticated("/", false), srv.getUserPage))
     srv.mux.Handle("GET /settings", srv.handle(srv.RedirectAuthenticated("/", false), srv.getSettingsPage))
+    srv.mux.Handle("GET /r/{slug}", srv.handle(srv.ServeSharedReport))
     srv.mux.Handle("GET /reset/{resetvalue}", srv.handle(srv.getResetPage))
     srv.mux.Handle("POST /reset/{resetvalue}", srv.handle(srv.GetResetPasswordData))
+    srv.mux.Handle("GET /verify/{token}", srv.handle(srv.ConfirmEmail))
 }
 
 func (h CandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -163,20 +237,49 @@ func (s *Server) getSettingsPage(w http.ResponseWriter, r *http.Request) error {
     return nil
 }
 
-func (s *Server) setTokens(w http.ResponseWriter, r *http.Request, username string) {
-    accessToken := webtoken.NewToken("accessToken", username, "notsecure", time.Now().Add(time.Hour * 1))
-    refreshToken := webtoken.NewToken("refreshToken", webtoken.GenerateRefreshString(), "notsecure", time.Now().Add(time.Hour * 24 * 30))
+// signingKey is the key new tokens are signed with. Without an active key
+// there is nothing safe to sign with, so callers have to fail the request.
+func (s *Server) signingKey() (webtoken.Key, error) {
+    key, err := s.authCfg.keys.Active()
+    if err != nil {
+        s.log.Error("Signing Key", "error", err)
+        return webtoken.Key{}, err
+    }
+
+    return key, nil
+}
+
+func (s *Server) setTokens(w http.ResponseWriter, r *http.Request, username string) error {
+    key, err := s.signingKey()
+    if err != nil {
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    now := time.Now()
+    accessToken := webtoken.NewToken("accessToken", username, key, now.Add(time.Hour * 1))
+    refreshToken := webtoken.NewToken("refreshToken", webtoken.GenerateRefreshString(), key, now.Add(sessionLifetime))
     accessToken.Create("nowplaying")
     refreshToken.Create("nowplaying")
     cookieValue := webtoken.CookieAuthValue{ AccessToken: accessToken.Value(), RefreshToken: refreshToken.Value() }
-    cookie := webtoken.NewAuthCookie("nowplaying", "/", cookieValue, int(time.Hour * 24 * 30))
+    cookie := webtoken.NewAuthCookie("nowplaying", "/", cookieValue, int(sessionLifetime.Seconds()))
 
-    s.authCfg.database.SaveUserSession(r.Context(), database.SaveUserSessionParams{
+    err = s.authCfg.database.SaveUserSession(r.Context(), database.SaveUserSessionParams{
         Accesstoken: accessToken.Value(),
         Refreshtoken: refreshToken.Subject(),
+        CreatedAt: now.UnixMilli(),
+        LastSeenAt: now.UnixMilli(),
+        ExpiresAt: now.Add(sessionLifetime).UnixMilli(),
+        Ip: s.clientIP(r),
+        UserAgent: sessionUserAgent(r),
+        Username: username,
     })
 
+    if err != nil {
+        s.log.Error("Saving Session", "username", username, "error", err)
+    }
+
     http.SetCookie(w, &cookie)
+    return nil
 }
 
 func (s *Server) unsetTokens(w http.ResponseWriter, r *http.Request) {
@@ -184,7 +287,7 @@ func (s *Server) unsetTokens(w http.ResponseWriter, r *http.Request) {
     refreshtoken := r.Context().Value("refreshtoken").(string)
     s.log.Info("unset tokens", "refresh", refreshtoken, "access", accesstoken)
 
-    s.authCfg.database.InvalidateUserSession(r.Context(), database.InvalidateUserSessionParams{ Accesstoken: accesstoken, Refreshtoken: refreshtoken, })
+    s.authCfg.database.EndUserSession(r.Context(), refreshtoken)
     cookie := webtoken.NewAuthCookie("nowplaying", "/", webtoken.CookieAuthValue{}, int(0))
 
     http.SetCookie(w, &cookie)
@@ -196,25 +299,29 @@ func (s *Server) authenticateRequest(r *http.Request, username string) {
     updatedRequest := r.WithContext(ctx)
 
     *r = *updatedRequest
+
+    if id, ok := ctx.Value("sessionid").(int64); ok {
+        s.touchSession(r, id)
+    }
 }
 
 func (s *Server) getAuthGookie(r *http.Request) (string, string) {
     cookie, err := r.Cookie("nowplaying")
     if err != nil {
-        s.log.Error("Cookie Retrieval", "cookie", "nowplaying", "method", "UserOnly", "request", r, "error", err.Error())
+        s.log.Error("Cookie Retrieval", "cookie", "nowplaying", "method", "UserOnly", "path", r.URL.Path, "error", err.Error())
         return "", ""
     }
 
     value, err := base64.StdEncoding.DecodeString(cookie.Value)
     if err != nil {
-        s.log.Error("Base64 Decoding", "cookie", cookie.Value, "method", "UserOnly", "request", r, "error", err.Error())
+        s.log.Error("Base64 Decoding", "cookie", cookie.Value, "method", "UserOnly", "path", r.URL.Path, "error", err.Error())
         return "", ""
     }
 
     var cookieValue webtoken.CookieAuthValue
     err = json.Unmarshal(value, &cookieValue)
     if err != nil {
-        s.log.Error("Invalid Cookie Value", "cookie", cookie.Value, "method", "UserOnly", "request", r, "error", err.Error())
+        s.log.Error("Invalid Cookie Value", "cookie", cookie.Value, "method", "UserOnly", "path", r.URL.Path, "error", err.Error())
         return "", ""
     }
 
@@ -245,25 +352,41 @@ func (s *Server) login(ctx context.Context, username string, password string) bo
     return true
 }
 
-func (s* Server) refreshAccessToken(ctx context.Context, refreshExpire int64, refreshTokenString, refreshValue, username string, w http.ResponseWriter) {
-    accessToken := webtoken.NewToken("accessToken", username, "notsecure", time.Now().Add(time.Hour * 1))
+// refreshAccessToken issues the session a new access token and returns it.
+// The session is found by its refresh token alone, so requests that arrive
+// together with the same expired access token each get a working one instead
+// of all but the first being turned away.
+func (s* Server) refreshAccessToken(ctx context.Context, refreshExpire int64, refreshTokenString, refreshValue, username string, w http.ResponseWriter) (string, error) {
+    key, err := s.signingKey()
+    if err != nil {
+        return "", fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    accessToken := webtoken.NewToken("accessToken", username, key, time.Now().Add(time.Hour * 1))
     accessToken.Create("nowplaying")
     cookieValue := webtoken.CookieAuthValue{ AccessToken: accessToken.Value(), RefreshToken: refreshTokenString }
-    cookie := webtoken.NewAuthCookie("nowplaying", "/", cookieValue, int(refreshExpire))
+    cookie := webtoken.NewAuthCookie("nowplaying", "/", cookieValue, int(refreshExpire - time.Now().Unix()))
 
-    s.authCfg.database.SaveUserSession(ctx, database.SaveUserSessionParams{
+    updated, err := s.authCfg.database.RefreshUserSession(ctx, database.RefreshUserSessionParams{
         Accesstoken: accessToken.Value(),
         Refreshtoken: refreshValue,
     })
 
+    if err != nil {
+        s.log.Error("Refreshing Session", "username", username, "error", err)
+    } else if updated == 0 {
+        s.log.Info("Refreshing Ended Session", "username", username)
+    }
+
     http.SetCookie(w, &cookie)
     s.log.Info("Refresh User Tokens", "username", username)
+    return accessToken.Value(), nil
 }
 
-func (s *Server) isAuthenticated(ctx context.Context, ats, rts string) (bool, string, func(http.ResponseWriter) context.Context, context.Context) {
+func (s *Server) isAuthenticated(ctx context.Context, ats, rts string) (bool, string, func(http.ResponseWriter) (context.Context, error), context.Context) {
     accessTokenExpired := true
     refreshTokenExpired := true
-    accessToken, err := webtoken.GetParsedJWT(ats, "notsecure")
+    accessToken, err := webtoken.GetParsedJWT(ats, s.authCfg.keys)
     if err != nil {
         fmt.Println()
 
@@ -275,7 +398,7 @@ func (s *Server) isAuthenticated(ctx context.Context, ats, rts string) (bool, st
         accessTokenExpired = false
     }
 
-    refreshToken, err := webtoken.GetParsedJWT(rts, "notsecure")
+    refreshToken, err := webtoken.GetParsedJWT(rts, s.authCfg.keys)
     if err != nil {
         if !strings.Contains(err.Error(), jwt.ErrTokenExpired.Error()) {
             s.log.Error("Invalid RefreshToken", "refreshToken", rts, "method", "isAuthenticated", "error", err.Error())
@@ -300,17 +423,11 @@ func (s *Server) isAuthenticated(ctx context.Context, ats, rts string) (bool, st
 
     if refreshTokenExpired {
         s.log.Error("Expired RefreshToken", "refreshToken", rts, "method", "isAuthenticated")
-        s.authCfg.database.InvalidateUserSession(ctx, database.InvalidateUserSessionParams{
-            Accesstoken: ats,
-            Refreshtoken: rf.Value,
-        })
+        s.authCfg.database.EndUserSession(ctx, rf.Value)
         return false, "", nil, nil
     }
 
-    _, err = s.authCfg.database.GetUserSession(ctx, database.GetUserSessionParams{
-        Accesstoken: ats,
-        Refreshtoken: rf.Value,
-    })
+    session, err := s.authCfg.database.GetUserSession(ctx, rf.Value)
     if err != nil {
         s.log.Error("Retreiving User Session", "method", "isAuthenticated", "error", err.Error())
         return false, "", nil, nil
@@ -329,26 +446,33 @@ func (s *Server) isAuthenticated(ctx context.Context, ats, rts string) (bool, st
         return false, "", nil, nil
     }
 
+    if username.Value != session.Username {
+        s.log.Error("Mismatched Session", "accessToken", us, "method", "isAuthenticated")
+        return false, "", nil, nil
+    }
+
     if accessTokenExpired {
         s.log.Error("Expired AccessToken", "accessToken", ats, "method", "isAuthenticated")
-        s.authCfg.database.InvalidateUserSession(ctx, database.InvalidateUserSessionParams{
-            Accesstoken: ats,
-            Refreshtoken: rf.Value,
-        })
 
         expiresAt, _ := refreshToken.Claims.GetExpirationTime()
 
-        return false, username.Value, func(w http.ResponseWriter) context.Context {
-            s.refreshAccessToken(ctx, expiresAt.Unix(), rts, rf.Value, username.Value, w)
-            ctx = context.WithValue(ctx, "accesstoken", ats)
+        return false, username.Value, func(w http.ResponseWriter) (context.Context, error) {
+            accessToken, err := s.refreshAccessToken(ctx, expiresAt.Unix(), rts, rf.Value, username.Value, w)
+            if err != nil {
+                return nil, err
+            }
+
+            ctx = context.WithValue(ctx, "accesstoken", accessToken)
             ctx = context.WithValue(ctx, "refreshtoken", rf.Value)
+            ctx = context.WithValue(ctx, "sessionid", session.ID)
 
-            return ctx
+            return ctx, nil
         }, nil
     }
 
     ctx = context.WithValue(ctx, "accesstoken", ats)
     ctx = context.WithValue(ctx, "refreshtoken", rf.Value)
+    ctx = context.WithValue(ctx, "sessionid", session.ID)
 
     return true, username.Value, nil, ctx 
 }
@@ -357,6 +481,7 @@ func (s *Server) Register(w http.ResponseWriter, r *http.Request) error {
     type RegisterBody struct {
         Username string `json:"username"`
         Password string `json:"password"`
+        Email string `json:"email"`
     }
 
     body, err := decode[RegisterBody](r)
@@ -364,6 +489,11 @@ func (s *Server) Register(w http.ResponseWriter, r *http.Request) error {
         return err
     }
 
+    body.Email = strings.TrimSpace(body.Email)
+    if body.Email != "" && !validEmail(body.Email) {
+        return fmt.Errorf(BAD_REQUEST_ERROR)
+    }
+
     existingUser, err := s.authCfg.database.GetUser(r.Context(), body.Username)
     if err != nil && err != sql.ErrNoRows {
         s.log.Error("sql err", "err", err)
@@ -390,9 +520,23 @@ func (s *Server) Register(w http.ResponseWriter, r *http.Request) error {
         return fmt.Errorf(INTERNAL_ERROR)
     }
 
-    s.setTokens(w, r, body.Username)
+    if body.Email != "" {
+        user, err := s.authCfg.database.GetUser(r.Context(), body.Username)
+        if err == nil {
+            err = s.verifyEmail(r, user.ID, user.Username, body.Email)
+        }
+
+        if err != nil {
+            s.log.Error("Setting Email", "username", body.Username, "err", err)
+        }
+    }
+
+    if err := s.setTokens(w, r, body.Username); err != nil {
+        return err
+    }
+
     encode(w, http.StatusOK, SuccessResp{ Success: true })
-    s.log.Info("Register Body", "body", body)
+    s.log.Info("Register", "username", body.Username)
     return nil
 }
 
@@ -411,7 +555,10 @@ func (s *Server) Login(w http.ResponseWriter, r *http.Request) error {
         return fmt.Errorf(AUTH_ERROR)
     }
 
-    s.setTokens(w, r, body.Username)
+    if err := s.setTokens(w, r, body.Username); err != nil {
+        return err
+    }
+
     encode(w, http.StatusOK, SuccessResp{ Success: true })
     s.log.Info("Login", "body", body)
     return nil
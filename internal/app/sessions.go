@@ -0,0 +1,175 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cg219/nowplaying/internal/database"
+	"github.com/cg219/nowplaying/pkg/webtoken"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+    sessionLifetime = time.Hour * 24 * 30
+    // last_seen_at is only written when it is at least this stale, so a busy
+    // session doesn't turn every request into a write.
+    sessionTouchInterval = time.Minute * 5
+    sessionPurgeInterval = time.Hour
+    sessionUserAgentLength = 256
+)
+
+type UserSession struct {
+    Id int64 `json:"id"`
+    Ip string `json:"ip"`
+    UserAgent string `json:"userAgent"`
+    CreatedAt int64 `json:"createdAt"`
+    LastSeenAt int64 `json:"lastSeenAt"`
+    ExpiresAt int64 `json:"expiresAt"`
+    Current bool `json:"current"`
+}
+
+func sessionUsername(accessToken string) (string, bool) {
+    token, _, err := jwt.NewParser().ParseUnverified(accessToken, jwt.MapClaims{})
+    if err != nil {
+        return "", false
+    }
+
+    sub, err := token.Claims.GetSubject()
+    if err != nil {
+        return "", false
+    }
+
+    var subject webtoken.Subject
+    if err := json.Unmarshal([]byte(sub), &subject); err != nil || subject.Value == "" {
+        return "", false
+    }
+
+    return subject.Value, true
+}
+
+func sessionUserAgent(r *http.Request) string {
+    ua := r.UserAgent()
+    if len(ua) > sessionUserAgentLength {
+        return ua[:sessionUserAgentLength]
+    }
+
+    return ua
+}
+
+// touchSession records where and when a session was last used.
+func (s *Server) touchSession(r *http.Request, id int64) {
+    now := time.Now()
+    err := s.authCfg.database.TouchUserSession(r.Context(), database.TouchUserSessionParams{
+        Now: now.UnixMilli(),
+        Ip: s.clientIP(r),
+        UserAgent: sessionUserAgent(r),
+        ID: id,
+        Since: now.Add(-sessionTouchInterval).UnixMilli(),
+    })
+
+    if err != nil {
+        s.log.Error("Touching Session", "id", id, "error", err)
+    }
+}
+
+// purgeSessions deletes sessions whose refresh token has expired. They can't
+// be used anymore, they only clutter the list in Settings.
+func (cfg *AppCfg) purgeSessions(ctx context.Context) {
+    ticker := time.NewTicker(sessionPurgeInterval)
+    defer ticker.Stop()
+
+    for {
+        purged, err := cfg.database.DeleteExpiredSessions(ctx, time.Now().UnixMilli())
+        if err != nil {
+            log.Printf("Sessions: purging expired: %s\n", err)
+        } else if purged > 0 {
+            log.Printf("Sessions: purged %d expired\n", purged)
+        }
+
+        select {
+        case <- ctx.Done():
+            log.Println("Exiting Session Purger")
+            return
+        case <- ticker.C:
+        }
+    }
+}
+
+func (s *Server) GetSessions(w http.ResponseWriter, r *http.Request) error {
+    user, err := s.currentUser(r)
+    if err != nil {
+        return err
+    }
+
+    rows, err := s.authCfg.database.GetUserSessions(r.Context(), user.ID)
+    if err != nil {
+        s.log.Error("Getting Sessions", "username", user.Username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    current, _ := r.Context().Value("refreshtoken").(string)
+    sessions := []UserSession{}
+    for _, row := range rows {
+        sessions = append(sessions, UserSession{
+            Id: row.ID,
+            Ip: row.Ip,
+            UserAgent: row.UserAgent,
+            CreatedAt: row.CreatedAt,
+            LastSeenAt: row.LastSeenAt,
+            ExpiresAt: row.ExpiresAt,
+            Current: row.Refreshtoken == current,
+        })
+    }
+
+    encode(w, http.StatusOK, sessions)
+    return nil
+}
+
+func (s *Server) RevokeSession(w http.ResponseWriter, r *http.Request) error {
+    user, err := s.currentUser(r)
+    if err != nil {
+        return err
+    }
+
+    id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+    if err != nil {
+        return fmt.Errorf(BAD_REQUEST_ERROR)
+    }
+
+    deleted, err := s.authCfg.database.RevokeUserSession(r.Context(), database.RevokeUserSessionParams{ ID: id, Uid: user.ID })
+    if err != nil {
+        s.log.Error("Revoking Session", "username", user.Username, "id", id, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    if deleted == 0 {
+        return fmt.Errorf(NOT_FOUND_ERROR)
+    }
+
+    encode(w, http.StatusOK, SuccessResp{ Success: true })
+    return nil
+}
+
+// RevokeAllSessions logs the user out everywhere, including here.
+func (s *Server) RevokeAllSessions(w http.ResponseWriter, r *http.Request) error {
+    user, err := s.currentUser(r)
+    if err != nil {
+        return err
+    }
+
+    if err := s.authCfg.database.DeleteUserSessions(r.Context(), user.ID); err != nil {
+        s.log.Error("Revoking Sessions", "username", user.Username, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    cookie := webtoken.NewAuthCookie("nowplaying", "/", webtoken.CookieAuthValue{}, 0)
+    http.SetCookie(w, &cookie)
+
+    encode(w, http.StatusOK, SuccessResp{ Success: true })
+    return nil
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cg219/nowplaying/pkg/webtoken"
+)
+
+func TestSessionUsername(t *testing.T) {
+    key, err := webtoken.NewKey(time.Now())
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    // Sessions from before 00029 are often long expired; the username is read
+    // regardless.
+    token := webtoken.NewToken("accessToken", "tester", key, time.Now().Add(-time.Hour))
+    token.Create("nowplaying")
+
+    if username, ok := sessionUsername(token.Value()); !ok || username != "tester" {
+        t.Errorf("got %q, %t", username, ok)
+    }
+
+    if _, ok := sessionUsername("not a token"); ok {
+        t.Errorf("expected garbage to be rejected")
+    }
+}
+
+func TestRefreshSession(t *testing.T) {
+    _, db := newTestDB(t)
+
+    key, err := webtoken.NewKey(time.Now())
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    s := &Server{
+        authCfg: &AppCfg{ database: db, keys: webtoken.NewKeySet(key) },
+        log: slog.New(slog.NewTextHandler(io.Discard, nil)),
+    }
+
+    w := httptest.NewRecorder()
+    s.setTokens(w, httptest.NewRequest("POST", "/auth/login", nil), "tester")
+
+    cookie := w.Result().Cookies()[0]
+    if cookie.MaxAge != int(sessionLifetime.Seconds()) {
+        t.Errorf("cookie max age: got %d", cookie.MaxAge)
+    }
+
+    raw, _ := base64.StdEncoding.DecodeString(cookie.Value)
+    var tokens webtoken.CookieAuthValue
+    if err := json.Unmarshal(raw, &tokens); err != nil {
+        t.Fatal(err)
+    }
+
+    expired := webtoken.NewToken("accessToken", "tester", key, time.Now().Add(-time.Minute))
+    expired.Create("nowplaying")
+
+    // Two requests that went out before either saw a new access token.
+    for i := 0; i < 2; i++ {
+        ok, username, refresh, _ := s.isAuthenticated(context.Background(), expired.Value(), tokens.RefreshToken)
+        if ok || username != "tester" || refresh == nil {
+            t.Fatalf("request %d: got %t, %q, refresh %t", i, ok, username, refresh != nil)
+        }
+
+        w := httptest.NewRecorder()
+        if _, err := refresh(w); err != nil {
+            t.Fatalf("request %d: %s", i, err)
+        }
+
+        if age := w.Result().Cookies()[0].MaxAge; age < int(sessionLifetime.Seconds()) - 60 || age > int(sessionLifetime.Seconds()) {
+            t.Errorf("request %d: refreshed cookie max age %d", i, age)
+        }
+    }
+
+    other := webtoken.NewToken("accessToken", "other", key, time.Now().Add(time.Hour))
+    other.Create("nowplaying")
+
+    if ok, _, refresh, _ := s.isAuthenticated(context.Background(), other.Value(), tokens.RefreshToken); ok || refresh != nil {
+        t.Errorf("accepted another user's access token with this session")
+    }
+}
+
+func TestSetTokensWithoutKey(t *testing.T) {
+    _, db := newTestDB(t)
+
+    s := &Server{
+        authCfg: &AppCfg{ database: db, keys: webtoken.NewKeySet() },
+        log: slog.New(slog.NewTextHandler(io.Discard, nil)),
+    }
+
+    w := httptest.NewRecorder()
+    if err := s.setTokens(w, httptest.NewRequest("POST", "/auth/login", nil), "tester"); err == nil || err.Error() != INTERNAL_ERROR {
+        t.Errorf("got %v", err)
+    }
+
+    if cookies := w.Result().Cookies(); len(cookies) != 0 {
+        t.Errorf("signed a cookie without a key: %v", cookies)
+    }
+}
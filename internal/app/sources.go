@@ -0,0 +1,56 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+type MusicSource struct {
+    Type string
+    Name string
+    New func(cfg *AppCfg, username string) Session
+    FromEncoded func(cfg *AppCfg, id int64, data []byte) (Session, error)
+}
+
+var musicSources = struct {
+    sync.RWMutex
+    sources map[string]MusicSource
+}{ sources: make(map[string]MusicSource) }
+
+// RegisterMusicSource makes a source type available to the listening loop and
+// the settings page. Sources register themselves from init in their own file.
+func RegisterMusicSource(src MusicSource) {
+    musicSources.Lock()
+    defer musicSources.Unlock()
+
+    if _, exists := musicSources.sources[src.Type]; exists {
+        panic(fmt.Sprintf("music source already registered: %s", src.Type))
+    }
+
+    musicSources.sources[src.Type] = src
+}
+
+func GetMusicSource(sourceType string) (MusicSource, bool) {
+    musicSources.RLock()
+    defer musicSources.RUnlock()
+
+    src, ok := musicSources.sources[sourceType]
+    return src, ok
+}
+
+func MusicSources() []MusicSource {
+    musicSources.RLock()
+    defer musicSources.RUnlock()
+
+    list := make([]MusicSource, 0, len(musicSources.sources))
+    for _, src := range musicSources.sources {
+        list = append(list, src)
+    }
+
+    sort.Slice(list, func(i, j int) bool {
+        return list[i].Type < list[j].Type
+    })
+
+    return list
+}
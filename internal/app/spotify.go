@@ -32,6 +32,8 @@ type Spotify struct {
     Id int
 }
 
+const SPOTIFY_SOURCE = "spotify-local"
+
 type SpotifyConfig struct {
     Id string
     Secret string
@@ -48,10 +50,15 @@ type SpotifyTokenResp struct {
 type SpotifyPlayingResp struct {
     Timestamp json.Number `json:"timestamp"`
     Progress json.Number `json:"progress_ms"`
+    IsPlaying bool `json:"is_playing"`
     Item struct {
         Album struct {
             Name string `json:"name"`
             Artist []struct{ Name string `json:"name"`} `json:"artists"`
+            Images []struct{
+                Url string `json:"url"`
+                Width int `json:"width"`
+            } `json:"images"`
         } `json:"album"`
         Artist []struct{ Name string `json:"name"`} `json:"artists"`
         Song string `json:"name"`
@@ -70,15 +77,18 @@ type SpotifyPlayingResp struct {
 
 type SpotifySong struct {
     Artist string
+    Artists []string
     Name string
     Album struct {
         Name string
         Artist string
+        Image string
     }
     Progress int
     Duration int
     Timestamp int
     TrackNumber int
+    Playing bool
 }
 
 type SpotifyPlayingErrorResp struct {
@@ -94,9 +104,23 @@ type SpotifyEncoded struct {
     Retrying bool `json:"r"`
 }
 
-type SpotifyListenValue struct {
-    Song *SpotifySong
-    Username string
+func init() {
+    RegisterMusicSource(MusicSource{
+        Type: "spotify",
+        Name: "Spotify",
+        New: func(cfg *AppCfg, username string) Session {
+            return NewSpotify(username, SpotifyConfig(cfg.config.Spotify), cfg.database)
+        },
+        FromEncoded: func(cfg *AppCfg, id int64, data []byte) (Session, error) {
+            s := NewSpotifyFromEncoded(data, SpotifyConfig(cfg.config.Spotify), cfg.database)
+            if s.Username == "" {
+                return nil, fmt.Errorf("invalid spotify session: %d", id)
+            }
+
+            s.Id = int(id)
+            return s, nil
+        },
+    })
 }
 
 func NewSpotify(u string, c SpotifyConfig, db *database.Queries) *Spotify {
@@ -135,19 +159,35 @@ func NewSpotifySongFromResp(resp SpotifyPlayingResp) *SpotifySong {
     progress, _ := resp.Progress.Int64()
     duration, _ := resp.Item.Duration.Int64()
     trackNumber, _ := resp.Item.TrackNumber.Int64()
-    timestamp, _ := resp.Timestamp.Int64()
+
+    artists := []string{}
+    for _, artist := range resp.Item.Artist {
+        artists = append(artists, artist.Name)
+    }
+
+    // Images come largest first; keep the smallest one that still makes a
+    // decent thumbnail.
+    var image string
+    for _, img := range resp.Item.Album.Images {
+        if image == "" || img.Width >= 250 {
+            image = img.Url
+        }
+    }
 
     return &SpotifySong{
         Artist: resp.Item.Artist[0].Name,
+        Artists: artists,
         Name: resp.Item.Song,
-        Album: struct{Name string; Artist string}{
+        Album: struct{Name string; Artist string; Image string}{
             Name: resp.Item.Album.Name,
             Artist: albumArtist,
+            Image: image,
         },
         Progress: int(progress),
         Duration: int(duration),
-        Timestamp: int(time.Unix(timestamp, 0).Unix()),
+        Timestamp: int(time.Now().Add(-time.Duration(progress) * time.Millisecond).UnixMilli()),
         TrackNumber: int(trackNumber),
+        Playing: resp.IsPlaying,
     }
 }
 
@@ -176,28 +216,99 @@ func (s *Spotify) Decode(encoded []byte) error {
     return nil
 }
 
-func (s *Spotify) Listen(ctx context.Context, out *chan any, done chan bool) {
+// Listen polls the player and follows each track with a PlaySession. Every
+// poll updates now playing; a track is only scrobbled once its play is over.
+func (s *Spotify) Listen(ctx context.Context, out chan<- ScrobblePack) error {
     timer := time.NewTicker(s.Duration)
+    defer timer.Stop()
+
+    var session PlaySession
+    var seeded string
+    var playing bool
 
     for {
         select {
-        case <- done:
-            close(done)
-            return
+        case <- ctx.Done():
+            return nil
         case <- timer.C:
             song, err := s.CheckCurrentTrack(ctx)
 
             if err != nil {
-                log.Printf("Oops: %s\n", err)
-                done <- false
-                return
+                if sc, ok := session.Finish(); ok {
+                    s.send(ctx, out, ScrobblePack{ Scrobble: sc, Username: s.Username, Import: true })
+                }
+
+                return err
+            }
+
+            if song != nil && song.Album.Image != "" && song.Album.Image != seeded {
+                seeded = song.Album.Image
+                s.seedArtwork(ctx, song)
+            }
+
+            var state *PlayerState
+            if song != nil {
+                state = &PlayerState{ Track: song.Scrobble(), Progress: song.Progress, Playing: song.Playing }
+            }
+
+            if sc, ok := session.Observe(state, time.Now()); ok {
+                if !s.send(ctx, out, ScrobblePack{ Scrobble: sc, Username: s.Username, Import: true }) {
+                    return nil
+                }
             }
 
-            if out != nil && song != nil {
-                *out <- SpotifyListenValue{ Song: song, Username: s.Username }
+            if song != nil && song.Playing {
+                if !s.send(ctx, out, ScrobblePack{ Scrobble: song.Scrobble(), Username: s.Username, NowPlaying: true }) {
+                    return nil
+                }
+            } else if playing {
+                stopped := ScrobblePack{ Scrobble: Scrobble{ Source: SPOTIFY_SOURCE }, Username: s.Username, NowPlaying: true, Stopped: true }
+                if !s.send(ctx, out, stopped) {
+                    return nil
+                }
             }
+
+            playing = song != nil && song.Playing
+        }
+    }
+}
+
+// seedArtwork hands the album art from the player to the artwork cache under
+// the same names the scrobble will be saved with, so it runs the user's
+// rewrite rules just like processScrobble.
+func (s *Spotify) seedArtwork(ctx context.Context, song *SpotifySong) {
+    user, err := s.db.GetUser(ctx, s.Username)
+    if err != nil {
+        log.Printf("Spotify: seeding artwork for %s: %s\n", s.Username, err)
+        return
+    }
+
+    normalizer, err := loadNormalizer(ctx, s.db, user.ID)
+    if err != nil {
+        log.Printf("Spotify: seeding artwork for %s: %s\n", s.Username, err)
+        return
+    }
+
+    sc := normalizer.Normalize(song.Scrobble())
+
+    if sc.AlbumName != "" {
+        if err := seedArtwork(ctx, s.db, ARTWORK_ALBUM, sc.ArtistName, sc.AlbumName, "spotify", song.Album.Image); err != nil {
+            log.Printf("Spotify: seeding artwork for %s: %s\n", sc.AlbumName, err)
         }
     }
+
+    if err := seedArtwork(ctx, s.db, ARTWORK_TRACK, sc.ArtistName, sc.TrackName, "spotify", song.Album.Image); err != nil {
+        log.Printf("Spotify: seeding artwork for %s: %s\n", sc.TrackName, err)
+    }
+}
+
+func (s *Spotify) send(ctx context.Context, out chan<- ScrobblePack, pack ScrobblePack) bool {
+    select {
+    case out <- pack:
+        return true
+    case <- ctx.Done():
+        return false
+    }
 }
 
 func GetRandomState(username string) string {
@@ -370,7 +481,7 @@ func (s *Spotify) CheckCurrentTrack(ctx context.Context) (*SpotifySong, error) {
             return nil, err
         }
 
-        if data.Actions.Disallows.Paused {
+        if len(data.Item.Artist) == 0 {
             return nil, nil
         }
 
@@ -403,6 +514,21 @@ func (s *Spotify) CheckCurrentTrack(ctx context.Context) (*SpotifySong, error) {
     return nil, nil
 }
 
+func (s *SpotifySong) Scrobble() Scrobble {
+    return Scrobble{
+        ArtistName: s.Artist,
+        Artists: s.Artists,
+        TrackName: s.Name,
+        AlbumName: s.Album.Name,
+        AlbumArtist: s.Album.Artist,
+        Timestamp: s.Timestamp,
+        Duration: s.Duration,
+        TrackNumber: fmt.Sprintf("%d", s.TrackNumber),
+        Source: SPOTIFY_SOURCE,
+        Progress: s.Progress,
+    }
+}
+
 func (s *SpotifySong) String() string {
     return fmt.Sprintf("%s - %s\n%s (by %s)\nTrack: %d", s.Artist, s.Name, s.Album.Name, s.Album.Artist, s.Timestamp)
 }
@@ -0,0 +1,134 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/cg219/nowplaying/internal/database"
+	"github.com/cg219/nowplaying/pkg/spotup"
+)
+
+const (
+    SPOTIFY_IMPORT_SOURCE = "spotify-import"
+    maxSpotifyUploadSize = 512 << 20
+)
+
+type SpotifyImportResult struct {
+    Entries int `json:"entries"`
+    Imported int `json:"imported"`
+    Skipped int `json:"skipped"`
+    Duplicates int `json:"duplicates"`
+}
+
+// ImportSpotifyHistory stores an extended streaming history export for a user.
+// Every song lands in history_spotify; plays that pass the scrobble threshold
+// and are not already scrobbled are copied into scrobbles. The whole export is
+// written in one transaction.
+func ImportSpotifyHistory(ctx context.Context, conn *sql.DB, uid int64, entries []spotup.Entry) (SpotifyImportResult, error) {
+    result := SpotifyImportResult{ Entries: len(entries) }
+
+    tx, err := conn.BeginTx(ctx, nil)
+    if err != nil {
+        return result, err
+    }
+
+    defer tx.Rollback()
+
+    db := database.New(conn).WithTx(tx)
+
+    normalizer, err := loadNormalizer(ctx, db, uid)
+    if err != nil {
+        return result, err
+    }
+
+    rule, err := loadScrobbleRule(ctx, db, uid, SPOTIFY_IMPORT_SOURCE)
+    if err != nil {
+        return result, err
+    }
+
+    for _, e := range entries {
+        if !e.IsMusic() {
+            result.Skipped++
+            continue
+        }
+
+        ended, err := e.Ended()
+        if err != nil {
+            result.Skipped++
+            continue
+        }
+
+        added, err := db.AddToHistory(ctx, database.AddToHistoryParams{
+            ArtistName: e.Artist,
+            TrackName: e.Track,
+            AlbumName: sql.NullString{ String: e.Album, Valid: e.Album != "" },
+            Timestamp: ended.UnixMilli(),
+            MsPlayed: int64(e.MsPlayed),
+            Platform: sql.NullString{ String: e.Platform, Valid: e.Platform != "" },
+            TrackUri: sql.NullString{ String: e.TrackURI, Valid: e.TrackURI != "" },
+            ReasonEnd: sql.NullString{ String: e.ReasonEnd, Valid: e.ReasonEnd != "" },
+            Uid: sql.NullInt64{ Int64: uid, Valid: true },
+        })
+
+        if err != nil {
+            return result, err
+        }
+
+        if added == 0 {
+            result.Duplicates++
+            continue
+        }
+
+        if !spotifyHistoryPlayed(rule, e) {
+            result.Skipped++
+            continue
+        }
+
+        duration := 0
+        if e.Finished() {
+            duration = e.MsPlayed
+        }
+
+        // Ended already parsed, so this can't fail.
+        started, _ := e.Started()
+
+        sc := normalizer.Normalize(Scrobble{
+            ArtistName: e.Artist,
+            TrackName: e.Track,
+            AlbumName: e.Album,
+            Duration: duration,
+            Timestamp: int(started.UnixMilli()),
+            Source: SPOTIFY_IMPORT_SOURCE,
+            Uid: int(uid),
+        })
+
+        found, err := scrobbleExists(ctx, db, uid, sc.ArtistName, sc.TrackName, int64(sc.Timestamp))
+        if err != nil {
+            return result, err
+        }
+
+        if found {
+            result.Duplicates++
+            continue
+        }
+
+        if err := insertScrobble(ctx, db, sc); err != nil {
+            return result, err
+        }
+
+        result.Imported++
+    }
+
+    return result, tx.Commit()
+}
+
+// spotifyHistoryPlayed applies the user's scrobble rule to an export entry.
+// The export has no track length, so a play that ran to the end is measured
+// against itself and anything cut short has to reach the rule's seconds.
+func spotifyHistoryPlayed(rule ScrobbleRule, e spotup.Entry) bool {
+    if e.Finished() {
+        return rule.Check(Scrobble{ Duration: e.MsPlayed, Progress: e.MsPlayed }) == ""
+    }
+
+    return e.MsPlayed >= rule.MinSeconds * 1000
+}
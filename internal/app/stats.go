@@ -0,0 +1,204 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cg219/nowplaying/internal/database"
+)
+
+const (
+    STATS_ARTIST = "artist"
+    STATS_ALBUM = "album"
+    STATS_TRACK = "track"
+    SORT_PLAYS = "plays"
+    SORT_LISTENED = "listened"
+    defaultStatsLimit = 10
+    maxStatsLimit = 200
+)
+
+// StatsQuery asks for the top artists, albums or tracks in a period, ranked
+// by play count or by listening time. An empty Source counts scrobbles from
+// every source.
+type StatsQuery struct {
+    Entity string
+    Period Period
+    Source string
+    Sort string
+    Limit int
+    Offset int
+}
+
+type StatsItem struct {
+    Rank int `json:"rank"`
+    Artist string `json:"artist"`
+    Name string `json:"name,omitempty"`
+    Plays int64 `json:"plays"`
+    Listened int64 `json:"listened"`
+    Image string `json:"image"`
+}
+
+// Stats holds one page of ranked results along with the play count and
+// listening time (in ms) of every scrobble in the period.
+type Stats struct {
+    Entity string `json:"entity"`
+    From int64 `json:"from"`
+    To int64 `json:"to"`
+    Source string `json:"source,omitempty"`
+    Plays int64 `json:"plays"`
+    Listened int64 `json:"listened"`
+    Results []StatsItem `json:"results"`
+}
+
+func (q StatsQuery) Valid() bool {
+    if q.Entity != STATS_ARTIST && q.Entity != STATS_ALBUM && q.Entity != STATS_TRACK {
+        return false
+    }
+
+    if q.Sort != "" && q.Sort != SORT_PLAYS && q.Sort != SORT_LISTENED {
+        return false
+    }
+
+    return q.Limit > 0 && q.Limit <= maxStatsLimit && q.Offset >= 0
+}
+
+// topStats runs a StatsQuery for a user. Artists are counted through their
+// credits, so a featured artist gets a play for every track they appear on.
+func topStats(ctx context.Context, db *database.Queries, uid int64, q StatsQuery) (Stats, error) {
+    since := q.Period.From.UnixMilli()
+    until := q.Period.To.UnixMilli()
+
+    stats := Stats{ Entity: q.Entity, From: since, To: until, Source: q.Source, Results: []StatsItem{} }
+
+    totals, err := db.GetScrobbleTotals(ctx, database.GetScrobbleTotalsParams{ Uid: uid, Since: since, Until: until, Source: q.Source })
+    if err != nil {
+        return stats, err
+    }
+
+    stats.Plays = totals.Plays
+    stats.Listened = totals.Listened
+
+    switch q.Entity {
+    case STATS_ARTIST:
+        rows, err := db.GetTopArtists(ctx, database.GetTopArtistsParams{ Uid: uid, Since: since, Until: until, Source: q.Source, Sort: q.Sort, Limit: int64(q.Limit), Offset: int64(q.Offset) })
+        if err != nil {
+            return stats, err
+        }
+
+        for _, row := range rows {
+            stats.Results = append(stats.Results, StatsItem{ Artist: row.Artist, Plays: row.Plays, Listened: row.Listened })
+        }
+    case STATS_ALBUM:
+        rows, err := db.GetTopAlbums(ctx, database.GetTopAlbumsParams{ Uid: uid, Since: since, Until: until, Source: q.Source, Sort: q.Sort, Limit: int64(q.Limit), Offset: int64(q.Offset) })
+        if err != nil {
+            return stats, err
+        }
+
+        for _, row := range rows {
+            stats.Results = append(stats.Results, StatsItem{ Artist: row.ArtistName, Name: row.AlbumName.String, Plays: row.Plays, Listened: row.Listened })
+        }
+    case STATS_TRACK:
+        rows, err := db.GetTopTracks(ctx, database.GetTopTracksParams{ Uid: uid, Since: since, Until: until, Source: q.Source, Sort: q.Sort, Limit: int64(q.Limit), Offset: int64(q.Offset) })
+        if err != nil {
+            return stats, err
+        }
+
+        for _, row := range rows {
+            stats.Results = append(stats.Results, StatsItem{ Artist: row.ArtistName, Name: row.TrackName, Plays: row.Plays, Listened: row.Listened })
+        }
+    }
+
+    for i := range stats.Results {
+        stats.Results[i].Rank = q.Offset + i + 1
+    }
+
+    return stats, nil
+}
+
+// statsQueryFromRequest reads entity, period or from/to, sort, limit, offset
+// and source from the query string. Without a period or range it covers all
+// time.
+func statsQueryFromRequest(r *http.Request, now time.Time) (StatsQuery, error) {
+    params := r.URL.Query()
+    q := StatsQuery{
+        Entity: params.Get("entity"),
+        Source: params.Get("source"),
+        Sort: params.Get("sort"),
+        Limit: defaultStatsLimit,
+        Period: Period{ To: now },
+    }
+
+    if value := params.Get("limit"); value != "" {
+        limit, err := strconv.Atoi(value)
+        if err != nil {
+            return q, err
+        }
+
+        q.Limit = limit
+    }
+
+    if value := params.Get("offset"); value != "" {
+        offset, err := strconv.Atoi(value)
+        if err != nil {
+            return q, err
+        }
+
+        q.Offset = offset
+    }
+
+    var err error
+    if period := params.Get("period"); period != "" {
+        q.Period, err = ParsePeriod(period, now)
+    } else if params.Get("from") != "" || params.Get("to") != "" {
+        q.Period, err = ParseRange(params.Get("from"), params.Get("to"), now)
+    }
+
+    if err != nil {
+        return q, err
+    }
+
+    if !q.Valid() {
+        return q, fmt.Errorf("invalid stats query")
+    }
+
+    return q, nil
+}
+
+func (s *Server) GetStats(w http.ResponseWriter, r *http.Request) error {
+    user, err := s.currentUser(r)
+    if err != nil {
+        return err
+    }
+
+    q, err := statsQueryFromRequest(r, time.Now().In(userLocation(user.Timezone)))
+    if err != nil {
+        return fmt.Errorf(BAD_REQUEST_ERROR)
+    }
+
+    stats, err := topStats(r.Context(), s.authCfg.database, user.ID, q)
+    if err != nil {
+        s.log.Error("Getting Stats", "username", user.Username, "entity", q.Entity, "error", err)
+        return fmt.Errorf(INTERNAL_ERROR)
+    }
+
+    s.addStatsImages(r.Context(), &stats)
+
+    encode(w, http.StatusOK, stats)
+    return nil
+}
+
+func (s *Server) addStatsImages(ctx context.Context, stats *Stats) {
+    for i, item := range stats.Results {
+        switch stats.Entity {
+        case STATS_ARTIST:
+            stats.Results[i].Image = s.authCfg.artwork.Image(ctx, ARTWORK_ARTIST, item.Artist, "")
+        case STATS_ALBUM:
+            stats.Results[i].Image = s.authCfg.artwork.Image(ctx, ARTWORK_ALBUM, item.Artist, item.Name)
+        case STATS_TRACK:
+            stats.Results[i].Image = s.authCfg.artwork.Image(ctx, ARTWORK_TRACK, item.Artist, item.Name)
+        }
+    }
+}
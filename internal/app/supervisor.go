@@ -0,0 +1,170 @@
+package app
+
+import (
+	"context"
+	"encoding/base64"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+    minRestartBackoff = time.Second * 5
+    maxRestartBackoff = time.Minute * 5
+    healthyListenTime = time.Minute
+    resyncInterval = time.Minute * 5
+)
+
+type Supervisor struct {
+    cfg *AppCfg
+    workers map[int64]*sessionWorker
+    changes chan struct{}
+    mu sync.Mutex
+}
+
+type sessionWorker struct {
+    id int64
+    sourceType string
+    cancel context.CancelFunc
+}
+
+func NewSupervisor(cfg *AppCfg) *Supervisor {
+    return &Supervisor{
+        cfg: cfg,
+        workers: make(map[int64]*sessionWorker),
+        changes: make(chan struct{}, 1),
+    }
+}
+
+// Refresh asks the supervisor to re-read the active music sessions. Calls never
+// block; several refreshes before the next sync collapse into one.
+func (sv *Supervisor) Refresh() {
+    select {
+    case sv.changes <- struct{}{}:
+    default:
+    }
+}
+
+func (sv *Supervisor) Run(ctx context.Context) {
+    ticker := time.NewTicker(resyncInterval)
+    defer ticker.Stop()
+
+    sv.sync(ctx)
+
+    for {
+        select {
+        case <- ctx.Done():
+            sv.stopAll()
+            log.Println("Exiting Supervisor")
+            return
+        case <- sv.changes:
+            sv.sync(ctx)
+        case <- ticker.C:
+            sv.sync(ctx)
+        }
+    }
+}
+
+func (sv *Supervisor) sync(ctx context.Context) {
+    encodedSessions, err := sv.cfg.database.GetActiveMusicSessions(ctx)
+    if err != nil {
+        log.Printf("loading music sessions: %s", err)
+        return
+    }
+
+    sv.mu.Lock()
+    defer sv.mu.Unlock()
+
+    active := make(map[int64]bool)
+
+    for _, es := range encodedSessions {
+        active[es.ID] = true
+
+        if _, running := sv.workers[es.ID]; running {
+            continue
+        }
+
+        src, ok := GetMusicSource(es.Type)
+        if !ok {
+            log.Printf("unknown music source: %s", es.Type)
+            continue
+        }
+
+        d, err := base64.StdEncoding.DecodeString(es.Data)
+        if err != nil {
+            log.Printf("decoding %s session %d: %s", es.Type, es.ID, err)
+            continue
+        }
+
+        session, err := src.FromEncoded(sv.cfg, es.ID, d)
+        if err != nil {
+            log.Printf("loading %s session %d: %s", es.Type, es.ID, err)
+            continue
+        }
+
+        workerCtx, cancel := context.WithCancel(ctx)
+        sv.workers[es.ID] = &sessionWorker{ id: es.ID, sourceType: es.Type, cancel: cancel }
+
+        log.Printf("starting %s session %d", es.Type, es.ID)
+        go sv.supervise(workerCtx, es.ID, es.Type, session)
+    }
+
+    for id, worker := range sv.workers {
+        if !active[id] {
+            log.Printf("stopping %s session %d", worker.sourceType, id)
+            worker.cancel()
+            delete(sv.workers, id)
+        }
+    }
+}
+
+func (sv *Supervisor) stopAll() {
+    sv.mu.Lock()
+    defer sv.mu.Unlock()
+
+    for id, worker := range sv.workers {
+        worker.cancel()
+        delete(sv.workers, id)
+    }
+}
+
+// supervise keeps a single session listening until its context is cancelled,
+// restarting it with exponential backoff whenever auth or Listen fails.
+func (sv *Supervisor) supervise(ctx context.Context, id int64, sourceType string, session Session) {
+    backoff := minRestartBackoff
+
+    for {
+        started := time.Now()
+        err := session.AuthWithDB(ctx)
+        if err == nil {
+            err = session.Listen(ctx, sv.cfg.scrobbles)
+        }
+
+        if ctx.Err() != nil {
+            return
+        }
+
+        if time.Since(started) > healthyListenTime {
+            backoff = minRestartBackoff
+        }
+
+        log.Printf("%s session %d stopped: %v; restarting in %s", sourceType, id, err, backoff)
+
+        select {
+        case <- ctx.Done():
+            return
+        case <- time.After(backoff):
+        }
+
+        backoff = nextBackoff(backoff)
+    }
+}
+
+func nextBackoff(current time.Duration) time.Duration {
+    next := current * 2
+    if next > maxRestartBackoff {
+        return maxRestartBackoff
+    }
+
+    return next
+}
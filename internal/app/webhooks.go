@@ -0,0 +1,335 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+    ticksPerMillisecond = 10000
+    maxWebhookMemory = 10 << 20
+)
+
+// JellyfinWebhook is the body sent by the Jellyfin webhook plugin's generic
+// destination with "Send All Properties" enabled.
+type JellyfinWebhook struct {
+    NotificationType string `json:"NotificationType"`
+    ItemType string `json:"ItemType"`
+    Name string `json:"Name"`
+    Artist string `json:"Artist"`
+    Album string `json:"Album"`
+    AlbumArtist string `json:"AlbumArtist"`
+    IndexNumber int `json:"IndexNumber"`
+    RunTimeTicks int64 `json:"RunTimeTicks"`
+    PlaybackPositionTicks int64 `json:"PlaybackPositionTicks"`
+    IsPaused bool `json:"IsPaused"`
+    PlayedToCompletion bool `json:"PlayedToCompletion"`
+    ClientName string `json:"ClientName"`
+    TrackMbid string `json:"Provider_musicbrainztrack"`
+    RecordingMbid string `json:"Provider_musicbrainzrecording"`
+    AlbumMbid string `json:"Provider_musicbrainzalbum"`
+    ArtistMbid string `json:"Provider_musicbrainzartist"`
+    AlbumArtistMbid string `json:"Provider_musicbrainzalbumartist"`
+}
+
+// EmbyWebhook is the native Emby webhook notification.
+type EmbyWebhook struct {
+    Event string `json:"Event"`
+    Item struct {
+        Type string `json:"Type"`
+        Name string `json:"Name"`
+        Album string `json:"Album"`
+        AlbumArtist string `json:"AlbumArtist"`
+        Artists []string `json:"Artists"`
+        IndexNumber int `json:"IndexNumber"`
+        RunTimeTicks int64 `json:"RunTimeTicks"`
+        ProviderIds map[string]string `json:"ProviderIds"`
+    } `json:"Item"`
+    PlaybackInfo struct {
+        PositionTicks int64 `json:"PositionTicks"`
+        PlayedToCompletion bool `json:"PlayedToCompletion"`
+    } `json:"PlaybackInfo"`
+}
+
+// PlexWebhook is the "payload" part of the multipart form Plex posts.
+type PlexWebhook struct {
+    Event string `json:"event"`
+    Metadata struct {
+        Type string `json:"type"`
+        Title string `json:"title"`
+        ParentTitle string `json:"parentTitle"`
+        GrandparentTitle string `json:"grandparentTitle"`
+        OriginalTitle string `json:"originalTitle"`
+        Index int `json:"index"`
+        Duration int `json:"duration"`
+        ViewOffset int `json:"viewOffset"`
+        PlexGuid string `json:"guid"`
+        Guid []struct {
+            Id string `json:"id"`
+        } `json:"Guid"`
+    } `json:"Metadata"`
+}
+
+// playback is what every media server event boils down to before it becomes a
+// Scrobble. Duration and position are in milliseconds.
+type playback struct {
+    artist string
+    artists []string
+    track string
+    album string
+    albumArtist string
+    trackNumber int
+    duration int
+    position int
+    completed bool
+    stopped bool
+    mbid string
+    albumMbid string
+    artistMbid string
+    source string
+}
+
+func (p playback) Scrobble() Scrobble {
+    progress := p.position
+    if p.completed && p.duration > progress {
+        progress = p.duration
+    }
+
+    trackNumber := ""
+    if p.trackNumber > 0 {
+        trackNumber = strconv.Itoa(p.trackNumber)
+    }
+
+    albumArtist := p.albumArtist
+    if albumArtist == "" {
+        albumArtist = p.artist
+    }
+
+    return Scrobble{
+        ArtistName: p.artist,
+        Artists: p.artists,
+        TrackName: p.track,
+        AlbumName: p.album,
+        AlbumArtist: albumArtist,
+        TrackNumber: trackNumber,
+        Mbid: p.mbid,
+        AlbumMbid: p.albumMbid,
+        ArtistMbid: p.artistMbid,
+        Duration: p.duration,
+        Progress: progress,
+        Timestamp: int(time.Now().Add(-time.Duration(p.position) * time.Millisecond).UnixMilli()),
+        Source: p.source,
+    }
+}
+
+func (s *Server) JellyfinWebhook(w http.ResponseWriter, r *http.Request) error {
+    var hook JellyfinWebhook
+    if err := readWebhook(r, "", &hook); err != nil {
+        s.log.Error("Jellyfin Webhook", "error", err)
+        return fmt.Errorf(BAD_REQUEST_ERROR)
+    }
+
+    p, ok := hook.playback()
+    if !ok {
+        return s.ignoreWebhook(w)
+    }
+
+    return s.acceptWebhook(w, r, p)
+}
+
+func (s *Server) EmbyWebhook(w http.ResponseWriter, r *http.Request) error {
+    var hook EmbyWebhook
+    if err := readWebhook(r, "data", &hook); err != nil {
+        s.log.Error("Emby Webhook", "error", err)
+        return fmt.Errorf(BAD_REQUEST_ERROR)
+    }
+
+    p, ok := hook.playback()
+    if !ok {
+        return s.ignoreWebhook(w)
+    }
+
+    return s.acceptWebhook(w, r, p)
+}
+
+// PlexWebhook is the only webhook that takes its key from the apikey query
+// parameter, since Plex can't add headers to the requests it sends. See
+// PlexKeyOnly.
+func (s *Server) PlexWebhook(w http.ResponseWriter, r *http.Request) error {
+    var hook PlexWebhook
+    if err := readWebhook(r, "payload", &hook); err != nil {
+        s.log.Error("Plex Webhook", "error", err)
+        return fmt.Errorf(BAD_REQUEST_ERROR)
+    }
+
+    p, ok := hook.playback()
+    if !ok {
+        return s.ignoreWebhook(w)
+    }
+
+    return s.acceptWebhook(w, r, p)
+}
+
+// playback reports false for events that aren't about a music track playing.
+func (hook JellyfinWebhook) playback() (playback, bool) {
+    if hook.ItemType != "Audio" || hook.IsPaused {
+        return playback{}, false
+    }
+
+    switch hook.NotificationType {
+    case "PlaybackStart", "PlaybackProgress", "PlaybackStop":
+    default:
+        return playback{}, false
+    }
+
+    mbid := hook.RecordingMbid
+    if mbid == "" {
+        mbid = hook.TrackMbid
+    }
+
+    artistMbid := hook.ArtistMbid
+    if artistMbid == "" {
+        artistMbid = hook.AlbumArtistMbid
+    }
+
+    return playback{
+        artist: hook.Artist,
+        track: hook.Name,
+        album: hook.Album,
+        albumArtist: hook.AlbumArtist,
+        trackNumber: hook.IndexNumber,
+        duration: int(hook.RunTimeTicks / ticksPerMillisecond),
+        position: int(hook.PlaybackPositionTicks / ticksPerMillisecond),
+        completed: hook.PlayedToCompletion,
+        stopped: hook.NotificationType == "PlaybackStop",
+        mbid: mbid,
+        albumMbid: hook.AlbumMbid,
+        artistMbid: artistMbid,
+        source: "jellyfin",
+    }, true
+}
+
+func (hook EmbyWebhook) playback() (playback, bool) {
+    if hook.Item.Type != "Audio" {
+        return playback{}, false
+    }
+
+    switch hook.Event {
+    case "playback.start", "playback.unpause", "playback.stop":
+    default:
+        return playback{}, false
+    }
+
+    artist := hook.Item.AlbumArtist
+    if len(hook.Item.Artists) > 0 {
+        artist = hook.Item.Artists[0]
+    }
+
+    ids := hook.Item.ProviderIds
+    artistMbid := ids["MusicBrainzArtist"]
+    if artistMbid == "" {
+        artistMbid = ids["MusicBrainzAlbumArtist"]
+    }
+
+    return playback{
+        artist: artist,
+        artists: hook.Item.Artists,
+        track: hook.Item.Name,
+        album: hook.Item.Album,
+        albumArtist: hook.Item.AlbumArtist,
+        trackNumber: hook.Item.IndexNumber,
+        duration: int(hook.Item.RunTimeTicks / ticksPerMillisecond),
+        position: int(hook.PlaybackInfo.PositionTicks / ticksPerMillisecond),
+        completed: hook.PlaybackInfo.PlayedToCompletion,
+        stopped: hook.Event == "playback.stop",
+        mbid: ids["MusicBrainzTrack"],
+        albumMbid: ids["MusicBrainzAlbum"],
+        artistMbid: artistMbid,
+        source: "emby",
+    }, true
+}
+
+func (hook PlexWebhook) playback() (playback, bool) {
+    if hook.Metadata.Type != "track" {
+        return playback{}, false
+    }
+
+    switch hook.Event {
+    case "media.play", "media.resume", "media.scrobble", "media.stop":
+    default:
+        return playback{}, false
+    }
+
+    // grandparentTitle is the album artist; originalTitle is only set when the
+    // track artist differs from it.
+    artist := hook.Metadata.OriginalTitle
+    if artist == "" {
+        artist = hook.Metadata.GrandparentTitle
+    }
+
+    mbid := ""
+    for _, guid := range hook.Metadata.Guid {
+        if id, ok := strings.CutPrefix(guid.Id, "mbid://"); ok {
+            mbid = id
+        }
+    }
+
+    return playback{
+        artist: artist,
+        track: hook.Metadata.Title,
+        album: hook.Metadata.ParentTitle,
+        albumArtist: hook.Metadata.GrandparentTitle,
+        trackNumber: hook.Metadata.Index,
+        duration: hook.Metadata.Duration,
+        position: hook.Metadata.ViewOffset,
+        completed: hook.Event == "media.scrobble",
+        stopped: hook.Event == "media.stop",
+        mbid: mbid,
+        source: "plex",
+    }, true
+}
+
+func (s *Server) acceptWebhook(w http.ResponseWriter, r *http.Request, p playback) error {
+    if p.artist == "" || p.track == "" {
+        return s.ignoreWebhook(w)
+    }
+
+    username := r.Context().Value("username").(string)
+    s.authCfg.scrobbles <- ScrobblePack{ Scrobble: p.Scrobble(), Username: username, Stopped: p.stopped }
+
+    encode(w, http.StatusOK, SuccessResp{ Success: true })
+    return nil
+}
+
+func (s *Server) ignoreWebhook(w http.ResponseWriter) error {
+    encode(w, http.StatusOK, SuccessResp{ Success: false })
+    return nil
+}
+
+// readWebhook decodes a JSON webhook body. Servers that post multipart forms
+// put the JSON document in the named form field instead.
+func readWebhook(r *http.Request, field string, v any) error {
+    defer r.Body.Close()
+
+    mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+    if field != "" && mediaType == "multipart/form-data" {
+        if err := r.ParseMultipartForm(maxWebhookMemory); err != nil {
+            return err
+        }
+
+        return json.Unmarshal([]byte(r.FormValue(field)), v)
+    }
+
+    body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookMemory))
+    if err != nil {
+        return err
+    }
+
+    return json.Unmarshal(body, v)
+}
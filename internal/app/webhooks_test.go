@@ -0,0 +1,145 @@
+package app
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestJellyfinPlayback(t *testing.T) {
+    tests := []struct {
+        name string
+        body string
+        ok bool
+        want playback
+    }{
+        {
+            "progress",
+            `{ "NotificationType": "PlaybackProgress", "ItemType": "Audio", "Name": "Song", "Artist": "Artist", "Album": "Album", "AlbumArtist": "Various", "IndexNumber": 3, "RunTimeTicks": 2000000000, "PlaybackPositionTicks": 450000000, "Provider_musicbrainztrack": "track-1", "Provider_musicbrainzalbum": "album-1", "Provider_musicbrainzalbumartist": "artist-1" }`,
+            true,
+            playback{ artist: "Artist", track: "Song", album: "Album", albumArtist: "Various", trackNumber: 3, duration: 200000, position: 45000, mbid: "track-1", albumMbid: "album-1", artistMbid: "artist-1", source: "jellyfin" },
+        },
+        {
+            "stop prefers the recording mbid",
+            `{ "NotificationType": "PlaybackStop", "ItemType": "Audio", "Name": "Song", "Artist": "Artist", "RunTimeTicks": 2000000000, "PlaybackPositionTicks": 2000000000, "PlayedToCompletion": true, "Provider_musicbrainztrack": "track-1", "Provider_musicbrainzrecording": "recording-1" }`,
+            true,
+            playback{ artist: "Artist", track: "Song", duration: 200000, position: 200000, completed: true, stopped: true, mbid: "recording-1", source: "jellyfin" },
+        },
+        { "paused", `{ "NotificationType": "PlaybackProgress", "ItemType": "Audio", "IsPaused": true }`, false, playback{} },
+        { "video", `{ "NotificationType": "PlaybackStart", "ItemType": "Movie" }`, false, playback{} },
+        { "other event", `{ "NotificationType": "ItemAdded", "ItemType": "Audio" }`, false, playback{} },
+    }
+
+    for _, tt := range tests {
+        var hook JellyfinWebhook
+        if err := json.Unmarshal([]byte(tt.body), &hook); err != nil {
+            t.Fatalf("%s: %s", tt.name, err)
+        }
+
+        got, ok := hook.playback()
+        if ok != tt.ok {
+            t.Errorf("%s: got ok %v, want %v", tt.name, ok, tt.ok)
+            continue
+        }
+
+        if ok && !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestEmbyPlayback(t *testing.T) {
+    tests := []struct {
+        name string
+        body string
+        ok bool
+        want playback
+    }{
+        {
+            "start",
+            `{ "Event": "playback.start", "Item": { "Type": "Audio", "Name": "Song", "Album": "Album", "AlbumArtist": "Various", "Artists": ["Artist", "Guest"], "IndexNumber": 2, "RunTimeTicks": 1800000000, "ProviderIds": { "MusicBrainzTrack": "track-1", "MusicBrainzAlbum": "album-1", "MusicBrainzAlbumArtist": "artist-1" } }, "PlaybackInfo": { "PositionTicks": 0 } }`,
+            true,
+            playback{ artist: "Artist", artists: []string{ "Artist", "Guest" }, track: "Song", album: "Album", albumArtist: "Various", trackNumber: 2, duration: 180000, mbid: "track-1", albumMbid: "album-1", artistMbid: "artist-1", source: "emby" },
+        },
+        {
+            "stop falls back to the album artist",
+            `{ "Event": "playback.stop", "Item": { "Type": "Audio", "Name": "Song", "AlbumArtist": "Artist", "RunTimeTicks": 1800000000 }, "PlaybackInfo": { "PositionTicks": 1200000000, "PlayedToCompletion": false } }`,
+            true,
+            playback{ artist: "Artist", track: "Song", albumArtist: "Artist", duration: 180000, position: 120000, stopped: true, source: "emby" },
+        },
+        { "pause", `{ "Event": "playback.pause", "Item": { "Type": "Audio" } }`, false, playback{} },
+        { "episode", `{ "Event": "playback.start", "Item": { "Type": "Episode" } }`, false, playback{} },
+    }
+
+    for _, tt := range tests {
+        var hook EmbyWebhook
+        if err := json.Unmarshal([]byte(tt.body), &hook); err != nil {
+            t.Fatalf("%s: %s", tt.name, err)
+        }
+
+        got, ok := hook.playback()
+        if ok != tt.ok {
+            t.Errorf("%s: got ok %v, want %v", tt.name, ok, tt.ok)
+            continue
+        }
+
+        if ok && !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestPlexPlayback(t *testing.T) {
+    tests := []struct {
+        name string
+        body string
+        ok bool
+        want playback
+    }{
+        {
+            "play",
+            `{ "event": "media.play", "Metadata": { "type": "track", "title": "Song", "parentTitle": "Album", "grandparentTitle": "Artist", "index": 4, "duration": 210000, "viewOffset": 5000, "Guid": [{ "id": "mbid://recording-1" }] } }`,
+            true,
+            playback{ artist: "Artist", track: "Song", album: "Album", albumArtist: "Artist", trackNumber: 4, duration: 210000, position: 5000, mbid: "recording-1", source: "plex" },
+        },
+        {
+            "scrobble uses the track artist",
+            `{ "event": "media.scrobble", "Metadata": { "type": "track", "title": "Song", "parentTitle": "Album", "grandparentTitle": "Various", "originalTitle": "Artist", "duration": 210000, "viewOffset": 190000, "Guid": [{ "id": "plex://track/abc" }] } }`,
+            true,
+            playback{ artist: "Artist", track: "Song", album: "Album", albumArtist: "Various", duration: 210000, position: 190000, completed: true, source: "plex" },
+        },
+        {
+            "stop",
+            `{ "event": "media.stop", "Metadata": { "type": "track", "title": "Song", "grandparentTitle": "Artist", "duration": 210000, "viewOffset": 60000 } }`,
+            true,
+            playback{ artist: "Artist", track: "Song", albumArtist: "Artist", duration: 210000, position: 60000, stopped: true, source: "plex" },
+        },
+        { "pause", `{ "event": "media.pause", "Metadata": { "type": "track" } }`, false, playback{} },
+        { "movie", `{ "event": "media.play", "Metadata": { "type": "movie" } }`, false, playback{} },
+    }
+
+    for _, tt := range tests {
+        var hook PlexWebhook
+        if err := json.Unmarshal([]byte(tt.body), &hook); err != nil {
+            t.Fatalf("%s: %s", tt.name, err)
+        }
+
+        got, ok := hook.playback()
+        if ok != tt.ok {
+            t.Errorf("%s: got ok %v, want %v", tt.name, ok, tt.ok)
+            continue
+        }
+
+        if ok && !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestPlaybackScrobble(t *testing.T) {
+    sc := playback{ artist: "Artist", track: "Song", trackNumber: 7, duration: 200000, position: 150000, completed: true }.Scrobble()
+
+    if sc.Progress != 200000 || sc.TrackNumber != "7" || sc.AlbumArtist != "Artist" {
+        t.Errorf("unexpected scrobble: %+v", sc)
+    }
+}
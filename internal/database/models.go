@@ -9,9 +9,28 @@ import (
 )
 
 type Apikey struct {
-	Key  string
-	Name string
-	Uid  sql.NullInt64
+	ID         int64
+	Uid        int64
+	Name       string
+	Prefix     string
+	Hash       string
+	Scopes     string
+	CreatedAt  int64
+	LastUsedAt sql.NullInt64
+	ExpiresAt  sql.NullInt64
+}
+
+type Artwork struct {
+	ID          int64
+	Kind        string
+	ArtistName  string
+	Name        string
+	Status      string
+	Source      sql.NullString
+	Url         sql.NullString
+	Image       []byte
+	ContentType sql.NullString
+	FetchedAt   int64
 }
 
 type HistorySpotify struct {
@@ -20,6 +39,41 @@ type HistorySpotify struct {
 	TrackName  string
 	AlbumName  sql.NullString
 	Timestamp  int64
+	MsPlayed   int64
+	Platform   sql.NullString
+	TrackUri   sql.NullString
+	ReasonEnd  sql.NullString
+	Uid        sql.NullInt64
+}
+
+type LastfmImport struct {
+	Uid        int64
+	Status     string
+	Page       int64
+	TotalPages int64
+	Imported   int64
+	Skipped    int64
+	Since      int64
+	Until      int64
+	StartedAt  int64
+	UpdatedAt  int64
+	Error      sql.NullString
+}
+
+type LastfmQueue struct {
+	ID          int64
+	Uid         int64
+	ArtistName  string
+	TrackName   string
+	AlbumName   sql.NullString
+	AlbumArtist sql.NullString
+	TrackNumber sql.NullString
+	Mbid        sql.NullString
+	Duration    int64
+	Timestamp   int64
+	Attempts    int64
+	NextAttempt int64
+	LastError   sql.NullString
 }
 
 type MusicSession struct {
@@ -30,6 +84,36 @@ type MusicSession struct {
 	Uid    int64
 }
 
+type MusicbrainzLookup struct {
+	ArtistName    string
+	TrackName     string
+	AlbumName     string
+	RecordingMbid sql.NullString
+	ReleaseMbid   sql.NullString
+	ArtistMbid    sql.NullString
+	Found         int64
+	LookedUpAt    int64
+	Length        sql.NullInt64
+}
+
+type Report struct {
+	ID        int64
+	Uid       int64
+	Slug      string
+	Period    string
+	Data      string
+	CreatedAt int64
+}
+
+type RewriteRule struct {
+	ID          int64
+	Uid         int64
+	Field       string
+	MatchType   string
+	Pattern     string
+	Replacement string
+}
+
 type Scrobble struct {
 	ID          int64
 	ArtistName  string
@@ -42,28 +126,59 @@ type Scrobble struct {
 	Source      sql.NullString
 	Mbid        sql.NullString
 	Uid         int64
+	AlbumMbid   sql.NullString
+	ArtistMbid  sql.NullString
+}
+
+type ScrobbleArtist struct {
+	ScrobbleID int64
+	Name       string
+	Position   int64
+}
+
+type ScrobbleRule struct {
+	ID              int64
+	Uid             int64
+	Source          string
+	Mode            string
+	MinPercent      int64
+	MinSeconds      int64
+	MaxTrackSeconds int64
+	CountRepeats    int64
 }
 
 type Session struct {
+	ID           int64
+	Uid          int64
 	Accesstoken  string
 	Refreshtoken string
-	Valid        sql.NullInt64
+	CreatedAt    int64
+	LastSeenAt   int64
+	ExpiresAt    int64
+	Ip           string
+	UserAgent    string
 }
 
 type User struct {
-	ID                   int64
-	Username             string
-	SpotifyAccessToken   sql.NullString
-	SpotifyRefreshToken  sql.NullString
-	SpotifyID            sql.NullString
-	SpotifyAuthState     sql.NullString
-	LastfmSessionName    sql.NullString
-	LastfmSessionKey     sql.NullString
-	Password             interface{}
-	TwitterRequestToken  sql.NullString
-	TwitterRequestSecret sql.NullString
-	TwitterOauthToken    sql.NullString
-	TwitterOauthSecret   sql.NullString
-	Reset                sql.NullString
-	ResetTime            sql.NullInt64
+	ID                    int64
+	Username              string
+	SpotifyAccessToken    sql.NullString
+	SpotifyRefreshToken   sql.NullString
+	SpotifyID             sql.NullString
+	SpotifyAuthState      sql.NullString
+	LastfmSessionName     sql.NullString
+	LastfmSessionKey      sql.NullString
+	Password              interface{}
+	TwitterRequestToken   sql.NullString
+	TwitterRequestSecret  sql.NullString
+	TwitterOauthToken     sql.NullString
+	TwitterOauthSecret    sql.NullString
+	Reset                 sql.NullString
+	ResetTime             sql.NullInt64
+	Timezone              string
+	Email                 sql.NullString
+	EmailVerifiedAt       sql.NullInt64
+	EmailVerification     sql.NullString
+	EmailVerificationTime sql.NullInt64
+	LastfmAuthState       sql.NullString
 }
@@ -10,9 +10,10 @@ import (
 	"database/sql"
 )
 
-const addToHistory = `-- name: AddToHistory :exec
-INSERT INTO history_spotify(artist_name, album_name, track_name, timestamp)
-VALUES(?, ?, ?, ?)
+const addToHistory = `-- name: AddToHistory :execrows
+INSERT INTO history_spotify(artist_name, album_name, track_name, timestamp, ms_played, platform, track_uri, reason_end, uid)
+VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT DO NOTHING
 `
 
 type AddToHistoryParams struct {
@@ -20,25 +21,27 @@ type AddToHistoryParams struct {
 	AlbumName  sql.NullString
 	TrackName  string
 	Timestamp  int64
+	MsPlayed   int64
+	Platform   sql.NullString
+	TrackUri   sql.NullString
+	ReasonEnd  sql.NullString
+	Uid        sql.NullInt64
 }
 
-func (q *Queries) AddToHistory(ctx context.Context, arg AddToHistoryParams) error {
-	_, err := q.db.ExecContext(ctx, addToHistory,
+func (q *Queries) AddToHistory(ctx context.Context, arg AddToHistoryParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, addToHistory,
 		arg.ArtistName,
 		arg.AlbumName,
 		arg.TrackName,
 		arg.Timestamp,
+		arg.MsPlayed,
+		arg.Platform,
+		arg.TrackUri,
+		arg.ReasonEnd,
+		arg.Uid,
 	)
-	return err
-}
-
-const historyToScrobbles = `-- name: HistoryToScrobbles :exec
-INSERT INTO scrobbles(artist_name, track_name, album_name, timestamp, duration, source, uid, track_number, artist_name)
-SELECT artist_name, track_name, album_name, timestamp, 30000, "spotify-local", 1, 0, artist_name
-FROM history_spotify
-`
-
-func (q *Queries) HistoryToScrobbles(ctx context.Context) error {
-	_, err := q.db.ExecContext(ctx, historyToScrobbles)
-	return err
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
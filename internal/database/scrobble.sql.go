@@ -10,6 +10,139 @@ import (
 	"database/sql"
 )
 
+const addScrobbleArtist = `-- name: AddScrobbleArtist :exec
+INSERT OR IGNORE INTO scrobble_artists(scrobble_id, name, position)
+VALUES(?, ?, ?)
+`
+
+type AddScrobbleArtistParams struct {
+	ScrobbleID int64
+	Name       string
+	Position   int64
+}
+
+func (q *Queries) AddScrobbleArtist(ctx context.Context, arg AddScrobbleArtistParams) error {
+	_, err := q.db.ExecContext(ctx, addScrobbleArtist, arg.ScrobbleID, arg.Name, arg.Position)
+	return err
+}
+
+const countAlbumScrobbles = `-- name: CountAlbumScrobbles :one
+SELECT count(id) as total
+FROM scrobbles
+WHERE uid = ? AND artist_name = ? AND album_name = ?
+`
+
+type CountAlbumScrobblesParams struct {
+	Uid        int64
+	ArtistName string
+	AlbumName  sql.NullString
+}
+
+func (q *Queries) CountAlbumScrobbles(ctx context.Context, arg CountAlbumScrobblesParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countAlbumScrobbles, arg.Uid, arg.ArtistName, arg.AlbumName)
+	var total int64
+	err := row.Scan(&total)
+	return total, err
+}
+
+const countArtistScrobbles = `-- name: CountArtistScrobbles :one
+SELECT count(DISTINCT scrobbles.id) as total
+FROM scrobbles
+JOIN scrobble_artists
+ON scrobble_artists.scrobble_id = scrobbles.id
+WHERE uid = ? AND scrobble_artists.name = ?
+`
+
+type CountArtistScrobblesParams struct {
+	Uid  int64
+	Name string
+}
+
+func (q *Queries) CountArtistScrobbles(ctx context.Context, arg CountArtistScrobblesParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countArtistScrobbles, arg.Uid, arg.Name)
+	var total int64
+	err := row.Scan(&total)
+	return total, err
+}
+
+const countScrobbles = `-- name: CountScrobbles :one
+SELECT count(id) as total
+FROM scrobbles
+WHERE uid = ?
+`
+
+func (q *Queries) CountScrobbles(ctx context.Context, uid int64) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countScrobbles, uid)
+	var total int64
+	err := row.Scan(&total)
+	return total, err
+}
+
+const countTrackScrobbles = `-- name: CountTrackScrobbles :one
+SELECT count(id) as total
+FROM scrobbles
+WHERE uid = ? AND artist_name = ? AND track_name = ?
+`
+
+type CountTrackScrobblesParams struct {
+	Uid        int64
+	ArtistName string
+	TrackName  string
+}
+
+func (q *Queries) CountTrackScrobbles(ctx context.Context, arg CountTrackScrobblesParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countTrackScrobbles, arg.Uid, arg.ArtistName, arg.TrackName)
+	var total int64
+	err := row.Scan(&total)
+	return total, err
+}
+
+const getFeaturedScrobbles = `-- name: GetFeaturedScrobbles :many
+SELECT id, artist_name, track_name
+FROM scrobbles
+WHERE artist_name LIKE '%,%'
+OR artist_name LIKE '% feat %'
+OR artist_name LIKE '% feat. %'
+OR artist_name LIKE '% ft %'
+OR artist_name LIKE '% ft. %'
+OR artist_name LIKE '% featuring %'
+OR track_name LIKE '%(feat%'
+OR track_name LIKE '%[feat%'
+OR track_name LIKE '%(ft%'
+OR track_name LIKE '%[ft%'
+`
+
+type GetFeaturedScrobblesRow struct {
+	ID         int64
+	ArtistName string
+	TrackName  string
+}
+
+// Only the delimited forms Normalize looks for, so titles like "Aftermath"
+// aren't pulled in.
+func (q *Queries) GetFeaturedScrobbles(ctx context.Context) ([]GetFeaturedScrobblesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFeaturedScrobbles)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFeaturedScrobblesRow
+	for rows.Next() {
+		var i GetFeaturedScrobblesRow
+		if err := rows.Scan(&i.ID, &i.ArtistName, &i.TrackName); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getLatestTrack = `-- name: GetLatestTrack :one
 SELECT artist_name, track_name, timestamp, duration
 FROM scrobbles
@@ -80,82 +213,64 @@ func (q *Queries) GetRecentScrobbles(ctx context.Context, uid int64) ([]GetRecen
 	return items, nil
 }
 
-const getTopAlbumsOfDay = `-- name: GetTopAlbumsOfDay :many
-SELECT album_name, artist_name, count(id) as plays
+const getScrobble = `-- name: GetScrobble :one
+SELECT id, artist_name, track_name, album_name, album_artist, timestamp, duration, source
 FROM scrobbles
-WHERE uid = ? AND album_name IS NOT "Unknown" AND album_name IS NOT NULL AND album_name IS NOT "" AND (timestamp / 1000) >= (strftime("%s", "now") - (60*60*24*1))
-GROUP BY album_name
-ORDER BY plays DESC
-LIMIT ?
+WHERE id = ? AND uid = ?
 `
 
-type GetTopAlbumsOfDayParams struct {
-	Uid   int64
-	Limit int64
+type GetScrobbleParams struct {
+	ID  int64
+	Uid int64
 }
 
-type GetTopAlbumsOfDayRow struct {
-	AlbumName  sql.NullString
-	ArtistName string
-	Plays      int64
+type GetScrobbleRow struct {
+	ID          int64
+	ArtistName  string
+	TrackName   string
+	AlbumName   sql.NullString
+	AlbumArtist sql.NullString
+	Timestamp   int64
+	Duration    int64
+	Source      sql.NullString
 }
 
-func (q *Queries) GetTopAlbumsOfDay(ctx context.Context, arg GetTopAlbumsOfDayParams) ([]GetTopAlbumsOfDayRow, error) {
-	rows, err := q.db.QueryContext(ctx, getTopAlbumsOfDay, arg.Uid, arg.Limit)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var items []GetTopAlbumsOfDayRow
-	for rows.Next() {
-		var i GetTopAlbumsOfDayRow
-		if err := rows.Scan(&i.AlbumName, &i.ArtistName, &i.Plays); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Close(); err != nil {
-		return nil, err
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return items, nil
+func (q *Queries) GetScrobble(ctx context.Context, arg GetScrobbleParams) (GetScrobbleRow, error) {
+	row := q.db.QueryRowContext(ctx, getScrobble, arg.ID, arg.Uid)
+	var i GetScrobbleRow
+	err := row.Scan(
+		&i.ID,
+		&i.ArtistName,
+		&i.TrackName,
+		&i.AlbumName,
+		&i.AlbumArtist,
+		&i.Timestamp,
+		&i.Duration,
+		&i.Source,
+	)
+	return i, err
 }
 
-const getTopAlbumsOfMonth = `-- name: GetTopAlbumsOfMonth :many
-SELECT album_name, artist_name, count(id) as plays
-FROM scrobbles
-WHERE uid = ? AND album_name IS NOT "Unknown" AND album_name IS NOT NULL AND album_name IS NOT "" AND (timestamp / 1000) >= (strftime("%s", "now") - (60*60*24*30))
-GROUP BY album_name
-ORDER BY plays DESC
-LIMIT ?
+const getScrobbleArtists = `-- name: GetScrobbleArtists :many
+SELECT name
+FROM scrobble_artists
+WHERE scrobble_id = ?
+ORDER BY position
 `
 
-type GetTopAlbumsOfMonthParams struct {
-	Uid   int64
-	Limit int64
-}
-
-type GetTopAlbumsOfMonthRow struct {
-	AlbumName  sql.NullString
-	ArtistName string
-	Plays      int64
-}
-
-func (q *Queries) GetTopAlbumsOfMonth(ctx context.Context, arg GetTopAlbumsOfMonthParams) ([]GetTopAlbumsOfMonthRow, error) {
-	rows, err := q.db.QueryContext(ctx, getTopAlbumsOfMonth, arg.Uid, arg.Limit)
+func (q *Queries) GetScrobbleArtists(ctx context.Context, scrobbleID int64) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getScrobbleArtists, scrobbleID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []GetTopAlbumsOfMonthRow
+	var items []string
 	for rows.Next() {
-		var i GetTopAlbumsOfMonthRow
-		if err := rows.Scan(&i.AlbumName, &i.ArtistName, &i.Plays); err != nil {
+		var name string
+		if err := rows.Scan(&name); err != nil {
 			return nil, err
 		}
-		items = append(items, i)
+		items = append(items, name)
 	}
 	if err := rows.Close(); err != nil {
 		return nil, err
@@ -166,36 +281,50 @@ func (q *Queries) GetTopAlbumsOfMonth(ctx context.Context, arg GetTopAlbumsOfMon
 	return items, nil
 }
 
-const getTopAlbumsOfWeek = `-- name: GetTopAlbumsOfWeek :many
-SELECT album_name, artist_name, count(id) as plays
+const getScrobbles = `-- name: GetScrobbles :many
+SELECT id, artist_name, track_name, album_name, album_artist, timestamp, duration, source
 FROM scrobbles
-WHERE uid = ? AND album_name IS NOT "Unknown" AND album_name IS NOT NULL AND album_name IS NOT "" AND (timestamp / 1000) >= (strftime("%s", "now") - (60*60*24*7))
-GROUP BY album_name
-ORDER BY plays DESC
-LIMIT ?
+WHERE uid = ?
+ORDER BY timestamp DESC
+LIMIT ? OFFSET ?
 `
 
-type GetTopAlbumsOfWeekParams struct {
-	Uid   int64
-	Limit int64
+type GetScrobblesParams struct {
+	Uid    int64
+	Limit  int64
+	Offset int64
 }
 
-type GetTopAlbumsOfWeekRow struct {
-	AlbumName  sql.NullString
-	ArtistName string
-	Plays      int64
+type GetScrobblesRow struct {
+	ID          int64
+	ArtistName  string
+	TrackName   string
+	AlbumName   sql.NullString
+	AlbumArtist sql.NullString
+	Timestamp   int64
+	Duration    int64
+	Source      sql.NullString
 }
 
-func (q *Queries) GetTopAlbumsOfWeek(ctx context.Context, arg GetTopAlbumsOfWeekParams) ([]GetTopAlbumsOfWeekRow, error) {
-	rows, err := q.db.QueryContext(ctx, getTopAlbumsOfWeek, arg.Uid, arg.Limit)
+func (q *Queries) GetScrobbles(ctx context.Context, arg GetScrobblesParams) ([]GetScrobblesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getScrobbles, arg.Uid, arg.Limit, arg.Offset)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []GetTopAlbumsOfWeekRow
+	var items []GetScrobblesRow
 	for rows.Next() {
-		var i GetTopAlbumsOfWeekRow
-		if err := rows.Scan(&i.AlbumName, &i.ArtistName, &i.Plays); err != nil {
+		var i GetScrobblesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ArtistName,
+			&i.TrackName,
+			&i.AlbumName,
+			&i.AlbumArtist,
+			&i.Timestamp,
+			&i.Duration,
+			&i.Source,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -209,418 +338,193 @@ func (q *Queries) GetTopAlbumsOfWeek(ctx context.Context, arg GetTopAlbumsOfWeek
 	return items, nil
 }
 
-const getTopAlbumsOfYear = `-- name: GetTopAlbumsOfYear :many
-SELECT album_name, artist_name, count(id) as plays
-FROM scrobbles
-WHERE uid = ? AND album_name IS NOT "Unknown" AND album_name IS NOT NULL AND album_name IS NOT "" AND (timestamp / 1000) >= (strftime("%s", "now") - (60*60*24*365))
-GROUP BY album_name
-ORDER BY plays DESC
-LIMIT ?
+const removeArtistCredits = `-- name: RemoveArtistCredits :exec
+DELETE FROM scrobble_artists
+WHERE name = ? AND scrobble_id IN (
+    SELECT id
+    FROM scrobbles
+    WHERE uid = ?
+)
 `
 
-type GetTopAlbumsOfYearParams struct {
-	Uid   int64
-	Limit int64
-}
-
-type GetTopAlbumsOfYearRow struct {
-	AlbumName  sql.NullString
-	ArtistName string
-	Plays      int64
+type RemoveArtistCreditsParams struct {
+	Name string
+	Uid  int64
 }
 
-func (q *Queries) GetTopAlbumsOfYear(ctx context.Context, arg GetTopAlbumsOfYearParams) ([]GetTopAlbumsOfYearRow, error) {
-	rows, err := q.db.QueryContext(ctx, getTopAlbumsOfYear, arg.Uid, arg.Limit)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var items []GetTopAlbumsOfYearRow
-	for rows.Next() {
-		var i GetTopAlbumsOfYearRow
-		if err := rows.Scan(&i.AlbumName, &i.ArtistName, &i.Plays); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Close(); err != nil {
-		return nil, err
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return items, nil
+func (q *Queries) RemoveArtistCredits(ctx context.Context, arg RemoveArtistCreditsParams) error {
+	_, err := q.db.ExecContext(ctx, removeArtistCredits, arg.Name, arg.Uid)
+	return err
 }
 
-const getTopArtistsOfDay = `-- name: GetTopArtistsOfDay :many
-With splits as (
-  SELECT trim(value) as artist
-  FROM scrobbles, json_each('["' || replace(artist_name, ',', '","') || '"]')
-  WHERE uid = ? AND (timestamp / 1000) >= (strftime("%s", "now") - (60*60*24*1))
-)
-SELECT artist, count(*) as plays
-FROM splits
-group by artist
-order by plays DESC
-limit ?
+const removeScrobble = `-- name: RemoveScrobble :execrows
+DELETE FROM scrobbles
+WHERE id = ? AND uid = ?
 `
 
-type GetTopArtistsOfDayParams struct {
-	Uid   int64
-	Limit int64
-}
-
-type GetTopArtistsOfDayRow struct {
-	Artist string
-	Plays  int64
+type RemoveScrobbleParams struct {
+	ID  int64
+	Uid int64
 }
 
-func (q *Queries) GetTopArtistsOfDay(ctx context.Context, arg GetTopArtistsOfDayParams) ([]GetTopArtistsOfDayRow, error) {
-	rows, err := q.db.QueryContext(ctx, getTopArtistsOfDay, arg.Uid, arg.Limit)
+func (q *Queries) RemoveScrobble(ctx context.Context, arg RemoveScrobbleParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, removeScrobble, arg.ID, arg.Uid)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	defer rows.Close()
-	var items []GetTopArtistsOfDayRow
-	for rows.Next() {
-		var i GetTopArtistsOfDayRow
-		if err := rows.Scan(&i.Artist, &i.Plays); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Close(); err != nil {
-		return nil, err
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return items, nil
+	return result.RowsAffected()
 }
 
-const getTopArtistsOfMonth = `-- name: GetTopArtistsOfMonth :many
-With splits as (
-  SELECT trim(value) as artist
-  FROM scrobbles, json_each('["' || replace(artist_name, ',', '","') || '"]')
-  WHERE uid = ? AND (timestamp / 1000) >= (strftime("%s", "now") - (60*60*24*30))
-)
-SELECT artist, count(*) as plays
-FROM splits
-group by artist
-order by plays DESC
-limit ?
+const removeScrobbleArtist = `-- name: RemoveScrobbleArtist :exec
+DELETE FROM scrobble_artists
+WHERE scrobble_id = ? AND name = ?
 `
 
-type GetTopArtistsOfMonthParams struct {
-	Uid   int64
-	Limit int64
-}
-
-type GetTopArtistsOfMonthRow struct {
-	Artist string
-	Plays  int64
+type RemoveScrobbleArtistParams struct {
+	ScrobbleID int64
+	Name       string
 }
 
-func (q *Queries) GetTopArtistsOfMonth(ctx context.Context, arg GetTopArtistsOfMonthParams) ([]GetTopArtistsOfMonthRow, error) {
-	rows, err := q.db.QueryContext(ctx, getTopArtistsOfMonth, arg.Uid, arg.Limit)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var items []GetTopArtistsOfMonthRow
-	for rows.Next() {
-		var i GetTopArtistsOfMonthRow
-		if err := rows.Scan(&i.Artist, &i.Plays); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Close(); err != nil {
-		return nil, err
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return items, nil
+func (q *Queries) RemoveScrobbleArtist(ctx context.Context, arg RemoveScrobbleArtistParams) error {
+	_, err := q.db.ExecContext(ctx, removeScrobbleArtist, arg.ScrobbleID, arg.Name)
+	return err
 }
 
-const getTopArtistsOfWeek = `-- name: GetTopArtistsOfWeek :many
-With splits as (
-  SELECT trim(value) as artist
-  FROM scrobbles, json_each('["' || replace(artist_name, ',', '","') || '"]')
-  WHERE uid = ? AND (timestamp / 1000) >= (strftime("%s", "now") - (60*60*24*7))
+const removeScrobbleArtists = `-- name: RemoveScrobbleArtists :exec
+DELETE FROM scrobble_artists
+WHERE scrobble_id IN (
+    SELECT id
+    FROM scrobbles
+    WHERE id = ? AND uid = ?
 )
-SELECT artist, count(*) as plays
-FROM splits
-group by artist
-order by plays DESC
-limit ?
 `
 
-type GetTopArtistsOfWeekParams struct {
-	Uid   int64
-	Limit int64
+type RemoveScrobbleArtistsParams struct {
+	ID  int64
+	Uid int64
 }
 
-type GetTopArtistsOfWeekRow struct {
-	Artist string
-	Plays  int64
-}
-
-func (q *Queries) GetTopArtistsOfWeek(ctx context.Context, arg GetTopArtistsOfWeekParams) ([]GetTopArtistsOfWeekRow, error) {
-	rows, err := q.db.QueryContext(ctx, getTopArtistsOfWeek, arg.Uid, arg.Limit)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var items []GetTopArtistsOfWeekRow
-	for rows.Next() {
-		var i GetTopArtistsOfWeekRow
-		if err := rows.Scan(&i.Artist, &i.Plays); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Close(); err != nil {
-		return nil, err
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return items, nil
+func (q *Queries) RemoveScrobbleArtists(ctx context.Context, arg RemoveScrobbleArtistsParams) error {
+	_, err := q.db.ExecContext(ctx, removeScrobbleArtists, arg.ID, arg.Uid)
+	return err
 }
 
-const getTopArtistsOfYear = `-- name: GetTopArtistsOfYear :many
-With splits as (
-  SELECT trim(value) as artist
-  FROM scrobbles, json_each('["' || replace(artist_name, ',', '","') || '"]')
-  WHERE uid = ? AND (timestamp / 1000) >= (strftime("%s", "now") - (60*60*24*365))
+const renameArtistCredits = `-- name: RenameArtistCredits :exec
+UPDATE OR IGNORE scrobble_artists
+SET name = ?
+WHERE name = ? AND scrobble_id IN (
+    SELECT id
+    FROM scrobbles
+    WHERE uid = ?
 )
-SELECT artist, count(*) as plays
-FROM splits
-group by artist
-order by plays DESC
-limit ?
 `
 
-type GetTopArtistsOfYearParams struct {
-	Uid   int64
-	Limit int64
+type RenameArtistCreditsParams struct {
+	NewName string
+	OldName string
+	Uid     int64
 }
 
-type GetTopArtistsOfYearRow struct {
-	Artist string
-	Plays  int64
-}
-
-func (q *Queries) GetTopArtistsOfYear(ctx context.Context, arg GetTopArtistsOfYearParams) ([]GetTopArtistsOfYearRow, error) {
-	rows, err := q.db.QueryContext(ctx, getTopArtistsOfYear, arg.Uid, arg.Limit)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var items []GetTopArtistsOfYearRow
-	for rows.Next() {
-		var i GetTopArtistsOfYearRow
-		if err := rows.Scan(&i.Artist, &i.Plays); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Close(); err != nil {
-		return nil, err
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return items, nil
+func (q *Queries) RenameArtistCredits(ctx context.Context, arg RenameArtistCreditsParams) error {
+	_, err := q.db.ExecContext(ctx, renameArtistCredits, arg.NewName, arg.OldName, arg.Uid)
+	return err
 }
 
-const getTopTracksOfDay = `-- name: GetTopTracksOfDay :many
-SELECT track_name, artist_name, count(id) as plays
-FROM scrobbles
-WHERE uid = ? AND (timestamp / 1000) >= (strftime("%s", "now") - (60*60*24*1))
-GROUP BY track_name
-ORDER BY plays DESC
-LIMIT ?
+const renameScrobbleAlbum = `-- name: RenameScrobbleAlbum :execrows
+UPDATE scrobbles
+SET album_name = ?
+WHERE uid = ? AND artist_name = ? AND album_name = ?
 `
 
-type GetTopTracksOfDayParams struct {
-	Uid   int64
-	Limit int64
-}
-
-type GetTopTracksOfDayRow struct {
-	TrackName  string
+type RenameScrobbleAlbumParams struct {
+	NewName    sql.NullString
+	Uid        int64
 	ArtistName string
-	Plays      int64
+	OldName    sql.NullString
 }
 
-func (q *Queries) GetTopTracksOfDay(ctx context.Context, arg GetTopTracksOfDayParams) ([]GetTopTracksOfDayRow, error) {
-	rows, err := q.db.QueryContext(ctx, getTopTracksOfDay, arg.Uid, arg.Limit)
+func (q *Queries) RenameScrobbleAlbum(ctx context.Context, arg RenameScrobbleAlbumParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, renameScrobbleAlbum,
+		arg.NewName,
+		arg.Uid,
+		arg.ArtistName,
+		arg.OldName,
+	)
 	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var items []GetTopTracksOfDayRow
-	for rows.Next() {
-		var i GetTopTracksOfDayRow
-		if err := rows.Scan(&i.TrackName, &i.ArtistName, &i.Plays); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Close(); err != nil {
-		return nil, err
+		return 0, err
 	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return items, nil
+	return result.RowsAffected()
 }
 
-const getTopTracksOfMonth = `-- name: GetTopTracksOfMonth :many
-SELECT track_name, artist_name, count(id) as plays
-FROM scrobbles
-WHERE uid = ? AND (timestamp / 1000) >= (strftime("%s", "now") - (60*60*24*30))
-GROUP BY track_name
-ORDER BY plays DESC
-LIMIT ?
+const renameScrobbleAlbumArtist = `-- name: RenameScrobbleAlbumArtist :execrows
+UPDATE scrobbles
+SET album_artist = ?
+WHERE uid = ? AND album_artist = ?
 `
 
-type GetTopTracksOfMonthParams struct {
-	Uid   int64
-	Limit int64
+type RenameScrobbleAlbumArtistParams struct {
+	NewName sql.NullString
+	Uid     int64
+	OldName sql.NullString
 }
 
-type GetTopTracksOfMonthRow struct {
-	TrackName  string
-	ArtistName string
-	Plays      int64
-}
-
-func (q *Queries) GetTopTracksOfMonth(ctx context.Context, arg GetTopTracksOfMonthParams) ([]GetTopTracksOfMonthRow, error) {
-	rows, err := q.db.QueryContext(ctx, getTopTracksOfMonth, arg.Uid, arg.Limit)
+func (q *Queries) RenameScrobbleAlbumArtist(ctx context.Context, arg RenameScrobbleAlbumArtistParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, renameScrobbleAlbumArtist, arg.NewName, arg.Uid, arg.OldName)
 	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var items []GetTopTracksOfMonthRow
-	for rows.Next() {
-		var i GetTopTracksOfMonthRow
-		if err := rows.Scan(&i.TrackName, &i.ArtistName, &i.Plays); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
+		return 0, err
 	}
-	if err := rows.Close(); err != nil {
-		return nil, err
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return items, nil
+	return result.RowsAffected()
 }
 
-const getTopTracksOfWeek = `-- name: GetTopTracksOfWeek :many
-SELECT track_name, artist_name, count(id) as plays
-FROM scrobbles
-WHERE uid = ? AND (timestamp / 1000) >= (strftime("%s", "now") - (60*60*24*7))
-GROUP BY track_name
-ORDER BY plays DESC
-LIMIT ?
+const renameScrobbleArtist = `-- name: RenameScrobbleArtist :execrows
+UPDATE scrobbles
+SET artist_name = ?
+WHERE uid = ? AND artist_name = ?
 `
 
-type GetTopTracksOfWeekParams struct {
-	Uid   int64
-	Limit int64
+type RenameScrobbleArtistParams struct {
+	NewName string
+	Uid     int64
+	OldName string
 }
 
-type GetTopTracksOfWeekRow struct {
-	TrackName  string
-	ArtistName string
-	Plays      int64
-}
-
-func (q *Queries) GetTopTracksOfWeek(ctx context.Context, arg GetTopTracksOfWeekParams) ([]GetTopTracksOfWeekRow, error) {
-	rows, err := q.db.QueryContext(ctx, getTopTracksOfWeek, arg.Uid, arg.Limit)
+func (q *Queries) RenameScrobbleArtist(ctx context.Context, arg RenameScrobbleArtistParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, renameScrobbleArtist, arg.NewName, arg.Uid, arg.OldName)
 	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var items []GetTopTracksOfWeekRow
-	for rows.Next() {
-		var i GetTopTracksOfWeekRow
-		if err := rows.Scan(&i.TrackName, &i.ArtistName, &i.Plays); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Close(); err != nil {
-		return nil, err
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
+		return 0, err
 	}
-	return items, nil
+	return result.RowsAffected()
 }
 
-const getTopTracksOfYear = `-- name: GetTopTracksOfYear :many
-SELECT track_name, artist_name, count(id) as plays
-FROM scrobbles
-WHERE uid = ? AND (timestamp / 1000) >= (strftime("%s", "now") - (60*60*24*365))
-GROUP BY track_name
-ORDER BY plays DESC
-LIMIT ?
+const renameScrobbleTrack = `-- name: RenameScrobbleTrack :execrows
+UPDATE scrobbles
+SET track_name = ?
+WHERE uid = ? AND artist_name = ? AND track_name = ?
 `
 
-type GetTopTracksOfYearParams struct {
-	Uid   int64
-	Limit int64
-}
-
-type GetTopTracksOfYearRow struct {
-	TrackName  string
+type RenameScrobbleTrackParams struct {
+	NewName    string
+	Uid        int64
 	ArtistName string
-	Plays      int64
+	OldName    string
 }
 
-func (q *Queries) GetTopTracksOfYear(ctx context.Context, arg GetTopTracksOfYearParams) ([]GetTopTracksOfYearRow, error) {
-	rows, err := q.db.QueryContext(ctx, getTopTracksOfYear, arg.Uid, arg.Limit)
+func (q *Queries) RenameScrobbleTrack(ctx context.Context, arg RenameScrobbleTrackParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, renameScrobbleTrack,
+		arg.NewName,
+		arg.Uid,
+		arg.ArtistName,
+		arg.OldName,
+	)
 	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var items []GetTopTracksOfYearRow
-	for rows.Next() {
-		var i GetTopTracksOfYearRow
-		if err := rows.Scan(&i.TrackName, &i.ArtistName, &i.Plays); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Close(); err != nil {
-		return nil, err
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
+		return 0, err
 	}
-	return items, nil
-}
-
-const removeScrobble = `-- name: RemoveScrobble :exec
-DELETE FROM scrobbles
-WHERE id = ?
-`
-
-func (q *Queries) RemoveScrobble(ctx context.Context, id int64) error {
-	_, err := q.db.ExecContext(ctx, removeScrobble, id)
-	return err
+	return result.RowsAffected()
 }
 
-const saveScrobble = `-- name: SaveScrobble :exec
-INSERT INTO scrobbles(artist_name, track_name, album_name, album_artist, mbid, track_number, duration, timestamp, source, uid)
-VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+const saveScrobble = `-- name: SaveScrobble :execlastid
+INSERT INTO scrobbles(artist_name, track_name, album_name, album_artist, mbid, album_mbid, artist_mbid, track_number, duration, timestamp, source, uid)
+VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 `
 
 type SaveScrobbleParams struct {
@@ -629,6 +533,8 @@ type SaveScrobbleParams struct {
 	AlbumName   sql.NullString
 	AlbumArtist sql.NullString
 	Mbid        sql.NullString
+	AlbumMbid   sql.NullString
+	ArtistMbid  sql.NullString
 	TrackNumber sql.NullString
 	Duration    int64
 	Timestamp   int64
@@ -636,18 +542,85 @@ type SaveScrobbleParams struct {
 	Uid         int64
 }
 
-func (q *Queries) SaveScrobble(ctx context.Context, arg SaveScrobbleParams) error {
-	_, err := q.db.ExecContext(ctx, saveScrobble,
+func (q *Queries) SaveScrobble(ctx context.Context, arg SaveScrobbleParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, saveScrobble,
 		arg.ArtistName,
 		arg.TrackName,
 		arg.AlbumName,
 		arg.AlbumArtist,
 		arg.Mbid,
+		arg.AlbumMbid,
+		arg.ArtistMbid,
 		arg.TrackNumber,
 		arg.Duration,
 		arg.Timestamp,
 		arg.Source,
 		arg.Uid,
 	)
-	return err
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+const scrobbleExistsNear = `-- name: ScrobbleExistsNear :one
+SELECT EXISTS (
+    SELECT 1
+    FROM scrobbles
+    WHERE uid = ?
+    AND artist_name = ?
+    AND track_name = ?
+    AND timestamp BETWEEN ? AND ?
+) as found
+`
+
+type ScrobbleExistsNearParams struct {
+	Uid        int64
+	ArtistName string
+	TrackName  string
+	Start      int64
+	End        int64
+}
+
+func (q *Queries) ScrobbleExistsNear(ctx context.Context, arg ScrobbleExistsNearParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, scrobbleExistsNear,
+		arg.Uid,
+		arg.ArtistName,
+		arg.TrackName,
+		arg.Start,
+		arg.End,
+	)
+	var found int64
+	err := row.Scan(&found)
+	return found, err
+}
+
+const updateScrobble = `-- name: UpdateScrobble :execrows
+UPDATE scrobbles
+SET artist_name = ?,
+    track_name = ?,
+    album_name = ?
+WHERE id = ? AND uid = ?
+`
+
+type UpdateScrobbleParams struct {
+	ArtistName string
+	TrackName  string
+	AlbumName  sql.NullString
+	ID         int64
+	Uid        int64
+}
+
+func (q *Queries) UpdateScrobble(ctx context.Context, arg UpdateScrobbleParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateScrobble,
+		arg.ArtistName,
+		arg.TrackName,
+		arg.AlbumName,
+		arg.ID,
+		arg.Uid,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
@@ -21,6 +21,25 @@ func (q *Queries) ActivateMusicSession(ctx context.Context, id int64) error {
 	return err
 }
 
+const claimLastFMAuthState = `-- name: ClaimLastFMAuthState :execrows
+UPDATE users
+SET lastfm_auth_state = NULL
+WHERE username = ? AND lastfm_auth_state = ?
+`
+
+type ClaimLastFMAuthStateParams struct {
+	Username        string
+	LastfmAuthState sql.NullString
+}
+
+func (q *Queries) ClaimLastFMAuthState(ctx context.Context, arg ClaimLastFMAuthStateParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, claimLastFMAuthState, arg.Username, arg.LastfmAuthState)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const deactivateMusicSession = `-- name: DeactivateMusicSession :exec
 UPDATE music_sessions
 SET active = 0
@@ -32,6 +51,54 @@ func (q *Queries) DeactivateMusicSession(ctx context.Context, id int64) error {
 	return err
 }
 
+const deleteExpiredSessions = `-- name: DeleteExpiredSessions :execrows
+DELETE FROM sessions
+WHERE expires_at < ?
+`
+
+func (q *Queries) DeleteExpiredSessions(ctx context.Context, expiresAt int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteExpiredSessions, expiresAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteUserSession = `-- name: DeleteUserSession :exec
+DELETE FROM sessions
+WHERE accessToken = ? AND refreshToken = ?
+`
+
+type DeleteUserSessionParams struct {
+	Accesstoken  string
+	Refreshtoken string
+}
+
+func (q *Queries) DeleteUserSession(ctx context.Context, arg DeleteUserSessionParams) error {
+	_, err := q.db.ExecContext(ctx, deleteUserSession, arg.Accesstoken, arg.Refreshtoken)
+	return err
+}
+
+const deleteUserSessions = `-- name: DeleteUserSessions :exec
+DELETE FROM sessions
+WHERE uid = ?
+`
+
+func (q *Queries) DeleteUserSessions(ctx context.Context, uid int64) error {
+	_, err := q.db.ExecContext(ctx, deleteUserSessions, uid)
+	return err
+}
+
+const endUserSession = `-- name: EndUserSession :exec
+DELETE FROM sessions
+WHERE refreshToken = ?
+`
+
+func (q *Queries) EndUserSession(ctx context.Context, refreshtoken string) error {
+	_, err := q.db.ExecContext(ctx, endUserSession, refreshtoken)
+	return err
+}
+
 const getActiveMusicSessions = `-- name: GetActiveMusicSessions :many
 SELECT id, data, type, active
 FROM music_sessions
@@ -162,6 +229,40 @@ func (q *Queries) GetTwitterSessionByRequestToken(ctx context.Context, twitterRe
 	return i, err
 }
 
+const getUnattributedSessions = `-- name: GetUnattributedSessions :many
+SELECT accessToken, refreshToken
+FROM sessions
+WHERE uid IS NULL
+`
+
+type GetUnattributedSessionsRow struct {
+	Accesstoken  string
+	Refreshtoken string
+}
+
+func (q *Queries) GetUnattributedSessions(ctx context.Context) ([]GetUnattributedSessionsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUnattributedSessions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUnattributedSessionsRow
+	for rows.Next() {
+		var i GetUnattributedSessionsRow
+		if err := rows.Scan(&i.Accesstoken, &i.Refreshtoken); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getUserMusicSessions = `-- name: GetUserMusicSessions :many
 SELECT id, data, type, active
 FROM music_sessions
@@ -204,38 +305,90 @@ func (q *Queries) GetUserMusicSessions(ctx context.Context, uid int64) ([]GetUse
 }
 
 const getUserSession = `-- name: GetUserSession :one
-SELECT accessToken, refreshToken, valid
+SELECT sessions.id, users.username
 FROM sessions
-WHERE accessToken = ? AND refreshToken = ?
-LIMIT 1
+JOIN users
+ON users.id = sessions.uid
+WHERE sessions.refreshToken = ?
 `
 
-type GetUserSessionParams struct {
-	Accesstoken  string
-	Refreshtoken string
+type GetUserSessionRow struct {
+	ID       int64
+	Username string
 }
 
-func (q *Queries) GetUserSession(ctx context.Context, arg GetUserSessionParams) (Session, error) {
-	row := q.db.QueryRowContext(ctx, getUserSession, arg.Accesstoken, arg.Refreshtoken)
-	var i Session
-	err := row.Scan(&i.Accesstoken, &i.Refreshtoken, &i.Valid)
+func (q *Queries) GetUserSession(ctx context.Context, refreshtoken string) (GetUserSessionRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserSession, refreshtoken)
+	var i GetUserSessionRow
+	err := row.Scan(&i.ID, &i.Username)
 	return i, err
 }
 
-const invalidateUserSession = `-- name: InvalidateUserSession :exec
+const getUserSessions = `-- name: GetUserSessions :many
+SELECT id, refreshToken, created_at, last_seen_at, expires_at, ip, user_agent
+FROM sessions
+WHERE uid = ?
+ORDER BY last_seen_at DESC
+`
+
+type GetUserSessionsRow struct {
+	ID           int64
+	Refreshtoken string
+	CreatedAt    int64
+	LastSeenAt   int64
+	ExpiresAt    int64
+	Ip           string
+	UserAgent    string
+}
+
+func (q *Queries) GetUserSessions(ctx context.Context, uid int64) ([]GetUserSessionsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUserSessions, uid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUserSessionsRow
+	for rows.Next() {
+		var i GetUserSessionsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Refreshtoken,
+			&i.CreatedAt,
+			&i.LastSeenAt,
+			&i.ExpiresAt,
+			&i.Ip,
+			&i.UserAgent,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const refreshUserSession = `-- name: RefreshUserSession :execrows
 UPDATE sessions
-SET valid = 0
-WHERE accessToken = ? AND refreshToken = ?
+SET accessToken = ?
+WHERE refreshToken = ?
 `
 
-type InvalidateUserSessionParams struct {
+type RefreshUserSessionParams struct {
 	Accesstoken  string
 	Refreshtoken string
 }
 
-func (q *Queries) InvalidateUserSession(ctx context.Context, arg InvalidateUserSessionParams) error {
-	_, err := q.db.ExecContext(ctx, invalidateUserSession, arg.Accesstoken, arg.Refreshtoken)
-	return err
+func (q *Queries) RefreshUserSession(ctx context.Context, arg RefreshUserSessionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, refreshUserSession, arg.Accesstoken, arg.Refreshtoken)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
 const removeInactiveMusicSessions = `-- name: RemoveInactiveMusicSessions :exec
@@ -248,6 +401,52 @@ func (q *Queries) RemoveInactiveMusicSessions(ctx context.Context) error {
 	return err
 }
 
+const removeLastFMSession = `-- name: RemoveLastFMSession :exec
+UPDATE users
+SET lastfm_session_name = NULL,
+    lastfm_session_key = NULL
+WHERE username = ?
+`
+
+func (q *Queries) RemoveLastFMSession(ctx context.Context, username string) error {
+	_, err := q.db.ExecContext(ctx, removeLastFMSession, username)
+	return err
+}
+
+const revokeUserSession = `-- name: RevokeUserSession :execrows
+DELETE FROM sessions
+WHERE id = ? AND uid = ?
+`
+
+type RevokeUserSessionParams struct {
+	ID  int64
+	Uid int64
+}
+
+func (q *Queries) RevokeUserSession(ctx context.Context, arg RevokeUserSessionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeUserSession, arg.ID, arg.Uid)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const saveLastFMAuthState = `-- name: SaveLastFMAuthState :exec
+UPDATE users
+SET lastfm_auth_state = ?
+WHERE username = ?
+`
+
+type SaveLastFMAuthStateParams struct {
+	LastfmAuthState sql.NullString
+	Username        string
+}
+
+func (q *Queries) SaveLastFMAuthState(ctx context.Context, arg SaveLastFMAuthStateParams) error {
+	_, err := q.db.ExecContext(ctx, saveLastFMAuthState, arg.LastfmAuthState, arg.Username)
+	return err
+}
+
 const saveLastFMSession = `-- name: SaveLastFMSession :exec
 UPDATE users
 SET lastfm_session_name = ?,
@@ -345,17 +544,78 @@ func (q *Queries) SaveTwitterSession(ctx context.Context, arg SaveTwitterSession
 }
 
 const saveUserSession = `-- name: SaveUserSession :exec
-INSERT INTO sessions(accessToken, refreshToken)
-VALUES(?, ?)
+INSERT INTO sessions(uid, accessToken, refreshToken, created_at, last_seen_at, expires_at, ip, user_agent)
+SELECT id, ?, ?, ?, ?, ?, ?, ?
+FROM users
+WHERE username = ?
 `
 
 type SaveUserSessionParams struct {
 	Accesstoken  string
 	Refreshtoken string
+	CreatedAt    int64
+	LastSeenAt   int64
+	ExpiresAt    int64
+	Ip           string
+	UserAgent    string
+	Username     string
 }
 
 func (q *Queries) SaveUserSession(ctx context.Context, arg SaveUserSessionParams) error {
-	_, err := q.db.ExecContext(ctx, saveUserSession, arg.Accesstoken, arg.Refreshtoken)
+	_, err := q.db.ExecContext(ctx, saveUserSession,
+		arg.Accesstoken,
+		arg.Refreshtoken,
+		arg.CreatedAt,
+		arg.LastSeenAt,
+		arg.ExpiresAt,
+		arg.Ip,
+		arg.UserAgent,
+		arg.Username,
+	)
+	return err
+}
+
+const setSessionUid = `-- name: SetSessionUid :exec
+UPDATE sessions
+SET uid = (SELECT id FROM users WHERE username = ?)
+WHERE accessToken = ? AND refreshToken = ?
+`
+
+type SetSessionUidParams struct {
+	Username     string
+	Accesstoken  string
+	Refreshtoken string
+}
+
+func (q *Queries) SetSessionUid(ctx context.Context, arg SetSessionUidParams) error {
+	_, err := q.db.ExecContext(ctx, setSessionUid, arg.Username, arg.Accesstoken, arg.Refreshtoken)
+	return err
+}
+
+const touchUserSession = `-- name: TouchUserSession :exec
+UPDATE sessions
+SET last_seen_at = ?,
+    ip = ?,
+    user_agent = ?
+WHERE id = ? AND last_seen_at < ?
+`
+
+type TouchUserSessionParams struct {
+	Now       int64
+	Ip        string
+	UserAgent string
+	ID        int64
+	Since     int64
+}
+
+func (q *Queries) TouchUserSession(ctx context.Context, arg TouchUserSessionParams) error {
+	_, err := q.db.ExecContext(ctx, touchUserSession,
+		arg.Now,
+		arg.Ip,
+		arg.UserAgent,
+		arg.ID,
+		arg.Since,
+	)
 	return err
 }
 
@@ -33,57 +33,8 @@ func (q *Queries) CanResetPassword(ctx context.Context, arg CanResetPasswordPara
 	return i, err
 }
 
-const checkValidApiKey = `-- name: CheckValidApiKey :one
-SELECT EXISTS (
-    SELECT 1
-    FROM apikeys
-    WHERE key = ?
-) as valid
-`
-
-func (q *Queries) CheckValidApiKey(ctx context.Context, key string) (int64, error) {
-	row := q.db.QueryRowContext(ctx, checkValidApiKey, key)
-	var valid int64
-	err := row.Scan(&valid)
-	return valid, err
-}
-
-const getApiKeysForUid = `-- name: GetApiKeysForUid :many
-SELECT key, name
-FROM apikeys
-WHERE uid = ?
-`
-
-type GetApiKeysForUidRow struct {
-	Key  string
-	Name string
-}
-
-func (q *Queries) GetApiKeysForUid(ctx context.Context, uid sql.NullInt64) ([]GetApiKeysForUidRow, error) {
-	rows, err := q.db.QueryContext(ctx, getApiKeysForUid, uid)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	var items []GetApiKeysForUidRow
-	for rows.Next() {
-		var i GetApiKeysForUidRow
-		if err := rows.Scan(&i.Key, &i.Name); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Close(); err != nil {
-		return nil, err
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return items, nil
-}
-
 const getUser = `-- name: GetUser :one
-SELECT id, username
+SELECT id, username, timezone
 FROM users
 WHERE username = ?
 `
@@ -91,28 +42,32 @@ WHERE username = ?
 type GetUserRow struct {
 	ID       int64
 	Username string
+	Timezone string
 }
 
 func (q *Queries) GetUser(ctx context.Context, username string) (GetUserRow, error) {
 	row := q.db.QueryRowContext(ctx, getUser, username)
 	var i GetUserRow
-	err := row.Scan(&i.ID, &i.Username)
+	err := row.Scan(&i.ID, &i.Username, &i.Timezone)
 	return i, err
 }
 
-const getUserFromApiKey = `-- name: GetUserFromApiKey :one
-SELECT username
+const getUserEmail = `-- name: GetUserEmail :one
+SELECT email, email_verified_at
 FROM users
-JOIN apikeys
-ON users.id = apikeys.uid
-WHERE apikeys.key = ?
+WHERE id = ?
 `
 
-func (q *Queries) GetUserFromApiKey(ctx context.Context, key string) (string, error) {
-	row := q.db.QueryRowContext(ctx, getUserFromApiKey, key)
-	var username string
-	err := row.Scan(&username)
-	return username, err
+type GetUserEmailRow struct {
+	Email           sql.NullString
+	EmailVerifiedAt sql.NullInt64
+}
+
+func (q *Queries) GetUserEmail(ctx context.Context, id int64) (GetUserEmailRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserEmail, id)
+	var i GetUserEmailRow
+	err := row.Scan(&i.Email, &i.EmailVerifiedAt)
+	return i, err
 }
 
 const getUserWithPassword = `-- name: GetUserWithPassword :one
@@ -133,39 +88,45 @@ func (q *Queries) GetUserWithPassword(ctx context.Context, username string) (Get
 	return i, err
 }
 
-const resetPassword = `-- name: ResetPassword :exec
+const getVerifiedEmail = `-- name: GetVerifiedEmail :one
+SELECT email
+FROM users
+WHERE username = ? AND email_verified_at IS NOT NULL
+`
+
+func (q *Queries) GetVerifiedEmail(ctx context.Context, username string) (sql.NullString, error) {
+	row := q.db.QueryRowContext(ctx, getVerifiedEmail, username)
+	var email sql.NullString
+	err := row.Scan(&email)
+	return email, err
+}
+
+const resetPassword = `-- name: ResetPassword :one
 UPDATE users
 SET reset = NULL,
     reset_time = NULL,
     password = ?
-WHERE reset = ? AND reset_time > ?
+WHERE username = ? AND reset = ? AND reset_time > ?
+RETURNING id
 `
 
 type ResetPasswordParams struct {
 	Password  interface{}
+	Username  string
 	Reset     sql.NullString
 	ResetTime sql.NullInt64
 }
 
-func (q *Queries) ResetPassword(ctx context.Context, arg ResetPasswordParams) error {
-	_, err := q.db.ExecContext(ctx, resetPassword, arg.Password, arg.Reset, arg.ResetTime)
-	return err
-}
-
-const saveApiKey = `-- name: SaveApiKey :exec
-INSERT INTO apikeys(name, key, uid)
-VALUES(?, ?, ?)
-`
-
-type SaveApiKeyParams struct {
-	Name string
-	Key  string
-	Uid  sql.NullInt64
-}
-
-func (q *Queries) SaveApiKey(ctx context.Context, arg SaveApiKeyParams) error {
-	_, err := q.db.ExecContext(ctx, saveApiKey, arg.Name, arg.Key, arg.Uid)
-	return err
+func (q *Queries) ResetPassword(ctx context.Context, arg ResetPasswordParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, resetPassword,
+		arg.Password,
+		arg.Username,
+		arg.Reset,
+		arg.ResetTime,
+	)
+	var iD int64
+	err := row.Scan(&iD)
+	return iD, err
 }
 
 const saveUser = `-- name: SaveUser :exec
@@ -200,3 +161,67 @@ func (q *Queries) SetPasswordReset(ctx context.Context, arg SetPasswordResetPara
 	_, err := q.db.ExecContext(ctx, setPasswordReset, arg.Reset, arg.ResetTime, arg.Username)
 	return err
 }
+
+const setUserEmail = `-- name: SetUserEmail :exec
+UPDATE users
+SET email = ?,
+    email_verified_at = NULL,
+    email_verification = ?,
+    email_verification_time = ?
+WHERE id = ?
+`
+
+type SetUserEmailParams struct {
+	Email                 sql.NullString
+	EmailVerification     sql.NullString
+	EmailVerificationTime sql.NullInt64
+	ID                    int64
+}
+
+func (q *Queries) SetUserEmail(ctx context.Context, arg SetUserEmailParams) error {
+	_, err := q.db.ExecContext(ctx, setUserEmail,
+		arg.Email,
+		arg.EmailVerification,
+		arg.EmailVerificationTime,
+		arg.ID,
+	)
+	return err
+}
+
+const setUserTimezone = `-- name: SetUserTimezone :exec
+UPDATE users
+SET timezone = ?
+WHERE id = ?
+`
+
+type SetUserTimezoneParams struct {
+	Timezone string
+	ID       int64
+}
+
+func (q *Queries) SetUserTimezone(ctx context.Context, arg SetUserTimezoneParams) error {
+	_, err := q.db.ExecContext(ctx, setUserTimezone, arg.Timezone, arg.ID)
+	return err
+}
+
+const verifyEmail = `-- name: VerifyEmail :execrows
+UPDATE users
+SET email_verified_at = ?,
+    email_verification = NULL,
+    email_verification_time = NULL
+WHERE email_verification = ? AND email_verification_time > ?
+`
+
+type VerifyEmailParams struct {
+	VerifiedAt sql.NullInt64
+	Token      sql.NullString
+	Now        sql.NullInt64
+}
+
+func (q *Queries) VerifyEmail(ctx context.Context, arg VerifyEmailParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, verifyEmail, arg.VerifiedAt, arg.Token, arg.Now)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
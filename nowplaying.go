@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	_ "time/tzdata"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
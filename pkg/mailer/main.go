@@ -0,0 +1,203 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+type Message struct {
+    To string
+    Subject string
+    Text string
+    HTML string
+}
+
+type Mailer interface {
+    Send(ctx context.Context, msg Message) error
+}
+
+// SMTP sends mail through a relay. Connections are upgraded with STARTTLS
+// whenever the server offers it, and credentials are only sent when a
+// username is set.
+type SMTP struct {
+    Host string
+    Port string
+    Username string
+    Password string
+    From string
+    Timeout time.Duration
+}
+
+func NewSMTP(host string, port string, username string, password string, from string) *SMTP {
+    if port == "" {
+        port = "587"
+    }
+
+    return &SMTP{
+        Host: host,
+        Port: port,
+        Username: username,
+        Password: password,
+        From: from,
+        Timeout: time.Second * 15,
+    }
+}
+
+func (m *SMTP) Send(ctx context.Context, msg Message) error {
+    from, err := mail.ParseAddress(m.From)
+    if err != nil {
+        return fmt.Errorf("mailer: from address: %w", err)
+    }
+
+    to, err := mail.ParseAddress(msg.To)
+    if err != nil {
+        return fmt.Errorf("mailer: to address: %w", err)
+    }
+
+    body, err := buildMessage(from, to, msg, time.Now())
+    if err != nil {
+        return err
+    }
+
+    ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+    defer cancel()
+
+    dialer := &net.Dialer{}
+    conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(m.Host, m.Port))
+    if err != nil {
+        return fmt.Errorf("mailer: dial: %w", err)
+    }
+
+    defer conn.Close()
+
+    if deadline, ok := ctx.Deadline(); ok {
+        conn.SetDeadline(deadline)
+    }
+
+    client, err := smtp.NewClient(conn, m.Host)
+    if err != nil {
+        return fmt.Errorf("mailer: %w", err)
+    }
+
+    defer client.Close()
+
+    if ok, _ := client.Extension("STARTTLS"); ok {
+        if err := client.StartTLS(&tls.Config{ ServerName: m.Host }); err != nil {
+            return fmt.Errorf("mailer: starttls: %w", err)
+        }
+    }
+
+    if m.Username != "" {
+        if err := client.Auth(smtp.PlainAuth("", m.Username, m.Password, m.Host)); err != nil {
+            return fmt.Errorf("mailer: auth: %w", err)
+        }
+    }
+
+    if err := client.Mail(from.Address); err != nil {
+        return fmt.Errorf("mailer: mail from: %w", err)
+    }
+
+    if err := client.Rcpt(to.Address); err != nil {
+        return fmt.Errorf("mailer: rcpt to: %w", err)
+    }
+
+    w, err := client.Data()
+    if err != nil {
+        return fmt.Errorf("mailer: data: %w", err)
+    }
+
+    if _, err := w.Write(body); err != nil {
+        return fmt.Errorf("mailer: writing message: %w", err)
+    }
+
+    if err := w.Close(); err != nil {
+        return fmt.Errorf("mailer: sending message: %w", err)
+    }
+
+    return client.Quit()
+}
+
+// Log writes messages to the log instead of sending them. It stands in when
+// no SMTP server is configured so links can still be followed in development.
+type Log struct{}
+
+func (Log) Send(ctx context.Context, msg Message) error {
+    log.Printf("Mail to %s: %s\n%s\n", msg.To, msg.Subject, msg.Text)
+    return nil
+}
+
+// buildMessage renders a multipart/alternative message with a plain text part
+// and, when there is one, an HTML part.
+func buildMessage(from *mail.Address, to *mail.Address, msg Message, now time.Time) ([]byte, error) {
+    var buf bytes.Buffer
+
+    header := func(key string, value string) {
+        fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+    }
+
+    id := make([]byte, 12)
+    if _, err := rand.Read(id); err != nil {
+        return nil, err
+    }
+
+    domain := from.Address[strings.LastIndex(from.Address, "@") + 1:]
+
+    header("From", from.String())
+    header("To", to.String())
+    header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+    header("Date", now.Format(time.RFC1123Z))
+    header("Message-ID", fmt.Sprintf("<%x@%s>", id, domain))
+    header("MIME-Version", "1.0")
+
+    if msg.HTML == "" {
+        header("Content-Type", "text/plain; charset=utf-8")
+        header("Content-Transfer-Encoding", "quoted-printable")
+        buf.WriteString("\r\n")
+
+        if err := writeQuoted(&buf, msg.Text); err != nil {
+            return nil, err
+        }
+
+        return buf.Bytes(), nil
+    }
+
+    boundary := fmt.Sprintf("np-%x", id)
+    header("Content-Type", fmt.Sprintf("multipart/alternative; boundary=%q", boundary))
+    buf.WriteString("\r\n")
+
+    for _, part := range []struct{ contentType, body string }{ { "text/plain", msg.Text }, { "text/html", msg.HTML } } {
+        fmt.Fprintf(&buf, "--%s\r\n", boundary)
+        header("Content-Type", part.contentType + "; charset=utf-8")
+        header("Content-Transfer-Encoding", "quoted-printable")
+        buf.WriteString("\r\n")
+
+        if err := writeQuoted(&buf, part.body); err != nil {
+            return nil, err
+        }
+
+        buf.WriteString("\r\n")
+    }
+
+    fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+    return buf.Bytes(), nil
+}
+
+func writeQuoted(buf *bytes.Buffer, body string) error {
+    w := quotedprintable.NewWriter(buf)
+    if _, err := w.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n"))); err != nil {
+        return err
+    }
+
+    return w.Close()
+}
@@ -0,0 +1,160 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+type sink struct {
+    from string
+    to []string
+    data string
+}
+
+// serveSMTP accepts one connection and speaks just enough SMTP to take a
+// message, like a local mail sink would.
+func serveSMTP(t *testing.T, ln net.Listener, got chan<- sink) {
+    conn, err := ln.Accept()
+    if err != nil {
+        return
+    }
+
+    defer conn.Close()
+
+    r := bufio.NewReader(conn)
+    reply := func(line string) { io.WriteString(conn, line + "\r\n") }
+    msg := sink{}
+
+    reply("220 sink ready")
+    for {
+        line, err := r.ReadString('\n')
+        if err != nil {
+            t.Errorf("reading command: %s", err)
+            return
+        }
+
+        cmd := strings.TrimSpace(line)
+        switch {
+        case strings.HasPrefix(cmd, "EHLO"):
+            reply("250-sink")
+            reply("250 8BITMIME")
+        case strings.HasPrefix(cmd, "MAIL FROM:"):
+            msg.from = strings.Trim(strings.Fields(cmd[len("MAIL FROM:"):])[0], "<>")
+            reply("250 ok")
+        case strings.HasPrefix(cmd, "RCPT TO:"):
+            msg.to = append(msg.to, strings.Trim(cmd[len("RCPT TO:"):], "<>"))
+            reply("250 ok")
+        case cmd == "DATA":
+            reply("354 go ahead")
+
+            var data strings.Builder
+            for {
+                line, err := r.ReadString('\n')
+                if err != nil {
+                    t.Errorf("reading data: %s", err)
+                    return
+                }
+
+                if line == ".\r\n" {
+                    break
+                }
+
+                data.WriteString(strings.TrimPrefix(line, "."))
+            }
+
+            msg.data = data.String()
+            reply("250 queued")
+        case cmd == "QUIT":
+            reply("221 bye")
+            got <- msg
+            return
+        default:
+            reply("502 unsupported")
+        }
+    }
+}
+
+func TestSMTPSend(t *testing.T) {
+    ln, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    defer ln.Close()
+
+    got := make(chan sink, 1)
+    go serveSMTP(t, ln, got)
+
+    host, port, _ := net.SplitHostPort(ln.Addr().String())
+    m := NewSMTP(host, port, "", "", "Now Playing <noreply@example.com>")
+
+    err = m.Send(context.Background(), Message{
+        To: "tester@example.com",
+        Subject: "Reset your password",
+        Text: "Reset it here: https://example.com/reset/abc\n",
+        HTML: "<p><a href=\"https://example.com/reset/abc\">Reset it here</a></p>",
+    })
+
+    if err != nil {
+        t.Fatalf("sending: %s", err)
+    }
+
+    msg := <- got
+    if msg.from != "noreply@example.com" || len(msg.to) != 1 || msg.to[0] != "tester@example.com" {
+        t.Fatalf("envelope: got %s -> %v", msg.from, msg.to)
+    }
+
+    parsed, err := mail.ReadMessage(strings.NewReader(msg.data))
+    if err != nil {
+        t.Fatalf("parsing message: %s", err)
+    }
+
+    if subject := parsed.Header.Get("Subject"); subject != "Reset your password" {
+        t.Errorf("subject: got %q", subject)
+    }
+
+    mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+    if err != nil || mediaType != "multipart/alternative" {
+        t.Fatalf("content type: got %q", parsed.Header.Get("Content-Type"))
+    }
+
+    parts := map[string]string{}
+    reader := multipart.NewReader(parsed.Body, params["boundary"])
+    for {
+        part, err := reader.NextRawPart()
+        if err == io.EOF {
+            break
+        }
+
+        if err != nil {
+            t.Fatalf("reading part: %s", err)
+        }
+
+        body, _ := io.ReadAll(quotedprintable.NewReader(part))
+        contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+        parts[contentType] = string(body)
+    }
+
+    if !strings.Contains(parts["text/plain"], "https://example.com/reset/abc") {
+        t.Errorf("text part: got %q", parts["text/plain"])
+    }
+
+    if !strings.Contains(parts["text/html"], `href="https://example.com/reset/abc"`) {
+        t.Errorf("html part: got %q", parts["text/html"])
+    }
+}
+
+func TestSMTPRejectsBadAddress(t *testing.T) {
+    m := NewSMTP("127.0.0.1", "1", "", "", "noreply@example.com")
+    if err := m.Send(context.Background(), Message{ To: "not an address" }); err == nil {
+        t.Errorf("expected an error for a bad recipient")
+    }
+}
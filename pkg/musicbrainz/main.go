@@ -0,0 +1,191 @@
+package musicbrainz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+    DefaultURL = "https://musicbrainz.org"
+    // MusicBrainz allows one request per second per client.
+    DefaultInterval = time.Second
+    minScore = 90
+)
+
+var (
+    ErrNotFound = errors.New("musicbrainz: no matching recording")
+    ErrRateLimited = errors.New("musicbrainz: rate limited")
+)
+
+type Client struct {
+    BaseURL string
+    UserAgent string
+    Interval time.Duration
+    http *http.Client
+    last time.Time
+    mu sync.Mutex
+}
+
+type Recording struct {
+    Id string `json:"id"`
+    Title string `json:"title"`
+    Score int `json:"score"`
+    Length int `json:"length"`
+    ArtistCredit []struct {
+        Name string `json:"name"`
+        Artist struct {
+            Id string `json:"id"`
+            Name string `json:"name"`
+        } `json:"artist"`
+    } `json:"artist-credit"`
+    Releases []struct {
+        Id string `json:"id"`
+        Title string `json:"title"`
+    } `json:"releases"`
+}
+
+type recordingSearch struct {
+    Recordings []Recording `json:"recordings"`
+}
+
+// Match is the set of MBIDs a lookup resolved to. ReleaseId is empty when none
+// of the recording's releases matched the album asked for. Length is the
+// recording's length in milliseconds, or 0 when MusicBrainz doesn't know it.
+type Match struct {
+    RecordingId string
+    ReleaseId string
+    ArtistId string
+    Length int
+}
+
+func New(baseURL string, userAgent string) *Client {
+    if baseURL == "" {
+        baseURL = DefaultURL
+    }
+
+    return &Client{
+        BaseURL: strings.TrimSuffix(baseURL, "/"),
+        UserAgent: userAgent,
+        Interval: DefaultInterval,
+        http: &http.Client{ Timeout: time.Second * 15 },
+    }
+}
+
+// Lookup searches for a recording by artist and title and picks the release
+// that matches album, if one does. When nothing matches with the album in the
+// query it searches again without it.
+func (c *Client) Lookup(ctx context.Context, artist string, track string, album string) (Match, error) {
+    match, err := c.lookup(ctx, artist, track, album, album)
+    if err == ErrNotFound && album != "" {
+        return c.lookup(ctx, artist, track, "", album)
+    }
+
+    return match, err
+}
+
+func (c *Client) lookup(ctx context.Context, artist string, track string, queryAlbum string, album string) (Match, error) {
+    recordings, err := c.SearchRecordings(ctx, artist, track, queryAlbum)
+    if err != nil {
+        return Match{}, err
+    }
+
+    for _, rec := range recordings {
+        if rec.Score < minScore {
+            continue
+        }
+
+        match := Match{ RecordingId: rec.Id, Length: rec.Length }
+        if len(rec.ArtistCredit) > 0 {
+            match.ArtistId = rec.ArtistCredit[0].Artist.Id
+        }
+
+        for _, release := range rec.Releases {
+            if album != "" && strings.EqualFold(release.Title, album) {
+                match.ReleaseId = release.Id
+                break
+            }
+        }
+
+        return match, nil
+    }
+
+    return Match{}, ErrNotFound
+}
+
+func (c *Client) SearchRecordings(ctx context.Context, artist string, track string, album string) ([]Recording, error) {
+    query := fmt.Sprintf(`recording:"%s" AND artist:"%s"`, escape(track), escape(artist))
+    if album != "" {
+        query += fmt.Sprintf(` AND release:"%s"`, escape(album))
+    }
+
+    params := url.Values{}
+    params.Set("query", query)
+    params.Set("fmt", "json")
+    params.Set("limit", "5")
+
+    var data recordingSearch
+    if err := c.get(ctx, "/ws/2/recording?" + params.Encode(), &data); err != nil {
+        return nil, err
+    }
+
+    return data.Recordings, nil
+}
+
+func (c *Client) get(ctx context.Context, path string, v any) error {
+    if err := c.wait(ctx); err != nil {
+        return err
+    }
+
+    req, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL + path, nil)
+    if err != nil {
+        return err
+    }
+
+    req.Header.Set("User-Agent", c.UserAgent)
+    req.Header.Set("Accept", "application/json")
+
+    res, err := c.http.Do(req)
+    if err != nil {
+        return err
+    }
+
+    defer res.Body.Close()
+
+    switch res.StatusCode {
+    case http.StatusOK:
+        return json.NewDecoder(res.Body).Decode(v)
+    case http.StatusServiceUnavailable, http.StatusTooManyRequests:
+        return ErrRateLimited
+    }
+
+    return fmt.Errorf("musicbrainz: unexpected status %d", res.StatusCode)
+}
+
+// wait holds a request back until Interval has passed since the last one.
+func (c *Client) wait(ctx context.Context) error {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    if delay := time.Until(c.last.Add(c.Interval)); delay > 0 {
+        select {
+        case <- ctx.Done():
+            return ctx.Err()
+        case <- time.After(delay):
+        }
+    }
+
+    c.last = time.Now()
+    return nil
+}
+
+// escape quotes a value for a Lucene phrase query.
+func escape(value string) string {
+    return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+}
@@ -0,0 +1,112 @@
+package musicbrainz
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const searchResp = `{
+    "recordings": [{
+        "id": "rec-1",
+        "title": "Woodstock",
+        "score": 100,
+        "length": 232000,
+        "artist-credit": [{ "name": "Crosby, Stills, Nash & Young", "artist": { "id": "artist-1", "name": "Crosby, Stills, Nash & Young" } }],
+        "releases": [{ "id": "release-1", "title": "Greatest Hits" }, { "id": "release-2", "title": "Déjà Vu" }]
+    }]
+}`
+
+func TestLookup(t *testing.T) {
+    var queries []string
+
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path != "/ws/2/recording" || r.Header.Get("User-Agent") != "test/1.0" {
+            w.WriteHeader(http.StatusBadRequest)
+            return
+        }
+
+        query := r.URL.Query().Get("query")
+        queries = append(queries, query)
+
+        if strings.Contains(query, "release:") {
+            fmt.Fprint(w, `{ "recordings": [] }`)
+            return
+        }
+
+        fmt.Fprint(w, searchResp)
+    }))
+
+    defer srv.Close()
+
+    client := New(srv.URL, "test/1.0")
+    client.Interval = time.Millisecond
+
+    match, err := client.Lookup(context.Background(), "Crosby, Stills, Nash & Young", "Woodstock", "déjà vu")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    if match.RecordingId != "rec-1" || match.ArtistId != "artist-1" || match.ReleaseId != "release-2" || match.Length != 232000 {
+        t.Errorf("unexpected match: %+v", match)
+    }
+
+    if len(queries) != 2 {
+        t.Fatalf("expected a retry without the album, got %q", queries)
+    }
+
+    if want := `recording:"Woodstock" AND artist:"Crosby, Stills, Nash & Young" AND release:"déjà vu"`; queries[0] != want {
+        t.Errorf("query: got %q, want %q", queries[0], want)
+    }
+}
+
+func TestLookupErrors(t *testing.T) {
+    status := http.StatusServiceUnavailable
+
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if status != http.StatusOK {
+            w.WriteHeader(status)
+            return
+        }
+
+        fmt.Fprint(w, `{ "recordings": [{ "id": "rec-1", "score": 40 }] }`)
+    }))
+
+    defer srv.Close()
+
+    client := New(srv.URL, "test/1.0")
+    client.Interval = time.Millisecond
+
+    if _, err := client.Lookup(context.Background(), "Artist", "Track", ""); err != ErrRateLimited {
+        t.Errorf("expected ErrRateLimited, got %v", err)
+    }
+
+    status = http.StatusOK
+    if _, err := client.Lookup(context.Background(), "Artist", "Track", ""); err != ErrNotFound {
+        t.Errorf("expected ErrNotFound for a low score, got %v", err)
+    }
+}
+
+func TestRateLimit(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        fmt.Fprint(w, `{ "recordings": [] }`)
+    }))
+
+    defer srv.Close()
+
+    client := New(srv.URL, "test/1.0")
+    client.Interval = time.Millisecond * 50
+
+    start := time.Now()
+    for i := 0; i < 3; i++ {
+        client.SearchRecordings(context.Background(), "Artist", "Track", "")
+    }
+
+    if elapsed := time.Since(start); elapsed < time.Millisecond * 100 {
+        t.Errorf("expected requests to be spaced out, took %s", elapsed)
+    }
+}
@@ -1,89 +1,137 @@
 package spotup
 
 import (
-	"context"
-	"database/sql"
+	"archive/zip"
+	"bytes"
 	"encoding/json"
 	"fmt"
-	"log"
-	"log/slog"
+	"io"
 	"os"
-	"path/filepath"
+	"path"
+	"strings"
 	"time"
-
-	"github.com/cg219/nowplaying/internal/database"
-	_ "github.com/tursodatabase/libsql-client-go/libsql"
 )
 
+// Entry is a single play from Spotify's extended streaming history export.
 type Entry struct {
     Timestamp string `json:"ts"`
+    Platform string `json:"platform"`
+    MsPlayed int `json:"ms_played"`
     Track string `json:"master_metadata_track_name"`
     Artist string `json:"master_metadata_album_artist_name"`
     Album string `json:"master_metadata_album_album_name"`
+    TrackURI string `json:"spotify_track_uri"`
+    ReasonStart string `json:"reason_start"`
+    ReasonEnd string `json:"reason_end"`
+    Skipped bool `json:"skipped"`
 }
 
-func Run() {
-    slog.Info("Starting Spotify Upload")
-    if len(os.Args) == 1 {
-        log.Fatal("please add path to spotify export")
-    }
-
-    pathtofile := os.Args[1]
-
-    slog.Info("File to read", "file", pathtofile)
-    slog.Info("Reading file into memory")
-
-    cwd, _ := os.Getwd()
-    data, err := os.ReadFile(filepath.Join(cwd, pathtofile))
+// Ended is the time playback stopped, which is what Spotify records in ts.
+func (e Entry) Ended() (time.Time, error) {
+    return time.Parse(time.RFC3339, e.Timestamp)
+}
 
+// Started backs ms_played off the end time to get when the play began.
+func (e Entry) Started() (time.Time, error) {
+    ended, err := e.Ended()
     if err != nil {
-        log.Fatalf("Error Reading File: %s\n", err)
+        return ended, err
     }
 
-    slog.Info("Connecting to database")
+    return ended.Add(-time.Duration(e.MsPlayed) * time.Millisecond), nil
+}
 
-    conn := fmt.Sprintf("%s?authToken=%s", os.Getenv("TURSO_URL"), os.Getenv("TURSO_TOKEN"))
-    db, _ := sql.Open("libsql", conn)
-    defer db.Close()
+// IsMusic reports whether the entry is a song rather than a podcast episode or
+// audiobook chapter, which share the same export files.
+func (e Entry) IsMusic() bool {
+    return e.Track != "" && e.Artist != ""
+}
 
-    storage := database.New(db)
+// Finished reports whether the track played through to the end.
+func (e Entry) Finished() bool {
+    return e.ReasonEnd == "trackdone"
+}
 
-    slog.Info("Decoding Json")
+// ReadJSON decodes one Streaming_History_Audio_*.json file.
+func ReadJSON(r io.Reader) ([]Entry, error) {
+    var entries []Entry
+    if err := json.NewDecoder(r).Decode(&entries); err != nil {
+        return nil, fmt.Errorf("decoding history: %w", err)
+    }
 
+    return entries, nil
+}
 
-    var structured []Entry
-    if err := json.Unmarshal(data, &structured); err != nil {
-        log.Fatal("Error Unmarshalling")
+// ReadZip reads every audio history file in the export archive. Video history
+// and the other documents Spotify bundles with the export are ignored.
+func ReadZip(r io.ReaderAt, size int64) ([]Entry, error) {
+    archive, err := zip.NewReader(r, size)
+    if err != nil {
+        return nil, fmt.Errorf("opening export: %w", err)
     }
 
-    slog.Info("Storing...", "database", db)
+    entries := []Entry{}
 
-    for _, e := range structured {
-        if e.Track == "" {
+    for _, f := range archive.File {
+        if !isHistoryFile(f.Name) {
             continue
         }
 
-        parsed, err := time.Parse(time.RFC3339, e.Timestamp)
+        rc, err := f.Open()
         if err != nil {
-            slog.Error("ERROR")
-        } 
-        slog.Info("Entry", "song", e.Track, "artist", e.Artist, "album", e.Album, "timestamp", parsed.Unix())
+            return nil, fmt.Errorf("opening %s: %w", f.Name, err)
+        }
 
-        err = storage.AddToHistory(context.Background(), database.AddToHistoryParams{
-            ArtistName: e.Artist,
-            TrackName: e.Track,
-            AlbumName: sql.NullString{ String: e.Album, Valid: e.Album != "" },
-            Timestamp: parsed.UnixMilli(),
-        })
+        list, err := ReadJSON(rc)
+        rc.Close()
 
         if err != nil {
-            slog.Error("Error storing", "err", err)
+            return nil, fmt.Errorf("%s: %w", f.Name, err)
         }
+
+        entries = append(entries, list...)
+    }
+
+    return entries, nil
+}
+
+// Read accepts either a zip export or a single history JSON file of the given
+// size. Nothing is buffered beyond what the zip reader or decoder needs.
+func Read(r io.ReaderAt, size int64) ([]Entry, error) {
+    magic := make([]byte, 2)
+    if _, err := r.ReadAt(magic, 0); err != nil && err != io.EOF {
+        return nil, err
+    }
+
+    if bytes.Equal(magic, []byte("PK")) {
+        return ReadZip(r, size)
+    }
+
+    return ReadJSON(io.NewSectionReader(r, 0, size))
+}
+
+func ReadFile(name string) ([]Entry, error) {
+    f, err := os.Open(name)
+    if err != nil {
+        return nil, err
+    }
+
+    defer f.Close()
+
+    info, err := f.Stat()
+    if err != nil {
+        return nil, err
     }
 
-    slog.Info("Transferring to scrobbles...", "database", db)
+    return Read(f, info.Size())
+}
+
+func isHistoryFile(name string) bool {
+    base := path.Base(name)
 
-    // storage.HistoryToScrobbles(context.Background())
+    if !strings.HasSuffix(strings.ToLower(base), ".json") {
+        return false
+    }
 
-    slog.Info("Completed")
+    return strings.HasPrefix(base, "Streaming_History_Audio") || strings.HasPrefix(base, "endsong")
 }
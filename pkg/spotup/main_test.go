@@ -0,0 +1,70 @@
+package spotup
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const history = `[
+    {"ts": "2023-04-01T12:03:30Z", "platform": "ios", "ms_played": 210000, "master_metadata_track_name": "Song", "master_metadata_album_artist_name": "Artist", "master_metadata_album_album_name": "Album", "spotify_track_uri": "spotify:track:abc", "reason_end": "trackdone"},
+    {"ts": "2023-04-01T12:04:00Z", "platform": "ios", "ms_played": 30000, "master_metadata_track_name": null, "master_metadata_album_artist_name": null, "episode_name": "Podcast", "reason_end": "endplay"}
+]`
+
+func TestReadZip(t *testing.T) {
+    buf := new(bytes.Buffer)
+    w := zip.NewWriter(buf)
+
+    for _, name := range []string{ "Spotify Extended Streaming History/Streaming_History_Audio_2023_0.json", "Spotify Extended Streaming History/Streaming_History_Video_2023.json" } {
+        f, err := w.Create(name)
+        if err != nil {
+            t.Fatalf("Oops: %s\n", err)
+        }
+
+        f.Write([]byte(history))
+    }
+
+    w.Close()
+
+    entries, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+    if err != nil {
+        t.Fatalf("Oops: %s\n", err)
+    }
+
+    if len(entries) != 2 {
+        t.Fatalf("Entries: got %d, want 2", len(entries))
+    }
+
+    if !entries[0].IsMusic() || entries[1].IsMusic() {
+        t.Fatalf("IsMusic: %v, %v", entries[0].IsMusic(), entries[1].IsMusic())
+    }
+
+    started, err := entries[0].Started()
+    if err != nil {
+        t.Fatalf("Oops: %s\n", err)
+    }
+
+    if started.Format("15:04:05") != "12:00:00" {
+        t.Fatalf("Started: got %s", started)
+    }
+
+    if !entries[0].Finished() || entries[0].TrackURI != "spotify:track:abc" {
+        t.Fatalf("Entry: %+v", entries[0])
+    }
+}
+
+func TestReadJSON(t *testing.T) {
+    entries, err := Read(strings.NewReader(history), int64(len(history)))
+    if err != nil {
+        t.Fatalf("Oops: %s\n", err)
+    }
+
+    if len(entries) != 2 || entries[0].Track != "Song" {
+        t.Fatalf("Entries: %+v", entries)
+    }
+
+    if _, err := Read(strings.NewReader("PK not a zip"), 12); err == nil {
+        t.Fatal("expected an error for a broken zip")
+    }
+}
@@ -0,0 +1,177 @@
+package webtoken
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+    ErrNoActiveKey = errors.New("webtoken: no active signing key")
+    ErrUnknownKey = errors.New("webtoken: unknown key id")
+)
+
+// Key is an HMAC secret identified by the kid header of the tokens it signs.
+// A retired key no longer signs but still verifies until it is removed.
+type Key struct {
+    Id string `yaml:"id"`
+    Secret string `yaml:"secret"`
+    CreatedAt time.Time `yaml:"created"`
+    RetiredAt time.Time `yaml:"retired,omitempty"`
+}
+
+// KeySet holds every key tokens may be verified with. The first key that
+// isn't retired signs new tokens.
+type KeySet struct {
+    Keys []Key `yaml:"keys"`
+}
+
+func NewKey(now time.Time) (Key, error) {
+    id := make([]byte, 4)
+    secret := make([]byte, 32)
+
+    if _, err := rand.Read(id); err != nil {
+        return Key{}, err
+    }
+
+    if _, err := rand.Read(secret); err != nil {
+        return Key{}, err
+    }
+
+    return Key{
+        Id: fmt.Sprintf("%s-%x", now.UTC().Format("20060102"), id),
+        Secret: base64.RawURLEncoding.EncodeToString(secret),
+        CreatedAt: now.UTC(),
+    }, nil
+}
+
+func NewKeySet(keys ...Key) *KeySet {
+    return &KeySet{ Keys: keys }
+}
+
+// ParseKeySet reads a key set from YAML. It needs an active key, and every
+// key needs a unique id and a secret.
+func ParseKeySet(data []byte) (*KeySet, error) {
+    ks := &KeySet{}
+
+    if err := yaml.Unmarshal(data, ks); err != nil {
+        return nil, err
+    }
+
+    seen := map[string]bool{}
+    for _, key := range ks.Keys {
+        if key.Id == "" || key.Secret == "" {
+            return nil, fmt.Errorf("webtoken: key is missing an id or secret")
+        }
+
+        if seen[key.Id] {
+            return nil, fmt.Errorf("webtoken: duplicate key id %s", key.Id)
+        }
+
+        seen[key.Id] = true
+    }
+
+    if _, err := ks.Active(); err != nil {
+        return nil, err
+    }
+
+    return ks, nil
+}
+
+func (ks *KeySet) Marshal() ([]byte, error) {
+    return yaml.Marshal(ks)
+}
+
+func (ks *KeySet) Active() (Key, error) {
+    for _, key := range ks.Keys {
+        if key.RetiredAt.IsZero() {
+            return key, nil
+        }
+    }
+
+    return Key{}, ErrNoActiveKey
+}
+
+func (ks *KeySet) Lookup(id string) (Key, bool) {
+    for _, key := range ks.Keys {
+        if key.Id == id {
+            return key, true
+        }
+    }
+
+    return Key{}, false
+}
+
+// Rotate adds a new active key and retires the rest. Tokens signed with the
+// retired keys keep verifying until Prune or Remove drops them.
+func (ks *KeySet) Rotate(now time.Time) (Key, error) {
+    key, err := NewKey(now)
+    if err != nil {
+        return key, err
+    }
+
+    for i := range ks.Keys {
+        if ks.Keys[i].RetiredAt.IsZero() {
+            ks.Keys[i].RetiredAt = now.UTC()
+        }
+    }
+
+    ks.Keys = append([]Key{ key }, ks.Keys...)
+    return key, nil
+}
+
+// Prune removes keys retired before cutoff and returns them.
+func (ks *KeySet) Prune(cutoff time.Time) []Key {
+    kept := []Key{}
+    removed := []Key{}
+
+    for _, key := range ks.Keys {
+        if !key.RetiredAt.IsZero() && key.RetiredAt.Before(cutoff) {
+            removed = append(removed, key)
+            continue
+        }
+
+        kept = append(kept, key)
+    }
+
+    ks.Keys = kept
+    return removed
+}
+
+// Remove drops a key right away, logging out everyone holding a token it
+// signed. The active key can't be removed; rotate first.
+func (ks *KeySet) Remove(id string) error {
+    active, err := ks.Active()
+    if err == nil && active.Id == id {
+        return fmt.Errorf("webtoken: %s is the active key", id)
+    }
+
+    for i, key := range ks.Keys {
+        if key.Id == id {
+            ks.Keys = append(ks.Keys[:i], ks.Keys[i + 1:]...)
+            return nil
+        }
+    }
+
+    return ErrUnknownKey
+}
+
+// Keyfunc picks the verification secret from a token's kid header.
+func (ks *KeySet) Keyfunc(t *jwt.Token) (interface{}, error) {
+    if t.Method != jwt.SigningMethodHS256 {
+        return nil, fmt.Errorf("webtoken: unexpected signing method %s", t.Method.Alg())
+    }
+
+    id, _ := t.Header["kid"].(string)
+    key, ok := ks.Lookup(id)
+    if !ok {
+        return nil, ErrUnknownKey
+    }
+
+    return []byte(key.Secret), nil
+}
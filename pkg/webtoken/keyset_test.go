@@ -0,0 +1,117 @@
+package webtoken
+
+import (
+	"testing"
+	"time"
+)
+
+func sign(t *testing.T, keys *KeySet, subject string) string {
+    key, err := keys.Active()
+    if err != nil {
+        t.Fatalf("active key: %s", err)
+    }
+
+    token := NewToken("accessToken", subject, key, time.Now().Add(time.Hour))
+    if err := token.Create("nowplaying"); err != nil {
+        t.Fatalf("creating token: %s", err)
+    }
+
+    return token.Value()
+}
+
+func TestKeyRotation(t *testing.T) {
+    now := time.Now()
+    first, err := NewKey(now.Add(-time.Hour * 24 * 60))
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    keys := NewKeySet(first)
+    old := sign(t, keys, "tester")
+
+    if _, err := keys.Rotate(now.Add(-time.Hour * 24 * 40)); err != nil {
+        t.Fatal(err)
+    }
+
+    second := sign(t, keys, "tester")
+
+    secondKey, _ := keys.Active()
+    if secondKey.Id == first.Id {
+        t.Fatalf("rotate kept %s active", first.Id)
+    }
+
+    for _, value := range []string{ old, second } {
+        if _, err := GetParsedJWT(value, keys); err != nil {
+            t.Errorf("token signed before rotation stopped verifying: %s", err)
+        }
+    }
+
+    if _, err := keys.Rotate(now); err != nil {
+        t.Fatal(err)
+    }
+
+    removed := keys.Prune(now.Add(-time.Hour * 24 * 30))
+    if len(removed) != 1 || removed[0].Id != first.Id {
+        t.Fatalf("expected %s to be pruned, got %+v", first.Id, removed)
+    }
+
+    if _, err := GetParsedJWT(old, keys); err == nil {
+        t.Errorf("token signed with a pruned key still verifies")
+    }
+
+    if _, err := GetParsedJWT(second, keys); err != nil {
+        t.Errorf("token signed with a retired key stopped verifying: %s", err)
+    }
+
+    if err := keys.Remove(secondKey.Id); err != nil {
+        t.Fatal(err)
+    }
+
+    if _, err := GetParsedJWT(second, keys); err == nil {
+        t.Errorf("token signed with a removed key still verifies")
+    }
+
+    active, _ := keys.Active()
+    if err := keys.Remove(active.Id); err == nil {
+        t.Errorf("removed the active key")
+    }
+}
+
+func TestParseKeySet(t *testing.T) {
+    keys := NewKeySet()
+    if _, err := keys.Rotate(time.Now()); err != nil {
+        t.Fatal(err)
+    }
+
+    if _, err := keys.Rotate(time.Now()); err != nil {
+        t.Fatal(err)
+    }
+
+    data, err := keys.Marshal()
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    parsed, err := ParseKeySet(data)
+    if err != nil {
+        t.Fatalf("parsing %s: %s", data, err)
+    }
+
+    token := sign(t, keys, "tester")
+    if _, err := GetParsedJWT(token, parsed); err != nil {
+        t.Errorf("parsed key set can't verify: %s", err)
+    }
+
+    invalid := []string{
+        "keys: []",
+        "keys:\n  - id: a\n    secret: s\n    retired: 2025-01-01T00:00:00Z",
+        "keys:\n  - id: a\n    secret: s\n  - id: a\n    secret: t",
+        "keys:\n  - id: a",
+    }
+
+    for _, value := range invalid {
+        if _, err := ParseKeySet([]byte(value)); err == nil {
+            t.Errorf("expected an error parsing %q", value)
+        }
+    }
+}
@@ -18,7 +18,7 @@ type Token struct {
     expiresAt time.Time
     Name string
     value string
-    secret string
+    key Key
 }
 
 type Subject struct {
@@ -30,12 +30,12 @@ type CookieAuthValue struct {
     RefreshToken string `json:"refresh"`
 }
 
-func NewToken(name, subject, secret string, expires time.Time) Token {
+func NewToken(name, subject string, key Key, expires time.Time) Token {
     return Token{
         Name: name,
         subject: subject,
         expiresAt: expires,
-        secret: secret,
+        key: key,
     }
 }
 
@@ -70,7 +70,7 @@ func (t *Token) Value() string {
 }
 
 func (t *Token) Secret() string {
-    return t.secret
+    return t.key.Secret
 }
 
 func (t *Token) Subject() string {
@@ -90,8 +90,8 @@ func GenerateRefreshString() string {
 }
 
 // TODO: Add ability to pass custom claims
-func GetParsedJWT(value string, secret string) (*jwt.Token, error) {
-    token, err := jwt.ParseWithClaims(value, &jwt.RegisteredClaims{}, func(t *jwt.Token) (interface{}, error) { return []byte(secret), nil })
+func GetParsedJWT(value string, keys *KeySet) (*jwt.Token, error) {
+    token, err := jwt.ParseWithClaims(value, &jwt.RegisteredClaims{}, keys.Keyfunc)
     if err != nil {
         return token, err
     }
@@ -116,7 +116,8 @@ func (t *Token) Create(issuer string) error {
         Subject: string(encodedSubject),
     })
 
-    stoken, err := token.SignedString([]byte(t.secret))
+    token.Header["kid"] = t.key.Id
+    stoken, err := token.SignedString([]byte(t.key.Secret))
 
     if err != nil {
         return fmt.Errorf("Error creating %s token. val: %v, err %s", cmp.Or(t.Name, "new"), t.subject, err)
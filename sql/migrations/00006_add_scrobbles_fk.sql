@@ -18,7 +18,7 @@ CREATE TABLE scrobbles_new (
 );
 
 INSERT INTO scrobbles_new(id, artist_name, track_name, album_name, album_artist, track_number, duration, timestamp, source, mbid, uid)
-SELECT id, artist_name, track_name, album_name, album_artist, track_number, duration, timestamp, source, mbid
+SELECT id, artist_name, track_name, album_name, album_artist, track_number, duration, timestamp, source, mbid, 1
 FROM scrobbles;
 
 DROP TABLE scrobbles;
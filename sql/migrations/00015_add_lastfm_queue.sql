@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE lastfm_queue (
+    id INTEGER PRIMARY KEY,
+    uid INTEGER NOT NULL,
+    artist_name TEXT NOT NULL,
+    track_name TEXT NOT NULL,
+    album_name TEXT,
+    album_artist TEXT,
+    track_number TEXT,
+    mbid TEXT,
+    duration INTEGER NOT NULL,
+    timestamp INTEGER NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt INTEGER NOT NULL,
+    last_error TEXT,
+    CONSTRAINT fk_users
+    FOREIGN KEY(uid)
+    REFERENCES users(id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE lastfm_queue;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE lastfm_imports (
+    uid INTEGER PRIMARY KEY,
+    status TEXT NOT NULL,
+    page INTEGER NOT NULL DEFAULT 1,
+    total_pages INTEGER NOT NULL DEFAULT 0,
+    imported INTEGER NOT NULL DEFAULT 0,
+    skipped INTEGER NOT NULL DEFAULT 0,
+    since INTEGER NOT NULL DEFAULT 0,
+    until INTEGER NOT NULL,
+    started_at INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL,
+    error TEXT,
+    CONSTRAINT fk_users
+    FOREIGN KEY(uid)
+    REFERENCES users(id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE lastfm_imports;
+-- +goose StatementEnd
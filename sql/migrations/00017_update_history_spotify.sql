@@ -0,0 +1,40 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE history_spotify
+ADD COLUMN ms_played INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE history_spotify
+ADD COLUMN platform TEXT;
+
+ALTER TABLE history_spotify
+ADD COLUMN track_uri TEXT;
+
+ALTER TABLE history_spotify
+ADD COLUMN reason_end TEXT;
+
+ALTER TABLE history_spotify
+ADD COLUMN uid INTEGER REFERENCES users(id);
+
+CREATE UNIQUE INDEX history_spotify_play
+ON history_spotify(uid, timestamp, track_name);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX history_spotify_play;
+
+ALTER TABLE history_spotify
+DROP COLUMN uid;
+
+ALTER TABLE history_spotify
+DROP COLUMN reason_end;
+
+ALTER TABLE history_spotify
+DROP COLUMN track_uri;
+
+ALTER TABLE history_spotify
+DROP COLUMN platform;
+
+ALTER TABLE history_spotify
+DROP COLUMN ms_played;
+-- +goose StatementEnd
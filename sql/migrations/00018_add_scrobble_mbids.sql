@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE scrobbles
+ADD COLUMN album_mbid TEXT;
+
+ALTER TABLE scrobbles
+ADD COLUMN artist_mbid TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE scrobbles
+DROP COLUMN album_mbid;
+
+ALTER TABLE scrobbles
+DROP COLUMN artist_mbid;
+-- +goose StatementEnd
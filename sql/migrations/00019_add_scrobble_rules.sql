@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE scrobble_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    uid INTEGER NOT NULL,
+    source TEXT NOT NULL DEFAULT '',
+    mode TEXT NOT NULL DEFAULT 'seconds',
+    min_percent INTEGER NOT NULL DEFAULT 50,
+    min_seconds INTEGER NOT NULL DEFAULT 30,
+    max_track_seconds INTEGER NOT NULL DEFAULT 0,
+    count_repeats INTEGER NOT NULL DEFAULT 1,
+    UNIQUE(uid, source),
+    CONSTRAINT fk_users
+    FOREIGN KEY(uid)
+    REFERENCES users(id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE scrobble_rules;
+-- +goose StatementEnd
@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE scrobble_artists (
+    scrobble_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY(scrobble_id, name),
+    CONSTRAINT fk_scrobbles
+    FOREIGN KEY(scrobble_id)
+    REFERENCES scrobbles(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX scrobble_artists_name
+ON scrobble_artists(name);
+
+INSERT INTO scrobble_artists(scrobble_id, name, position)
+SELECT id, artist_name, 0
+FROM scrobbles;
+
+CREATE TABLE rewrite_rules (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    uid INTEGER NOT NULL,
+    field TEXT NOT NULL,
+    match_type TEXT NOT NULL DEFAULT 'exact',
+    pattern TEXT NOT NULL,
+    replacement TEXT NOT NULL,
+    CONSTRAINT fk_users
+    FOREIGN KEY(uid)
+    REFERENCES users(id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE rewrite_rules;
+
+DROP INDEX scrobble_artists_name;
+
+DROP TABLE scrobble_artists;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE musicbrainz_lookups (
+    artist_name TEXT NOT NULL,
+    track_name TEXT NOT NULL,
+    album_name TEXT NOT NULL DEFAULT '',
+    recording_mbid TEXT,
+    release_mbid TEXT,
+    artist_mbid TEXT,
+    found INTEGER NOT NULL DEFAULT 0,
+    looked_up_at INTEGER NOT NULL,
+    PRIMARY KEY(artist_name, track_name, album_name)
+);
+
+CREATE INDEX scrobbles_missing_mbid
+ON scrobbles(id)
+WHERE mbid IS NULL OR mbid = '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX scrobbles_missing_mbid;
+
+DROP TABLE musicbrainz_lookups;
+-- +goose StatementEnd
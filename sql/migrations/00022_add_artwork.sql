@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE artwork (
+    id INTEGER PRIMARY KEY,
+    kind TEXT NOT NULL,
+    artist_name TEXT NOT NULL COLLATE NOCASE,
+    name TEXT NOT NULL DEFAULT '' COLLATE NOCASE,
+    status TEXT NOT NULL DEFAULT 'pending',
+    source TEXT,
+    url TEXT,
+    image BLOB,
+    content_type TEXT,
+    fetched_at INTEGER NOT NULL DEFAULT 0,
+    UNIQUE(kind, artist_name, name)
+);
+
+CREATE INDEX artwork_status
+ON artwork(status, fetched_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX artwork_status;
+
+DROP TABLE artwork;
+-- +goose StatementEnd
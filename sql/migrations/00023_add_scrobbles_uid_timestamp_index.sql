@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX scrobbles_uid_timestamp
+ON scrobbles(uid, timestamp);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX scrobbles_uid_timestamp;
+-- +goose StatementEnd
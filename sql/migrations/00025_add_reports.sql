@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE reports (
+    id INTEGER PRIMARY KEY,
+    uid INTEGER NOT NULL,
+    slug TEXT NOT NULL UNIQUE,
+    period TEXT NOT NULL,
+    data TEXT NOT NULL,
+    created_at INTEGER NOT NULL,
+    CONSTRAINT fk_users
+    FOREIGN KEY(uid)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+CREATE INDEX reports_uid
+ON reports(uid);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX reports_uid;
+
+DROP TABLE reports;
+-- +goose StatementEnd
@@ -0,0 +1,59 @@
+-- Followed by 00027_hash_apikeys.go, a Go migration registered in
+-- internal/app/migrations.go.
+
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE apikeys_new (
+    id INTEGER PRIMARY KEY,
+    uid INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    hash TEXT NOT NULL UNIQUE,
+    scopes TEXT NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL DEFAULT 0,
+    last_used_at INTEGER,
+    expires_at INTEGER,
+    CONSTRAINT fk_users
+    FOREIGN KEY(uid)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+-- Keys are still raw here; 00027 hashes them. Existing keys keep every scope.
+INSERT INTO apikeys_new(uid, name, prefix, hash, scopes)
+SELECT uid, name, substr(key, 1, 8), key, 'scrobble:write stats:read share:post'
+FROM apikeys
+WHERE uid IS NOT NULL;
+
+DROP TABLE apikeys;
+
+ALTER TABLE apikeys_new
+RENAME TO apikeys;
+
+CREATE INDEX apikeys_uid
+ON apikeys(uid);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX apikeys_uid;
+
+-- Hashed keys can't be recovered, so every key stops working.
+CREATE TABLE apikeys_new (
+    key TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    uid INTEGER,
+    CONSTRAINT fk_users
+    FOREIGN KEY(uid)
+    REFERENCES users(uid)
+);
+
+INSERT INTO apikeys_new(key, name, uid)
+SELECT hash, name, uid
+FROM apikeys;
+
+DROP TABLE apikeys;
+
+ALTER TABLE apikeys_new
+RENAME TO apikeys;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+ADD COLUMN email TEXT;
+
+ALTER TABLE users
+ADD COLUMN email_verified_at INTEGER;
+
+ALTER TABLE users
+ADD COLUMN email_verification TEXT;
+
+ALTER TABLE users
+ADD COLUMN email_verification_time INTEGER;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+DROP COLUMN email_verification_time;
+
+ALTER TABLE users
+DROP COLUMN email_verification;
+
+ALTER TABLE users
+DROP COLUMN email_verified_at;
+
+ALTER TABLE users
+DROP COLUMN email;
+-- +goose StatementEnd
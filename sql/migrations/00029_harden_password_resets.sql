@@ -0,0 +1,26 @@
+-- Followed by 00030_add_session_uids.go, a Go migration registered in
+-- internal/app/migrations.go.
+
+-- +goose Up
+-- +goose StatementBegin
+-- Reset tokens are stored hashed from here on; outstanding plaintext ones
+-- can't be matched anymore, so drop them.
+UPDATE users
+SET reset = NULL,
+    reset_time = NULL;
+
+-- Sessions are tied to their user so a password reset can end all of them.
+-- 00030 fills this in for sessions that already exist.
+ALTER TABLE sessions
+ADD COLUMN uid INTEGER REFERENCES users(id) ON DELETE CASCADE;
+
+CREATE INDEX sessions_uid ON sessions(uid);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX sessions_uid;
+
+ALTER TABLE sessions
+DROP COLUMN uid;
+-- +goose StatementEnd
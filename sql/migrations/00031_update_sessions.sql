@@ -0,0 +1,65 @@
+-- +goose Up
+-- +goose StatementBegin
+-- One row per signed in device, keyed by its refresh token. Refreshing the
+-- access token updates the row instead of adding another, and ending a
+-- session deletes it.
+CREATE TABLE sessions_new (
+    id INTEGER PRIMARY KEY,
+    uid INTEGER NOT NULL,
+    accessToken TEXT NOT NULL,
+    refreshToken TEXT NOT NULL UNIQUE,
+    created_at INTEGER NOT NULL DEFAULT 0,
+    last_seen_at INTEGER NOT NULL DEFAULT 0,
+    expires_at INTEGER NOT NULL DEFAULT 0,
+    ip TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    CONSTRAINT fk_users
+    FOREIGN KEY(uid)
+    REFERENCES users(id)
+    ON DELETE CASCADE
+);
+
+-- Only the newest access token of each live session is still in use. When a
+-- session started isn't known, so it is treated as starting now and given the
+-- full 30 day refresh lifetime.
+INSERT INTO sessions_new(uid, accessToken, refreshToken, created_at, last_seen_at, expires_at)
+SELECT uid, accessToken, refreshToken, unixepoch() * 1000, unixepoch() * 1000, (unixepoch() + 30 * 24 * 60 * 60) * 1000
+FROM sessions
+WHERE rowid IN (
+    SELECT MAX(rowid)
+    FROM sessions
+    WHERE valid = 1 AND uid IS NOT NULL
+    GROUP BY refreshToken
+);
+
+DROP TABLE sessions;
+
+ALTER TABLE sessions_new
+RENAME TO sessions;
+
+CREATE INDEX sessions_uid ON sessions(uid);
+CREATE INDEX sessions_expires_at ON sessions(expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE TABLE sessions_old (
+    accessToken TEXT NOT NULL,
+    refreshToken TEXT NOT NULL,
+    valid INTEGER DEFAULT 1,
+    uid INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE(accessToken, refreshToken),
+    PRIMARY KEY(accessToken, refreshToken)
+);
+
+INSERT INTO sessions_old(accessToken, refreshToken, uid)
+SELECT accessToken, refreshToken, uid
+FROM sessions;
+
+DROP TABLE sessions;
+
+ALTER TABLE sessions_old
+RENAME TO sessions;
+
+CREATE INDEX sessions_uid ON sessions(uid);
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- Followed by 00034_split_scrobble_artists.go, a Go migration registered in
+-- internal/app/migrations.go.
+
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE musicbrainz_lookups
+ADD COLUMN length INTEGER;
+
+DROP INDEX scrobbles_missing_mbid;
+
+CREATE INDEX scrobbles_missing_mbid
+ON scrobbles(id)
+WHERE mbid IS NULL OR mbid = '' OR duration = 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX scrobbles_missing_mbid;
+
+CREATE INDEX scrobbles_missing_mbid
+ON scrobbles(id)
+WHERE mbid IS NULL OR mbid = '';
+
+ALTER TABLE musicbrainz_lookups
+DROP COLUMN length;
+-- +goose StatementEnd
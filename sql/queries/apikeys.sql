@@ -0,0 +1,51 @@
+-- name: SaveApiKey :one
+INSERT INTO apikeys(uid, name, prefix, hash, scopes, created_at, expires_at)
+VALUES(?, ?, ?, ?, ?, ?, ?)
+RETURNING id;
+
+-- name: GetApiKeys :many
+SELECT id, name, prefix, scopes, created_at, last_used_at, expires_at
+FROM apikeys
+WHERE uid = ?
+ORDER BY created_at DESC, id DESC;
+
+-- name: GetApiKeyUser :one
+SELECT apikeys.id, apikeys.scopes, users.username
+FROM apikeys
+JOIN users
+ON users.id = apikeys.uid
+WHERE apikeys.hash = sqlc.arg(hash)
+AND (apikeys.expires_at IS NULL OR apikeys.expires_at > sqlc.arg(now));
+
+-- name: TouchApiKey :exec
+UPDATE apikeys
+SET last_used_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id) AND ifnull(last_used_at, 0) < sqlc.arg(since);
+
+-- name: DeleteApiKey :execrows
+DELETE FROM apikeys
+WHERE uid = ? AND id = ?;
+
+-- name: GetUnhashedApiKeys :many
+SELECT id, hash
+FROM apikeys
+WHERE length(hash) != 64;
+
+-- name: SetApiKeyHash :exec
+UPDATE apikeys
+SET hash = ?
+WHERE id = ?;
+
+-- name: GetApiKeyByName :one
+SELECT id
+FROM apikeys
+WHERE uid = ? AND name = ?
+ORDER BY id DESC
+LIMIT 1;
+
+-- name: ReplaceApiKeyHash :exec
+UPDATE apikeys
+SET prefix = ?,
+    hash = ?,
+    last_used_at = NULL
+WHERE id = ?;
@@ -0,0 +1,52 @@
+-- name: GetArtwork :one
+SELECT id, status
+FROM artwork
+WHERE kind = ? AND artist_name = ? AND name = ?;
+
+-- name: QueueArtwork :exec
+INSERT INTO artwork(kind, artist_name, name)
+VALUES(?, ?, ?)
+ON CONFLICT(kind, artist_name, name) DO NOTHING;
+
+-- name: SeedArtwork :exec
+INSERT INTO artwork(kind, artist_name, name, source, url)
+VALUES(?, ?, ?, ?, ?)
+ON CONFLICT(kind, artist_name, name) DO UPDATE
+SET source = excluded.source,
+    url = excluded.url,
+    status = 'pending'
+WHERE artwork.status != 'found';
+
+-- name: GetPendingArtwork :many
+SELECT id, kind, artist_name, name, source, url
+FROM artwork
+WHERE status = 'pending' OR (status = 'missing' AND fetched_at < ?)
+ORDER BY id
+LIMIT ?;
+
+-- name: SaveArtwork :exec
+UPDATE artwork
+SET status = ?,
+    source = ?,
+    url = ?,
+    image = ?,
+    content_type = ?,
+    fetched_at = ?
+WHERE id = ?;
+
+-- name: GetArtworkImage :one
+SELECT image, content_type
+FROM artwork
+WHERE id = ? AND status = 'found';
+
+-- name: GetAlbumMbid :one
+SELECT album_mbid
+FROM scrobbles
+WHERE artist_name = ? AND album_name = ? AND album_mbid IS NOT NULL AND album_mbid != ''
+LIMIT 1;
+
+-- name: GetTrackAlbumMbid :one
+SELECT album_mbid
+FROM scrobbles
+WHERE artist_name = ? AND track_name = ? AND album_mbid IS NOT NULL AND album_mbid != ''
+LIMIT 1;
@@ -0,0 +1,66 @@
+-- name: QueueLastFMScrobble :exec
+INSERT INTO lastfm_queue(uid, artist_name, track_name, album_name, album_artist, track_number, mbid, duration, timestamp, next_attempt, last_error)
+VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: GetDueLastFMScrobbles :many
+SELECT lastfm_queue.id, lastfm_queue.uid, users.username, artist_name, track_name, album_name, album_artist, track_number, mbid, duration, timestamp, attempts
+FROM lastfm_queue
+JOIN users
+ON users.id = lastfm_queue.uid
+WHERE next_attempt <= ?
+ORDER BY timestamp
+LIMIT ?;
+
+-- name: RetryLastFMScrobble :exec
+UPDATE lastfm_queue
+SET attempts = attempts + 1,
+    next_attempt = ?,
+    last_error = ?
+WHERE id = ?;
+
+-- name: RemoveQueuedLastFMScrobble :exec
+DELETE FROM lastfm_queue
+WHERE id = ?;
+
+-- name: StartLastFMImport :exec
+INSERT INTO lastfm_imports(uid, status, page, total_pages, imported, skipped, since, until, started_at, updated_at)
+VALUES(?, "running", 1, 0, 0, 0, ?, ?, ?, ?)
+ON CONFLICT(uid) DO UPDATE
+SET status = "running",
+    page = 1,
+    total_pages = 0,
+    imported = 0,
+    skipped = 0,
+    since = excluded.since,
+    until = excluded.until,
+    started_at = excluded.started_at,
+    updated_at = excluded.updated_at,
+    error = NULL;
+
+-- name: GetLastFMImport :one
+SELECT uid, status, page, total_pages, imported, skipped, since, until, started_at, updated_at, error
+FROM lastfm_imports
+WHERE uid = ?;
+
+-- name: GetRunningLastFMImports :many
+SELECT lastfm_imports.uid, users.username
+FROM lastfm_imports
+JOIN users
+ON users.id = lastfm_imports.uid
+WHERE status = "running";
+
+-- name: UpdateLastFMImportProgress :exec
+UPDATE lastfm_imports
+SET page = ?,
+    total_pages = ?,
+    imported = imported + ?,
+    skipped = skipped + ?,
+    updated_at = ?
+WHERE uid = ?;
+
+-- name: SetLastFMImportStatus :exec
+UPDATE lastfm_imports
+SET status = ?,
+    error = ?,
+    updated_at = ?
+WHERE uid = ?;
@@ -0,0 +1,30 @@
+-- name: GetMusicBrainzLookup :one
+SELECT artist_name, track_name, album_name, recording_mbid, release_mbid, artist_mbid, found, looked_up_at, length
+FROM musicbrainz_lookups
+WHERE artist_name = ? AND track_name = ? AND album_name = ?;
+
+-- name: SaveMusicBrainzLookup :exec
+INSERT INTO musicbrainz_lookups(artist_name, track_name, album_name, recording_mbid, release_mbid, artist_mbid, found, looked_up_at, length)
+VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(artist_name, track_name, album_name) DO UPDATE
+SET recording_mbid = excluded.recording_mbid,
+    release_mbid = excluded.release_mbid,
+    artist_mbid = excluded.artist_mbid,
+    found = excluded.found,
+    looked_up_at = excluded.looked_up_at,
+    length = excluded.length;
+
+-- name: GetScrobblesMissingMbids :many
+SELECT id, artist_name, track_name, album_name
+FROM scrobbles
+WHERE (mbid IS NULL OR mbid = '' OR duration = 0) AND id > ?
+ORDER BY id
+LIMIT ?;
+
+-- name: SetScrobbleMbids :exec
+UPDATE scrobbles
+SET mbid = coalesce(nullif(mbid, ''), sqlc.arg(mbid)),
+    album_mbid = coalesce(nullif(album_mbid, ''), sqlc.arg(album_mbid)),
+    artist_mbid = coalesce(nullif(artist_mbid, ''), sqlc.arg(artist_mbid)),
+    duration = CASE WHEN duration = 0 THEN sqlc.arg(duration) ELSE duration END
+WHERE id = sqlc.arg(id);
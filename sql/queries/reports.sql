@@ -0,0 +1,35 @@
+-- name: GetReportScrobbles :many
+SELECT timestamp, duration, artist_name, track_name
+FROM scrobbles
+WHERE uid = sqlc.arg(uid)
+AND timestamp >= sqlc.arg(since) AND timestamp < sqlc.arg(until)
+ORDER BY timestamp;
+
+-- name: GetNewArtists :many
+SELECT scrobble_artists.name as artist, CAST(min(timestamp) AS INTEGER) as first_played, count(*) as plays
+FROM scrobbles
+JOIN scrobble_artists
+ON scrobble_artists.scrobble_id = scrobbles.id
+WHERE uid = sqlc.arg(uid) AND timestamp < sqlc.arg(until)
+GROUP BY scrobble_artists.name
+HAVING first_played >= sqlc.arg(since)
+ORDER BY plays DESC, first_played, artist;
+
+-- name: SaveReport :exec
+INSERT INTO reports (uid, slug, period, data, created_at)
+VALUES (?, ?, ?, ?, ?);
+
+-- name: GetReport :one
+SELECT data
+FROM reports
+WHERE slug = ?;
+
+-- name: GetReports :many
+SELECT slug, period, created_at
+FROM reports
+WHERE uid = ?
+ORDER BY created_at DESC;
+
+-- name: DeleteReport :execrows
+DELETE FROM reports
+WHERE uid = ? AND slug = ?;
@@ -0,0 +1,39 @@
+-- name: GetScrobbleRules :many
+SELECT id, uid, source, mode, min_percent, min_seconds, max_track_seconds, count_repeats
+FROM scrobble_rules
+WHERE uid = ?
+ORDER BY source;
+
+-- name: SaveScrobbleRule :exec
+INSERT INTO scrobble_rules(uid, source, mode, min_percent, min_seconds, max_track_seconds, count_repeats)
+VALUES(?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(uid, source) DO UPDATE
+SET mode = excluded.mode,
+    min_percent = excluded.min_percent,
+    min_seconds = excluded.min_seconds,
+    max_track_seconds = excluded.max_track_seconds,
+    count_repeats = excluded.count_repeats;
+
+-- name: DeleteScrobbleRule :exec
+DELETE FROM scrobble_rules
+WHERE uid = ? AND source = ?;
+
+-- name: GetRewriteRules :many
+SELECT id, uid, field, match_type, pattern, replacement
+FROM rewrite_rules
+WHERE uid = ?
+ORDER BY id;
+
+-- name: SaveRewriteRule :execlastid
+INSERT INTO rewrite_rules(uid, field, match_type, pattern, replacement)
+VALUES(?, ?, ?, ?, ?);
+
+-- name: DeleteRewriteRule :execrows
+DELETE FROM rewrite_rules
+WHERE id = ? AND uid = ?;
+
+-- name: GetScrobbleSources :many
+SELECT DISTINCT source
+FROM scrobbles
+WHERE uid = ? AND source IS NOT NULL AND source != ''
+ORDER BY source;
@@ -5,45 +5,123 @@ WHERE uid = ?
 ORDER BY timestamp DESC
 LIMIT 1;
 
--- name: SaveScrobble :exec
-INSERT INTO scrobbles(artist_name, track_name, album_name, album_artist, mbid, track_number, duration, timestamp, source, uid)
-VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+-- name: SaveScrobble :execlastid
+INSERT INTO scrobbles(artist_name, track_name, album_name, album_artist, mbid, album_mbid, artist_mbid, track_number, duration, timestamp, source, uid)
+VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
 
--- name: RemoveScrobble :exec
-DELETE FROM scrobbles
-WHERE id = ?;
+-- name: ScrobbleExistsNear :one
+SELECT EXISTS (
+    SELECT 1
+    FROM scrobbles
+    WHERE uid = sqlc.arg(uid)
+    AND artist_name = sqlc.arg(artist_name)
+    AND track_name = sqlc.arg(track_name)
+    AND timestamp BETWEEN sqlc.arg(start) AND sqlc.arg(end)
+) as found;
 
--- name: GetTopAlbumsOfYear :many
-SELECT album_name, artist_name, count(id) as plays
+-- name: GetScrobbles :many
+SELECT id, artist_name, track_name, album_name, album_artist, timestamp, duration, source
 FROM scrobbles
-WHERE uid = ? AND album_name IS NOT "Unknown" AND album_name IS NOT NULL AND album_name IS NOT "" AND (timestamp / 1000) >= (strftime("%s", "now") - (60*60*24*365))
-GROUP BY album_name
-ORDER BY plays DESC
-LIMIT ?;
+WHERE uid = ?
+ORDER BY timestamp DESC
+LIMIT ? OFFSET ?;
 
--- name: GetTopAlbumsOfMonth :many
-SELECT album_name, artist_name, count(id) as plays
+-- name: CountScrobbles :one
+SELECT count(id) as total
 FROM scrobbles
-WHERE uid = ? AND album_name IS NOT "Unknown" AND album_name IS NOT NULL AND album_name IS NOT "" AND (timestamp / 1000) >= (strftime("%s", "now") - (60*60*24*30))
-GROUP BY album_name
-ORDER BY plays DESC
-LIMIT ?;
+WHERE uid = ?;
 
--- name: GetTopAlbumsOfWeek :many
-SELECT album_name, artist_name, count(id) as plays
+-- name: GetScrobble :one
+SELECT id, artist_name, track_name, album_name, album_artist, timestamp, duration, source
 FROM scrobbles
-WHERE uid = ? AND album_name IS NOT "Unknown" AND album_name IS NOT NULL AND album_name IS NOT "" AND (timestamp / 1000) >= (strftime("%s", "now") - (60*60*24*7))
-GROUP BY album_name
-ORDER BY plays DESC
-LIMIT ?;
+WHERE id = ? AND uid = ?;
+
+-- name: UpdateScrobble :execrows
+UPDATE scrobbles
+SET artist_name = ?,
+    track_name = ?,
+    album_name = ?
+WHERE id = ? AND uid = ?;
+
+-- name: AddScrobbleArtist :exec
+INSERT OR IGNORE INTO scrobble_artists(scrobble_id, name, position)
+VALUES(?, ?, ?);
+
+-- name: GetScrobbleArtists :many
+SELECT name
+FROM scrobble_artists
+WHERE scrobble_id = ?
+ORDER BY position;
+
+-- name: RemoveScrobbleArtist :exec
+DELETE FROM scrobble_artists
+WHERE scrobble_id = ? AND name = ?;
 
--- name: GetTopAlbumsOfDay :many
-SELECT album_name, artist_name, count(id) as plays
+-- name: RemoveScrobbleArtists :exec
+DELETE FROM scrobble_artists
+WHERE scrobble_id IN (
+    SELECT id
+    FROM scrobbles
+    WHERE id = ? AND uid = ?
+);
+
+-- name: RenameArtistCredits :exec
+UPDATE OR IGNORE scrobble_artists
+SET name = sqlc.arg(new_name)
+WHERE name = sqlc.arg(old_name) AND scrobble_id IN (
+    SELECT id
+    FROM scrobbles
+    WHERE uid = sqlc.arg(uid)
+);
+
+-- name: RemoveArtistCredits :exec
+DELETE FROM scrobble_artists
+WHERE name = sqlc.arg(name) AND scrobble_id IN (
+    SELECT id
+    FROM scrobbles
+    WHERE uid = sqlc.arg(uid)
+);
+
+-- name: RemoveScrobble :execrows
+DELETE FROM scrobbles
+WHERE id = ? AND uid = ?;
+
+-- name: CountArtistScrobbles :one
+SELECT count(DISTINCT scrobbles.id) as total
 FROM scrobbles
-WHERE uid = ? AND album_name IS NOT "Unknown" AND album_name IS NOT NULL AND album_name IS NOT "" AND (timestamp / 1000) >= (strftime("%s", "now") - (60*60*24*1))
-GROUP BY album_name
-ORDER BY plays DESC
-LIMIT ?;
+JOIN scrobble_artists
+ON scrobble_artists.scrobble_id = scrobbles.id
+WHERE uid = ? AND scrobble_artists.name = ?;
+
+-- name: RenameScrobbleArtist :execrows
+UPDATE scrobbles
+SET artist_name = sqlc.arg(new_name)
+WHERE uid = sqlc.arg(uid) AND artist_name = sqlc.arg(old_name);
+
+-- name: RenameScrobbleAlbumArtist :execrows
+UPDATE scrobbles
+SET album_artist = sqlc.arg(new_name)
+WHERE uid = sqlc.arg(uid) AND album_artist = sqlc.arg(old_name);
+
+-- name: CountAlbumScrobbles :one
+SELECT count(id) as total
+FROM scrobbles
+WHERE uid = ? AND artist_name = ? AND album_name = ?;
+
+-- name: RenameScrobbleAlbum :execrows
+UPDATE scrobbles
+SET album_name = sqlc.arg(new_name)
+WHERE uid = sqlc.arg(uid) AND artist_name = sqlc.arg(artist_name) AND album_name = sqlc.arg(old_name);
+
+-- name: CountTrackScrobbles :one
+SELECT count(id) as total
+FROM scrobbles
+WHERE uid = ? AND artist_name = ? AND track_name = ?;
+
+-- name: RenameScrobbleTrack :execrows
+UPDATE scrobbles
+SET track_name = sqlc.arg(new_name)
+WHERE uid = sqlc.arg(uid) AND artist_name = sqlc.arg(artist_name) AND track_name = sqlc.arg(old_name);
 
 -- name: GetRecentScrobbles :many
 SELECT artist_name, track_name, timestamp, duration
@@ -52,82 +130,19 @@ WHERE uid = ?
 ORDER BY timestamp DESC
 LIMIT 5;
 
--- name: GetTopTracksOfYear :many
-SELECT track_name, artist_name, count(id) as plays
-FROM scrobbles
-WHERE uid = ? AND (timestamp / 1000) >= (strftime("%s", "now") - (60*60*24*365))
-GROUP BY track_name
-ORDER BY plays DESC
-LIMIT ?;
-
--- name: GetTopTracksOfMonth :many
-SELECT track_name, artist_name, count(id) as plays
-FROM scrobbles
-WHERE uid = ? AND (timestamp / 1000) >= (strftime("%s", "now") - (60*60*24*30))
-GROUP BY track_name
-ORDER BY plays DESC
-LIMIT ?;
-
--- name: GetTopTracksOfWeek :many
-SELECT track_name, artist_name, count(id) as plays
-FROM scrobbles
-WHERE uid = ? AND (timestamp / 1000) >= (strftime("%s", "now") - (60*60*24*7))
-GROUP BY track_name
-ORDER BY plays DESC
-LIMIT ?;
 
--- name: GetTopTracksOfDay :many
-SELECT track_name, artist_name, count(id) as plays
+-- name: GetFeaturedScrobbles :many
+-- Only the delimited forms Normalize looks for, so titles like "Aftermath"
+-- aren't pulled in.
+SELECT id, artist_name, track_name
 FROM scrobbles
-WHERE uid = ? AND (timestamp / 1000) >= (strftime("%s", "now") - (60*60*24*1))
-GROUP BY track_name
-ORDER BY plays DESC
-LIMIT ?;
-
--- name: GetTopArtistsOfYear :many
-With splits as (
-  SELECT trim(value) as artist
-  FROM scrobbles, json_each('["' || replace(artist_name, ',', '","') || '"]')
-  WHERE uid = ? AND (timestamp / 1000) >= (strftime("%s", "now") - (60*60*24*365))
-)
-SELECT artist, count(*) as plays
-FROM splits
-group by artist
-order by plays DESC
-limit ?;
-
--- name: GetTopArtistsOfMonth :many
-With splits as (
-  SELECT trim(value) as artist
-  FROM scrobbles, json_each('["' || replace(artist_name, ',', '","') || '"]')
-  WHERE uid = ? AND (timestamp / 1000) >= (strftime("%s", "now") - (60*60*24*30))
-)
-SELECT artist, count(*) as plays
-FROM splits
-group by artist
-order by plays DESC
-limit ?;
-
--- name: GetTopArtistsOfWeek :many
-With splits as (
-  SELECT trim(value) as artist
-  FROM scrobbles, json_each('["' || replace(artist_name, ',', '","') || '"]')
-  WHERE uid = ? AND (timestamp / 1000) >= (strftime("%s", "now") - (60*60*24*7))
-)
-SELECT artist, count(*) as plays
-FROM splits
-group by artist
-order by plays DESC
-limit ?;
-
--- name: GetTopArtistsOfDay :many
-With splits as (
-  SELECT trim(value) as artist
-  FROM scrobbles, json_each('["' || replace(artist_name, ',', '","') || '"]')
-  WHERE uid = ? AND (timestamp / 1000) >= (strftime("%s", "now") - (60*60*24*1))
-)
-SELECT artist, count(*) as plays
-FROM splits
-group by artist
-order by plays DESC
-limit ?;
+WHERE artist_name LIKE '%,%'
+OR artist_name LIKE '% feat %'
+OR artist_name LIKE '% feat. %'
+OR artist_name LIKE '% ft %'
+OR artist_name LIKE '% ft. %'
+OR artist_name LIKE '% featuring %'
+OR track_name LIKE '%(feat%'
+OR track_name LIKE '%[feat%'
+OR track_name LIKE '%(ft%'
+OR track_name LIKE '%[ft%';
@@ -24,6 +24,22 @@ SET lastfm_session_name = ?,
     lastfm_session_key = ?
 WHERE username = ?;
 
+-- name: SaveLastFMAuthState :exec
+UPDATE users
+SET lastfm_auth_state = ?
+WHERE username = ?;
+
+-- name: ClaimLastFMAuthState :execrows
+UPDATE users
+SET lastfm_auth_state = NULL
+WHERE username = ? AND lastfm_auth_state = ?;
+
+-- name: RemoveLastFMSession :exec
+UPDATE users
+SET lastfm_session_name = NULL,
+    lastfm_session_key = NULL
+WHERE username = ?;
+
 -- name: SaveSpotifySession :exec
 UPDATE users
 SET spotify_access_token = ?,
@@ -47,20 +63,66 @@ SET spotify_access_token = ?,
 WHERE username = ?;
 
 -- name: GetUserSession :one
-SELECT accessToken, refreshToken, valid
+SELECT sessions.id, users.username
 FROM sessions
-WHERE accessToken = ? AND refreshToken = ?
-LIMIT 1;
+JOIN users
+ON users.id = sessions.uid
+WHERE sessions.refreshToken = ?;
 
 -- name: SaveUserSession :exec
-INSERT INTO sessions(accessToken, refreshToken)
-VALUES(?, ?);
+INSERT INTO sessions(uid, accessToken, refreshToken, created_at, last_seen_at, expires_at, ip, user_agent)
+SELECT id, sqlc.arg(accesstoken), sqlc.arg(refreshtoken), sqlc.arg(created_at), sqlc.arg(last_seen_at), sqlc.arg(expires_at), sqlc.arg(ip), sqlc.arg(user_agent)
+FROM users
+WHERE username = sqlc.arg(username);
 
--- name: InvalidateUserSession :exec
+-- name: RefreshUserSession :execrows
 UPDATE sessions
-SET valid = 0
+SET accessToken = sqlc.arg(accesstoken)
+WHERE refreshToken = sqlc.arg(refreshtoken);
+
+-- name: TouchUserSession :exec
+UPDATE sessions
+SET last_seen_at = sqlc.arg(now),
+    ip = sqlc.arg(ip),
+    user_agent = sqlc.arg(user_agent)
+WHERE id = sqlc.arg(id) AND last_seen_at < sqlc.arg(since);
+
+-- name: DeleteUserSession :exec
+DELETE FROM sessions
 WHERE accessToken = ? AND refreshToken = ?;
 
+-- name: EndUserSession :exec
+DELETE FROM sessions
+WHERE refreshToken = ?;
+
+-- name: DeleteUserSessions :exec
+DELETE FROM sessions
+WHERE uid = ?;
+
+-- name: GetUserSessions :many
+SELECT id, refreshToken, created_at, last_seen_at, expires_at, ip, user_agent
+FROM sessions
+WHERE uid = ?
+ORDER BY last_seen_at DESC;
+
+-- name: RevokeUserSession :execrows
+DELETE FROM sessions
+WHERE id = ? AND uid = ?;
+
+-- name: DeleteExpiredSessions :execrows
+DELETE FROM sessions
+WHERE expires_at < ?;
+
+-- name: GetUnattributedSessions :many
+SELECT accessToken, refreshToken
+FROM sessions
+WHERE uid IS NULL;
+
+-- name: SetSessionUid :exec
+UPDATE sessions
+SET uid = (SELECT id FROM users WHERE username = sqlc.arg(username))
+WHERE accessToken = sqlc.arg(accesstoken) AND refreshToken = sqlc.arg(refreshtoken);
+
 -- name: SaveMusicSession :exec
 INSERT INTO music_sessions(data, type, active, uid)
 VALUES(?, ?, ?, ?);
@@ -0,0 +1,50 @@
+-- name: GetTopArtists :many
+SELECT scrobble_artists.name as artist, count(*) as plays, CAST(coalesce(sum(duration), 0) AS INTEGER) as listened
+FROM scrobbles
+JOIN scrobble_artists
+ON scrobble_artists.scrobble_id = scrobbles.id
+WHERE uid = sqlc.arg(uid)
+AND timestamp >= sqlc.arg(since) AND timestamp < sqlc.arg(until)
+AND ifnull(source, '') = coalesce(nullif(sqlc.arg(source), ''), ifnull(source, ''))
+GROUP BY scrobble_artists.name
+ORDER BY CASE WHEN sqlc.arg(sort) = 'listened' THEN listened ELSE plays END DESC, plays DESC, artist
+LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
+
+-- name: GetTopAlbums :many
+SELECT album_name, artist_name, count(id) as plays, CAST(coalesce(sum(duration), 0) AS INTEGER) as listened
+FROM scrobbles
+WHERE uid = sqlc.arg(uid)
+AND album_name IS NOT "Unknown" AND album_name IS NOT NULL AND album_name IS NOT ""
+AND timestamp >= sqlc.arg(since) AND timestamp < sqlc.arg(until)
+AND ifnull(source, '') = coalesce(nullif(sqlc.arg(source), ''), ifnull(source, ''))
+GROUP BY artist_name, album_name
+ORDER BY CASE WHEN sqlc.arg(sort) = 'listened' THEN listened ELSE plays END DESC, plays DESC, album_name
+LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
+
+-- name: GetTopTracks :many
+SELECT track_name, artist_name, count(id) as plays, CAST(coalesce(sum(duration), 0) AS INTEGER) as listened
+FROM scrobbles
+WHERE uid = sqlc.arg(uid)
+AND timestamp >= sqlc.arg(since) AND timestamp < sqlc.arg(until)
+AND ifnull(source, '') = coalesce(nullif(sqlc.arg(source), ''), ifnull(source, ''))
+GROUP BY artist_name, track_name
+ORDER BY CASE WHEN sqlc.arg(sort) = 'listened' THEN listened ELSE plays END DESC, plays DESC, track_name
+LIMIT sqlc.arg(limit) OFFSET sqlc.arg(offset);
+
+-- name: GetScrobbleTotals :one
+SELECT count(id) as plays, CAST(coalesce(sum(duration), 0) AS INTEGER) as listened
+FROM scrobbles
+WHERE uid = sqlc.arg(uid)
+AND timestamp >= sqlc.arg(since) AND timestamp < sqlc.arg(until)
+AND ifnull(source, '') = coalesce(nullif(sqlc.arg(source), ''), ifnull(source, ''));
+
+-- name: GetListening :many
+-- Plays are grouped into UTC quarter hours. Every time zone is offset by
+-- whole quarter hours, so each group falls inside one local hour.
+SELECT CAST(timestamp / 900000 * 900000 AS INTEGER) as start, count(id) as plays, CAST(coalesce(sum(duration), 0) AS INTEGER) as listened
+FROM scrobbles
+WHERE uid = sqlc.arg(uid)
+AND timestamp >= sqlc.arg(since) AND timestamp < sqlc.arg(until)
+AND ifnull(source, '') = coalesce(nullif(sqlc.arg(source), ''), ifnull(source, ''))
+GROUP BY start
+ORDER BY start;
@@ -3,10 +3,15 @@ INSERT INTO users(username, password)
 VALUES(?, ?);
 
 -- name: GetUser :one
-SELECT id, username
+SELECT id, username, timezone
 FROM users
 WHERE username = ?;
 
+-- name: SetUserTimezone :exec
+UPDATE users
+SET timezone = ?
+WHERE id = ?;
+
 -- name: GetUserWithPassword :one
 SELECT username, password
 FROM users
@@ -18,37 +23,40 @@ SET reset = ?,
     reset_time = ?
 WHERE username = ?;
 
--- name: ResetPassword :exec
+-- name: ResetPassword :one
 UPDATE users
 SET reset = NULL,
     reset_time = NULL,
     password = ?
-WHERE reset = ? AND reset_time > ?;
+WHERE username = ? AND reset = ? AND reset_time > ?
+RETURNING id;
 
 -- name: CanResetPassword :one
 SELECT reset_time > ? AS valid, username
 FROM users
 WHERE reset = ?;
 
--- name: SaveApiKey :exec
-INSERT INTO apikeys(name, key, uid)
-VALUES(?, ?, ?);
+-- name: GetUserEmail :one
+SELECT email, email_verified_at
+FROM users
+WHERE id = ?;
 
--- name: GetApiKeysForUid :many
-SELECT key, name
-FROM apikeys
-WHERE uid = ?;
+-- name: GetVerifiedEmail :one
+SELECT email
+FROM users
+WHERE username = ? AND email_verified_at IS NOT NULL;
 
--- name: CheckValidApiKey :one
-SELECT EXISTS (
-    SELECT 1
-    FROM apikeys
-    WHERE key = ?
-) as valid;
+-- name: SetUserEmail :exec
+UPDATE users
+SET email = ?,
+    email_verified_at = NULL,
+    email_verification = ?,
+    email_verification_time = ?
+WHERE id = ?;
 
--- name: GetUserFromApiKey :one
-SELECT username
-FROM users
-JOIN apikeys
-ON users.id = apikeys.uid
-WHERE apikeys.key = ?;
+-- name: VerifyEmail :execrows
+UPDATE users
+SET email_verified_at = sqlc.arg(verified_at),
+    email_verification = NULL,
+    email_verification_time = NULL
+WHERE email_verification = sqlc.arg(token) AND email_verification_time > sqlc.arg(now);